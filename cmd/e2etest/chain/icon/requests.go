@@ -1,6 +1,7 @@
 package icon
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -33,6 +34,7 @@ type requestAPI struct {
 	contractNameToAddress map[chain.ContractName]string
 	networkID             string
 	cl                    *icon.Client
+	txm                   *txManager
 	stepLimit             int64
 	nativeCoin            string
 	wrappedCoinsAddr      map[string]string
@@ -47,9 +49,18 @@ func newRequestAPI(cl *icon.Client, cfg *chain.Config) (req *requestAPI, err err
 		networkID:             strings.Split(cfg.NetworkID, ".")[0],
 		contractNameToAddress: cfg.ContractAddresses,
 		cl:                    cl,
+		txm:                   newTxManager(cl),
 		stepLimit:             cfg.GasLimit,
 		nativeCoin:            cfg.NativeCoin,
 	}
+	if req.networkID == "" {
+		// ".icon": the nid of the chain of the endpoint
+		ni, err := cl.GetNetworkInfo(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "GetNetworkInfo ")
+		}
+		req.networkID = string(ni.NID)
+	}
 	req.nativeTokensAddr, req.wrappedCoinsAddr, err = req.getCoinAddresses(cfg.NativeTokens, cfg.WrappedCoins)
 	return req, err
 }
@@ -63,26 +74,21 @@ func (r *requestAPI) transactWithContract(senderKey string, contractAddress stri
 		return
 	}
 
-	param := icon.TransactionParam{
-		Version:     icon.NewHexInt(icon.JsonrpcApiVersion),
-		ToAddress:   icon.Address(contractAddress),
-		Value:       icon.HexInt(intconv.FormatBigInt(amount)), //NewHexInt(amount.Int64()) Using Int64() can overflow for large amounts
-		FromAddress: icon.Address(senderWallet.Address().String()),
-		StepLimit:   icon.NewHexInt(r.stepLimit),
-		Timestamp:   icon.NewHexInt(time.Now().UnixNano() / int64(time.Microsecond)),
-		NetworkID:   icon.HexInt(r.networkID),
-		DataType:    "call",
-	}
-	argMap := map[string]interface{}{}
-	argMap["method"] = method
-	argMap["params"] = args
-	param.Data = argMap
-
-	if err = SignTransactionParam(senderWallet, &param); err != nil {
-		err = errors.Wrap(err, "SignTransactionParam ")
+	param, err := icon.NewTransactionBuilder().
+		From(icon.Address(senderWallet.Address().String())).
+		To(icon.Address(contractAddress)).
+		Value(amount).
+		StepLimit(r.stepLimit).
+		NetworkID(icon.HexInt(r.networkID)).
+		CallMethod(method).
+		Params(args).
+		Build()
+	if err != nil {
+		err = errors.Wrap(err, "TransactionBuilder ")
 		return
 	}
-	txH, err := r.cl.SendTransaction(&param)
+
+	txH, err := r.txm.Send(senderWallet, param)
 	if err != nil {
 		err = errors.Wrap(err, "SendTransaction ")
 		return
@@ -138,20 +144,18 @@ func (r *requestAPI) transferNativeIntraChain(senderKey, recepientAddress string
 		err = errors.Wrap(err, "GetWalletFromPrivKey ")
 		return
 	}
-	param := icon.TransactionParam{
-		Version:     icon.NewHexInt(icon.JsonrpcApiVersion),
-		ToAddress:   icon.Address(recepientAddress),
-		Value:       icon.HexInt(intconv.FormatBigInt(amount)), //NewHexInt(amount.Int64()) Using Int64() can overflow for large amounts
-		FromAddress: icon.Address(senderWallet.Address().String()),
-		StepLimit:   icon.NewHexInt(r.stepLimit),
-		Timestamp:   icon.NewHexInt(time.Now().UnixNano() / int64(time.Microsecond)),
-		NetworkID:   icon.HexInt(r.networkID),
-	}
-	if err = SignTransactionParam(senderWallet, &param); err != nil {
-		err = errors.Wrap(err, "SignTransactionParam ")
+	param, err := icon.NewTransactionBuilder().
+		From(icon.Address(senderWallet.Address().String())).
+		To(icon.Address(recepientAddress)).
+		Value(amount).
+		StepLimit(r.stepLimit).
+		NetworkID(icon.HexInt(r.networkID)).
+		Build()
+	if err != nil {
+		err = errors.Wrap(err, "TransactionBuilder ")
 		return
 	}
-	txH, err := r.cl.SendTransaction(&param)
+	txH, err := r.txm.Send(senderWallet, param)
 	if err != nil {
 		err = errors.Wrap(err, "SendTransaction ")
 		return
@@ -265,18 +269,12 @@ func (r *requestAPI) getCoinBalance(coinName, addr string) (bal *chain.CoinBalan
 	if coinName == r.nativeCoin {
 		return r.getNativeCoinBalance(coinName, addr)
 	}
-	getBalanceOfType := func(balanceMap map[string]interface{}, key string) (bal *big.Int, err error) {
-		tmp, ok := balanceMap[key]
-		if !ok {
-			return nil, fmt.Errorf("")
-		}
-		tmpStr, ok := tmp.(string)
-		if !ok {
-			return nil, fmt.Errorf("Expected type string Got %T", tmp)
+	getBalanceOfType := func(balanceMap interface{}, key string) (*big.Int, error) {
+		v, err := icon.CallResultField(balanceMap, key)
+		if err != nil {
+			return nil, err
 		}
-		bal = new(big.Int)
-		bal.SetString(tmpStr[2:], 16)
-		return
+		return icon.DecodeCallBigInt(v)
 	}
 
 	// Tokens ..
@@ -292,10 +290,7 @@ func (r *requestAPI) getCoinBalance(coinName, addr string) (bal *chain.CoinBalan
 	} else if res == nil {
 		return nil, errors.New("callContract returned nil value ")
 	}
-	balanceMap, ok := res.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("Expected type map[string]interface{} Got %T", res)
-	}
+	balanceMap := res
 	bal = &chain.CoinBalance{}
 	bal.UsableBalance, err = getBalanceOfType(balanceMap, "usable")
 	if err != nil {
@@ -381,12 +376,11 @@ func (r *requestAPI) getCoinAddresses(nativeTokens, wrappedCoins []string) (toke
 			err = fmt.Errorf("Call to Method %v returned nil for _coinName=%v", "coinId", coin)
 			return
 		}
-		coinId, ok := res.(string)
-		if !ok {
-			err = fmt.Errorf("For method coinId, Expected Type string Got %T", res)
-			return
+		addr, err := icon.DecodeCallAddress(res)
+		if err != nil {
+			return "", errors.Wrapf(err, "coinId %v", err)
 		}
-		return coinId, nil
+		return string(addr), nil
 	}
 
 	tokenAddrMap = map[string]string{}
@@ -427,17 +421,26 @@ func (r *requestAPI) getNativeCoinBalance(coinName, addr string) (bal *chain.Coi
 	return
 }
 
-func SignTransactionParam(wallet module.Wallet, param *icon.TransactionParam) error {
+// serializeTransactionParam returns the bytes of param whose hash is signed
+// and used as the transaction hash.
+func serializeTransactionParam(param *icon.TransactionParam) ([]byte, error) {
 	js, err := json.Marshal(param)
 	if err != nil {
-		return errors.Wrap(err, "jsonMarshal ")
+		return nil, errors.Wrap(err, "jsonMarshal ")
 	}
 	var txSerializeExcludes = map[string]bool{"signature": true}
 	bs, err := transaction.SerializeJSON(js, nil, txSerializeExcludes)
 	if err != nil {
-		return errors.Wrap(err, "tx.SerializeJSON ")
+		return nil, errors.Wrap(err, "tx.SerializeJSON ")
+	}
+	return append([]byte("icx_sendTransaction."), bs...), nil
+}
+
+func SignTransactionParam(wallet module.Wallet, param *icon.TransactionParam) error {
+	bs, err := serializeTransactionParam(param)
+	if err != nil {
+		return err
 	}
-	bs = append([]byte("icx_sendTransaction."), bs...)
 	sig, err := wallet.Sign(gocrypto.SHA3Sum256(bs))
 	if err != nil {
 		return errors.Wrap(err, "wallet.Sign ")
@@ -497,14 +500,9 @@ func (a *api) CallBTS(method chain.ContractCallMethodName, args []interface{}) (
 		} else if res == nil {
 			return nil, errors.New("isUserBlackListed result is nil")
 		}
-		resStr, ok := res.(string)
-		if !ok {
-			return nil, fmt.Errorf("isUserBlackListed Response Expected string Got %T", res)
-		}
-		if resStr == "0x0" {
-			response = false
-		} else {
-			response = true
+		response, err = icon.DecodeCallBool(res)
+		if err != nil {
+			return nil, errors.Wrapf(err, "isUserBlackListed %v", err)
 		}
 	} else if method == chain.GetTokenLimit {
 		if len(args) != 1 {
@@ -540,11 +538,11 @@ func (a *api) CallBTS(method chain.ContractCallMethodName, args []interface{}) (
 		} else if res == nil {
 			return nil, errors.New("isUserBlackListed returned nil")
 		}
-		if resOk, ok := res.(string); !ok {
-			return nil, fmt.Errorf("Expected bool Got type %T", res)
-		} else if ok && resOk == "0x1" {
+		if blacklisted, err := icon.DecodeCallBool(res); err != nil {
+			return nil, errors.Wrapf(err, "isUserBlackListed %v", err)
+		} else if blacklisted {
 			response = errors.New("Blacklisted")
-			return
+			return response, nil
 		}
 		res, err = a.requester.callContract(btsAddr, map[string]interface{}{"_name": args[1]}, "getTokenLimit")
 		if err != nil {
@@ -552,12 +550,10 @@ func (a *api) CallBTS(method chain.ContractCallMethodName, args []interface{}) (
 		} else if res == nil {
 			return nil, errors.New("getTokenLimit returned nil")
 		}
-		limitStr, ok := res.(string)
-		if !ok {
-			return nil, fmt.Errorf("Expected string for Tokenlimit Got type %T", res)
+		var limit *big.Int
+		if limit, err = icon.DecodeCallBigInt(res); err != nil {
+			return nil, errors.Wrapf(err, "getTokenLimit %v", err)
 		}
-		limit := new(big.Int)
-		limit.SetString(limitStr[2:], 16)
 		value, ok := args[3].(*big.Int)
 		if !ok {
 			return nil, fmt.Errorf("Expected *big.Int for value Got type %T", res)
@@ -585,16 +581,11 @@ func (a *api) CallBTS(method chain.ContractCallMethodName, args []interface{}) (
 		} else if res == nil {
 			return nil, errors.New("isOwner result is nil")
 		}
-		resStr, ok := res.(string)
-		if !ok {
-			return nil, fmt.Errorf("isOwner Response Expected string Got %T", res)
-		}
-		if resStr == "0x0" {
-			response = false
-		} else {
-			response = true
+		response, err = icon.DecodeCallBool(res)
+		if err != nil {
+			return nil, errors.Wrapf(err, "isOwner %v", err)
 		}
-		return response, err
+		return response, nil
 	} else {
 		response = nil
 		err = fmt.Errorf("method %v not supported", method)
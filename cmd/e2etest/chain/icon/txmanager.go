@@ -0,0 +1,105 @@
+package icon
+
+import (
+	"sync"
+	"time"
+
+	gocrypto "github.com/icon-project/goloop/common/crypto"
+	"github.com/icon-project/goloop/module"
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain/icon"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
+	"github.com/pkg/errors"
+)
+
+const defaultTxManagerSendRetry = 10
+
+// txManager serializes the transactions sent from the same address, so that
+// several transfers can be submitted in parallel without two of them being
+// signed with the same timestamp and rejected as duplicates.
+type txManager struct {
+	cl            *icon.Client
+	retryInterval time.Duration
+	mtx           sync.Mutex
+	senders       map[icon.Address]*txSender
+}
+
+type txSender struct {
+	sync.Mutex
+	timestamp int64 // last timestamp used by this sender
+}
+
+func newTxManager(cl *icon.Client) *txManager {
+	return &txManager{
+		cl:            cl,
+		retryInterval: DefaultSendTransactionRetryInterval,
+		senders:       make(map[icon.Address]*txSender),
+	}
+}
+
+func (m *txManager) sender(addr icon.Address) *txSender {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	s, ok := m.senders[addr]
+	if !ok {
+		s = &txSender{}
+		m.senders[addr] = s
+	}
+	return s
+}
+
+// Send stamps param with a timestamp unique to its sender, signs it with w and
+// sends it. It re-sends while the transaction pool is full, re-signs expired
+// transactions and treats a duplicate transaction as already sent.
+func (m *txManager) Send(w module.Wallet, param *icon.TransactionParam) (*icon.HexBytes, error) {
+	s := m.sender(param.FromAddress)
+	s.Lock()
+	defer s.Unlock()
+
+	for retry := 0; ; retry++ {
+		ts := time.Now().UnixNano() / int64(time.Microsecond)
+		if ts <= s.timestamp {
+			ts = s.timestamp + 1
+		}
+		s.timestamp = ts
+		param.Timestamp = icon.NewHexInt(ts)
+		if err := SignTransactionParam(w, param); err != nil {
+			return nil, errors.Wrap(err, "SignTransactionParam ")
+		}
+		txh, err := m.cl.SendTransaction(param)
+		if err == nil {
+			return txh, nil
+		}
+		je, ok := err.(*jsonrpc.Error)
+		if !ok || retry >= defaultTxManagerSendRetry {
+			return nil, err
+		}
+		switch je.Code {
+		case icon.JsonrpcErrorCodeTxPoolOverflow:
+			time.Sleep(m.retryInterval)
+			continue
+		case icon.JsonrpcErrorCodeSystem:
+			if subEc, ok := icon.SystemErrorCodeOf(je); ok {
+				switch subEc {
+				case icon.DuplicateTransactionError:
+					return transactionHash(param)
+				case icon.TransactionPoolOverflowError:
+					time.Sleep(m.retryInterval)
+					continue
+				case icon.ExpiredTransactionError:
+					continue
+				}
+			}
+		}
+		return nil, err
+	}
+}
+
+// transactionHash returns the hash of the signed transaction param.
+func transactionHash(param *icon.TransactionParam) (*icon.HexBytes, error) {
+	bs, err := serializeTransactionParam(param)
+	if err != nil {
+		return nil, err
+	}
+	txh := icon.NewHexBytes(gocrypto.SHA3Sum256(bs))
+	return &txh, nil
+}
@@ -0,0 +1,107 @@
+package icon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/icon-project/goloop/common/wallet"
+	"github.com/icon-project/goloop/module"
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain/icon"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTxManager(t *testing.T, handle func(p *icon.TransactionParam) (string, error)) *txManager {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64                  `json:"id"`
+			Params *icon.TransactionParam `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		if res, err := handle(req.Params); err != nil {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":-31000,"message":%q}}`, req.ID, err.Error())
+		} else {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":%q}`, req.ID, res)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	m := newTxManager(icon.NewClient(srv.URL, log.New()))
+	m.retryInterval = 0
+	return m
+}
+
+func newTestTxParam(w module.Wallet) *icon.TransactionParam {
+	return &icon.TransactionParam{
+		Version:     icon.NewHexInt(icon.JsonrpcApiVersion),
+		FromAddress: icon.Address(w.Address().String()),
+		ToAddress:   icon.Address("hx0000000000000000000000000000000000000001"),
+		Value:       icon.NewHexInt(1),
+		StepLimit:   icon.NewHexInt(100000),
+		NetworkID:   icon.NewHexInt(1),
+	}
+}
+
+func TestTxManagerUniqueTimestamps(t *testing.T) {
+	var mtx sync.Mutex
+	seen := make(map[icon.HexInt]bool)
+	m := newTestTxManager(t, func(p *icon.TransactionParam) (string, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if seen[p.Timestamp] {
+			return "", fmt.Errorf("E2000:duplicate transaction")
+		}
+		seen[p.Timestamp] = true
+		txh, err := transactionHash(p)
+		if err != nil {
+			return "", err
+		}
+		return string(*txh), nil
+	})
+
+	w := wallet.New()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Send(w, newTestTxParam(w))
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Len(t, seen, n)
+}
+
+func TestTxManagerDuplicate(t *testing.T) {
+	m := newTestTxManager(t, func(p *icon.TransactionParam) (string, error) {
+		return "", fmt.Errorf("E2000:duplicate transaction")
+	})
+	w := wallet.New()
+	p := newTestTxParam(w)
+	txh, err := m.Send(w, p)
+	require.NoError(t, err)
+	expected, err := transactionHash(p)
+	require.NoError(t, err)
+	require.Equal(t, *expected, *txh)
+}
+
+func TestTxManagerRetryOverflow(t *testing.T) {
+	calls := 0
+	m := newTestTxManager(t, func(p *icon.TransactionParam) (string, error) {
+		if calls++; calls < 3 {
+			return "", fmt.Errorf("E2001:transaction pool overflow")
+		}
+		return "0x01", nil
+	})
+	w := wallet.New()
+	txh, err := m.Send(w, newTestTxParam(w))
+	require.NoError(t, err)
+	require.Equal(t, icon.HexBytes("0x01"), *txh)
+	require.Equal(t, 3, calls)
+}
@@ -0,0 +1,610 @@
+package executor
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/icon-project/icon-bridge/cmd/e2etest/chain"
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain/icon"
+	"github.com/icon-project/icon-bridge/common/crypto"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/icon-project/icon-bridge/common/wallet"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeNodeAPIPath = "/api/v3"
+
+type fakeRPCHandler func(params json.RawMessage) (interface{}, error)
+
+// fakeNode is an in-memory ICON node serving canned JSON-RPC responses
+// and replaying a scripted sequence of BlockNotifications on /block.
+type fakeNode struct {
+	srv      *httptest.Server
+	mtx      sync.RWMutex
+	handlers map[string]fakeRPCHandler
+	blocks   []*icon.BlockNotification
+	added    chan struct{} // closed and replaced by AddBlocks
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	fn := &fakeNode{
+		handlers: make(map[string]fakeRPCHandler),
+		added:    make(chan struct{}),
+	}
+	fn.srv = httptest.NewServer(http.HandlerFunc(fn.serveHTTP))
+	t.Cleanup(fn.srv.Close)
+	return fn
+}
+
+// Endpoint returns the JSON-RPC endpoint to be passed to icon.NewClient.
+func (fn *fakeNode) Endpoint() string {
+	return fn.srv.URL + fakeNodeAPIPath
+}
+
+func (fn *fakeNode) Handle(method string, h fakeRPCHandler) {
+	fn.mtx.Lock()
+	defer fn.mtx.Unlock()
+	fn.handlers[method] = h
+}
+
+// AddBlocks appends notifications replayed to block monitors whose
+// requested height is less than or equal to the notification height,
+// including the monitors already connected.
+func (fn *fakeNode) AddBlocks(bns ...*icon.BlockNotification) {
+	fn.mtx.Lock()
+	defer fn.mtx.Unlock()
+	fn.blocks = append(fn.blocks, bns...)
+	close(fn.added)
+	fn.added = make(chan struct{})
+}
+
+func (fn *fakeNode) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) && strings.HasSuffix(r.URL.Path, "/block") {
+		fn.serveBlock(w, r)
+		return
+	}
+	var req struct {
+		ID     int64           `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	fn.mtx.RLock()
+	h, ok := fn.handlers[req.Method]
+	fn.mtx.RUnlock()
+	if !ok {
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+	} else if result, err := h(req.Params); err != nil {
+		resp["error"] = map[string]interface{}{"code": int(icon.JsonrpcErrorCodeSystem), "message": err.Error()}
+	} else {
+		resp["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (fn *fakeNode) serveBlock(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var req icon.BlockRequest
+	if err := conn.ReadJSON(&req); err != nil {
+		return
+	}
+	height, err := req.Height.Value()
+	if err != nil {
+		conn.WriteJSON(&icon.WSResponse{Code: -1, Message: err.Error()})
+		return
+	}
+	if err := conn.WriteJSON(&icon.WSResponse{}); err != nil {
+		return
+	}
+	// keep the connection open until the client closes it
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	for sent := 0; ; {
+		fn.mtx.RLock()
+		blocks, added := fn.blocks[sent:], fn.added
+		fn.mtx.RUnlock()
+		for _, bn := range blocks {
+			if h, _ := bn.Height.Value(); h < height {
+				continue
+			}
+			if err := conn.WriteJSON(bn); err != nil {
+				return
+			}
+		}
+		sent += len(blocks)
+		select {
+		case <-added:
+		case <-closed:
+			return
+		}
+	}
+}
+
+// fakeChainAPI is a chain.ChainAPI whose Subscribe replays scripted events.
+// Methods other than Subscribe are not implemented.
+type fakeChainAPI struct {
+	chain.ChainAPI
+	sinkChan chan *chain.EventLogInfo
+	errChan  chan error
+}
+
+func newFakeChainAPI() *fakeChainAPI {
+	return &fakeChainAPI{
+		sinkChan: make(chan *chain.EventLogInfo),
+		errChan:  make(chan error),
+	}
+}
+
+func (f *fakeChainAPI) Subscribe(ctx context.Context) (chan *chain.EventLogInfo, chan error, error) {
+	return f.sinkChan, f.errChan, nil
+}
+
+const (
+	transferStartSig = "TransferStart(Address,str,int,bytes)"
+	transferEndSig   = "TransferEnd(Address,int,int,bytes)"
+)
+
+// fakeBTS executes the native coin transfers sent to the BTS of a fakeNode,
+// each in a block of its own emitting TransferStart. The TransferEnd of a
+// transfer is emitted in a later block by End, as if relayed back from the
+// destination.
+type fakeBTS struct {
+	fn      *fakeNode
+	addr    icon.Address
+	mtx     sync.Mutex
+	height  int64
+	txs     map[int64]icon.HexBytes // by height
+	results map[icon.HexBytes]interface{}
+	senders map[int64]icon.Address // by sn
+}
+
+func newFakeBTS(fn *fakeNode, addr icon.Address) *fakeBTS {
+	b := &fakeBTS{
+		fn:      fn,
+		addr:    addr,
+		txs:     make(map[int64]icon.HexBytes),
+		results: make(map[icon.HexBytes]interface{}),
+		senders: make(map[int64]icon.Address),
+	}
+	fn.Handle("icx_getLastBlock", func(json.RawMessage) (interface{}, error) {
+		b.mtx.Lock()
+		defer b.mtx.Unlock()
+		return &icon.Block{Height: b.height}, nil
+	})
+	fn.Handle("icx_getBlockByHeight", b.getBlockByHeight)
+	fn.Handle("icx_getTransactionResult", b.getTransactionResult)
+	fn.Handle("icx_sendTransaction", b.sendTransaction)
+	return b
+}
+
+func (b *fakeBTS) sendTransaction(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		From  icon.Address `json:"from"`
+		To    icon.Address `json:"to"`
+		Value icon.HexInt  `json:"value"`
+		Data  struct {
+			Method string `json:"method"`
+			Params struct {
+				To string `json:"_to"`
+			} `json:"params"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.To != b.addr || p.Data.Method != "transferNativeCoin" {
+		return nil, fmt.Errorf("unsupported transaction: to=%v, method=%v", p.To, p.Data.Method)
+	}
+	value, err := p.Value.BigInt()
+	if err != nil {
+		return nil, err
+	}
+	assets, err := json.Marshal([]chain.AssetTransferDetails{{Name: "ICX", Value: value, Fee: big.NewInt(0)}})
+	if err != nil {
+		return nil, err
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	sn := int64(len(b.senders) + 1)
+	b.senders[sn] = p.From
+	return b.execute(
+		[]string{transferStartSig, string(p.From)},
+		[]string{p.Data.Params.To, string(icon.NewHexInt(sn)), string(icon.NewHexBytes(assets))},
+	), nil
+}
+
+// End emits the TransferEnd of the transfer sn with code.
+func (b *fakeBTS) End(sn, code int64, msg string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.execute(
+		[]string{transferEndSig, string(b.senders[sn])},
+		[]string{string(icon.NewHexInt(sn)), string(icon.NewHexInt(code)), string(icon.NewHexBytes([]byte(msg)))},
+	)
+}
+
+// execute adds a block with a transaction emitting the event, returning the
+// hash of the transaction.
+func (b *fakeBTS) execute(indexed, data []string) icon.HexBytes {
+	b.height++
+	hash := icon.NewHexBytes([]byte(fmt.Sprintf("tx-%d", b.height)))
+	b.txs[b.height] = hash
+	b.results[hash] = map[string]interface{}{
+		"status":      "0x1",
+		"txHash":      hash,
+		"txIndex":     "0x0",
+		"blockHeight": icon.NewHexInt(b.height),
+		"eventLogs": []map[string]interface{}{
+			{"scoreAddress": b.addr, "indexed": indexed, "data": data},
+		},
+	}
+	b.fn.AddBlocks(&icon.BlockNotification{
+		Hash:   icon.NewHexBytes([]byte(fmt.Sprintf("block-%d", b.height))),
+		Height: icon.NewHexInt(b.height),
+	})
+	return hash
+}
+
+func (b *fakeBTS) getBlockByHeight(params json.RawMessage) (interface{}, error) {
+	var p icon.BlockHeightParam
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	height, err := p.Height.Value()
+	if err != nil {
+		return nil, err
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	hash, ok := b.txs[height]
+	if !ok {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return map[string]interface{}{
+		"height":                     height,
+		"confirmed_transaction_list": []map[string]interface{}{{"txHash": hash}},
+	}, nil
+}
+
+func (b *fakeBTS) getTransactionResult(params json.RawMessage) (interface{}, error) {
+	var p icon.TransactionHashParam
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	res, ok := b.results[p.Hash]
+	if !ok {
+		return nil, fmt.Errorf("no transaction %v", p.Hash)
+	}
+	return res, nil
+}
+
+// parseFakeEventLog parses the events of a fakeBTS, nil for others.
+func parseFakeEventLog(indexed, data []string) *chain.EventLogInfo {
+	if len(indexed) != 2 || len(data) != 3 {
+		return nil
+	}
+	switch indexed[0] {
+	case transferStartSig:
+		sn, ok := new(big.Int).SetString(data[1], 0)
+		b, err := icon.HexBytes(data[2]).Value()
+		var assets []chain.AssetTransferDetails
+		if !ok || err != nil || json.Unmarshal(b, &assets) != nil {
+			return nil
+		}
+		return &chain.EventLogInfo{EventType: chain.TransferStart, EventLog: &chain.TransferStartEvent{
+			From: indexed[1], To: data[0], Sn: sn, Assets: assets,
+		}}
+	case transferEndSig:
+		sn, snOk := new(big.Int).SetString(data[0], 0)
+		code, codeOk := new(big.Int).SetString(data[1], 0)
+		msg, err := icon.HexBytes(data[2]).Value()
+		if !snOk || !codeOk || err != nil {
+			return nil
+		}
+		return &chain.EventLogInfo{EventType: chain.TransferEnd, EventLog: &chain.TransferEndEvent{
+			From: indexed[1], Sn: sn, Code: code, Response: string(msg),
+		}}
+	}
+	return nil
+}
+
+// fakeNodeAPI is a chain.ChainAPI on a fakeNode with a fakeBTS, sending
+// transfers and reading their events through an icon.Client, for scripts to
+// run end-to-end. Methods not used by the scripts run are not implemented.
+type fakeNodeAPI struct {
+	chain.ChainAPI
+	cl      *icon.Client
+	network string
+	bts     icon.Address
+	mtx     sync.Mutex
+	watched map[int64]uint64 // ids watching the TransferEnd, by sn
+	watch   chan int64       // sn of the TransferEnd watched
+}
+
+func newFakeNodeAPI(fn *fakeNode, network string, bts icon.Address) *fakeNodeAPI {
+	return &fakeNodeAPI{
+		cl:      icon.NewClient(fn.Endpoint(), log.New()),
+		network: network,
+		bts:     bts,
+		watched: make(map[int64]uint64),
+		watch:   make(chan int64, 1),
+	}
+}
+
+func (a *fakeNodeAPI) NativeCoin() string {
+	return "ICX"
+}
+
+func (a *fakeNodeAPI) GetBTPAddress(addr string) string {
+	return "btp://" + a.network + "/" + addr
+}
+
+func (a *fakeNodeAPI) Transfer(coinName, senderKey, recepientAddress string, amount *big.Int) (string, error) {
+	if coinName != a.NativeCoin() {
+		return "", fmt.Errorf("unsupported coin %v", coinName)
+	}
+	b, err := hex.DecodeString(senderKey)
+	if err != nil {
+		return "", err
+	}
+	sk, err := crypto.ParsePrivateKey(b)
+	if err != nil {
+		return "", err
+	}
+	w, err := wallet.NewIcxWalletFromPrivateKey(sk)
+	if err != nil {
+		return "", err
+	}
+	p, err := icon.NewTransactionBuilder().
+		From(icon.Address(w.Address())).
+		To(a.bts).
+		Value(amount).
+		StepLimit(1000000).
+		NetworkID(icon.NewHexInt(1)).
+		CallMethod("transferNativeCoin").
+		Params(map[string]interface{}{"_to": recepientAddress}).
+		Build()
+	if err != nil {
+		return "", err
+	}
+	if err := a.cl.SignTransaction(w, p); err != nil {
+		return "", err
+	}
+	hash, err := a.cl.SendTransaction(p)
+	if err != nil {
+		return "", err
+	}
+	return string(*hash), nil
+}
+
+func (a *fakeNodeAPI) WaitForTxnResult(ctx context.Context, hash string) (*chain.TxnResult, error) {
+	_, res, err := a.cl.WaitForResults(ctx, &icon.TransactionHashParam{Hash: icon.HexBytes(hash)})
+	if err != nil {
+		return nil, err
+	}
+	status, err := res.Status.Value()
+	if err != nil {
+		return nil, err
+	}
+	els := []*chain.EventLogInfo{}
+	for _, el := range res.EventLogs {
+		if info := parseFakeEventLog(el.Indexed, el.Data); info != nil {
+			els = append(els, info)
+		}
+	}
+	return &chain.TxnResult{StatusCode: int(status), ElInfo: els, Raw: res}, nil
+}
+
+func (a *fakeNodeAPI) WatchForTransferEnd(id uint64, seq int64) error {
+	a.mtx.Lock()
+	a.watched[seq] = id
+	a.mtx.Unlock()
+	a.watch <- seq
+	return nil
+}
+
+// Subscribe sends the TransferEnd events watched, from the first block.
+func (a *fakeNodeAPI) Subscribe(ctx context.Context) (chan *chain.EventLogInfo, chan error, error) {
+	sinkChan, errChan := make(chan *chain.EventLogInfo), make(chan error, 1)
+	go func() {
+		errChan <- a.cl.MonitorBlock(ctx, &icon.BlockRequest{Height: icon.NewHexInt(1)},
+			func(conn *websocket.Conn, bn *icon.BlockNotification) error {
+				blk, err := a.cl.GetBlockByHeight(&icon.BlockHeightParam{Height: bn.Height})
+				if err != nil {
+					return err
+				}
+				for _, tx := range blk.NormalTransactions {
+					res, err := a.cl.GetTransactionResult(&icon.TransactionHashParam{Hash: tx.TxHash})
+					if err != nil {
+						return err
+					}
+					for _, el := range res.EventLogs {
+						info := parseFakeEventLog(el.Indexed, el.Data)
+						if info == nil || info.EventType != chain.TransferEnd {
+							continue
+						}
+						a.mtx.Lock()
+						id, ok := a.watched[info.EventLog.(*chain.TransferEndEvent).Sn.Int64()]
+						a.mtx.Unlock()
+						if !ok {
+							continue
+						}
+						info.IDs = []uint64{id}
+						select {
+						case sinkChan <- info:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+				}
+				return nil
+			},
+			func(conn *websocket.Conn) {},
+			func(conn *websocket.Conn, err error) {})
+	}()
+	return sinkChan, errChan, nil
+}
+
+func TestFakeNodeMonitorBlock(t *testing.T) {
+	fn := newFakeNode(t)
+	for h := int64(1); h <= 5; h++ {
+		fn.AddBlocks(&icon.BlockNotification{
+			Hash:   icon.NewHexBytes([]byte(fmt.Sprintf("block-%d", h))),
+			Height: icon.NewHexInt(h),
+		})
+	}
+	fn.Handle("icx_getLastBlock", func(params json.RawMessage) (interface{}, error) {
+		return &icon.Block{Height: 5}, nil
+	})
+
+	cl := icon.NewClient(fn.Endpoint(), log.New())
+	blk, err := cl.GetLastBlock()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), blk.Height)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []int64
+	err = cl.MonitorBlock(ctx, &icon.BlockRequest{Height: icon.NewHexInt(3)},
+		func(conn *websocket.Conn, v *icon.BlockNotification) error {
+			h, err := v.Height.Value()
+			require.NoError(t, err)
+			if got = append(got, h); h == blk.Height {
+				cancel()
+			}
+			return nil
+		},
+		func(conn *websocket.Conn) {},
+		func(conn *websocket.Conn, err error) {})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int64{3, 4, 5}, got)
+}
+
+func TestSubscribeRoutesEvtByID(t *testing.T) {
+	src, dst := newFakeChainAPI(), newFakeChainAPI()
+	ex := &executor{
+		log: log.New(),
+		clientsPerChain: map[chain.ChainType]chain.ChainAPI{
+			chain.ICON: src,
+			chain.BSC:  dst,
+		},
+		sinkChanPerID: make(map[uint64]chan *evt),
+		stoppedChan:   make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ex.Subscribe(ctx)
+
+	const id = 42
+	sinkChan := make(chan *evt)
+	require.NoError(t, ex.addChan(id, sinkChan))
+	defer ex.removeChan(id)
+
+	go func() {
+		src.sinkChan <- &chain.EventLogInfo{IDs: []uint64{id + 1}, EventType: chain.TransferStart}
+		dst.sinkChan <- &chain.EventLogInfo{IDs: []uint64{id}, EventType: chain.TransferReceived}
+		src.sinkChan <- &chain.EventLogInfo{IDs: []uint64{id}, EventType: chain.TransferEnd}
+	}()
+
+	for _, want := range []struct {
+		chainType chain.ChainType
+		eventType chain.EventLogType
+	}{
+		{chain.BSC, chain.TransferReceived},
+		{chain.ICON, chain.TransferEnd},
+	} {
+		select {
+		case ev := <-sinkChan:
+			require.Equal(t, want.chainType, ev.chainType)
+			require.Equal(t, want.eventType, ev.msg.EventType)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %v", want.eventType)
+		}
+	}
+}
+
+func TestScriptOnFakeNode(t *testing.T) {
+	fn := newFakeNode(t)
+	bts := newFakeBTS(fn, "cx0000000000000000000000000000000000000b75")
+	src := newFakeNodeAPI(fn, "0x1.icon", bts.addr)
+	dst := newFakeNodeAPI(newFakeNode(t), "0x61.bsc", "")
+	ex := &executor{
+		log:             log.New(),
+		clientsPerChain: map[chain.ChainType]chain.ChainAPI{chain.ICON: src, chain.BSC: dst},
+		sinkChanPerID:   make(map[uint64]chan *evt),
+		stoppedChan:     make(chan struct{}),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ex.Subscribe(ctx)
+
+	const id = 7
+	sinkChan := make(chan *evt)
+	require.NoError(t, ex.addChan(id, sinkChan))
+	defer ex.removeChan(id)
+
+	// the destination fails the transfer once the script watches for it
+	go func() {
+		select {
+		case sn := <-src.watch:
+			bts.End(sn, 1, "unparseable address")
+		case <-ctx.Done():
+		}
+	}()
+
+	godKey := func() keypair {
+		sk, _ := crypto.GenerateKeyPair()
+		w, err := wallet.NewIcxWalletFromPrivateKey(sk)
+		require.NoError(t, err)
+		return keypair{PrivKey: hex.EncodeToString(sk.Bytes()), PubKey: w.Address()}
+	}
+	ts := &testSuite{
+		id:               id,
+		logger:           log.New(),
+		env:              "testnet", // transfer from the god wallets, not funding others
+		subChan:          sinkChan,
+		clsPerChain:      ex.clientsPerChain,
+		godKeysPerChain:  map[chain.ChainType]keypair{chain.ICON: godKey(), chain.BSC: godKey()},
+		gasLimitPerChain: map[chain.ChainType]int64{chain.ICON: 1},
+		fee:              fee{numerator: big.NewInt(FEE_NUMERATOR), denominator: big.NewInt(FEE_DENOMINATOR), fixed: big.NewInt(FIXED_PRICE)},
+		eventTimeout:     10 * time.Second,
+	}
+	rec, err := TransferToUnparseableAddress.Callback(ctx, chain.ICON, chain.BSC, []string{"ICX"}, ts)
+	require.NoError(t, err)
+	require.NotNil(t, rec.startEvent)
+	require.Equal(t, "1", rec.endEvent.Code.String())
+	// the fake BTS charges no fee
+	require.Len(t, rec.fees, 1)
+	require.Equal(t, "ICX", rec.fees[0].coinName)
+	require.Zero(t, rec.fees[0].observed.Sign())
+	require.False(t, rec.fees[0].matches())
+	require.Equal(t, int64(2), bts.height, "transfer started and ended")
+}
@@ -81,7 +81,8 @@ var TransferToUnparseableAddress Script = Script{
 			}
 			return nil, errors.Wrapf(err, "ValidateTransactionResultAndEvents Unexpected error %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
 			chain.TransferEnd: func(ev *evt) error {
 				if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
 					return errors.New("Got nil value for event ")
@@ -99,7 +100,7 @@ var TransferToUnparseableAddress Script = Script{
 		if err != nil {
 			return nil, errors.Wrapf(err, "WaitForEvents %v", err)
 		}
-		return nil, err
+		return rec, nil
 	},
 }
 
@@ -184,7 +185,8 @@ var TransferToZeroAddress Script = Script{
 			}
 			return nil, errors.Wrapf(err, "ValidateTransactionResultAndEvents Unexpected error %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
 			chain.TransferEnd: func(ev *evt) error {
 				if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
 					return errors.New("Got nil value for event ")
@@ -202,7 +204,7 @@ var TransferToZeroAddress Script = Script{
 		if err != nil {
 			return nil, errors.Wrapf(err, "WaitForEvents %v", err)
 		}
-		return nil, err
+		return rec, nil
 	},
 }
 
@@ -373,7 +375,8 @@ var TransferExceedingBTSBalance Script = Script{
 		if err := ts.ValidateTransactionResultAndEvents(ctx, hash, []string{coinName}, srcAddr, dstAddr, []*big.Int{amt}); err != nil {
 			return nil, errors.Wrapf(err, "ValidateTransactionResultEvents %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
 			// chain.TransferReceived: func(e *evt) error {
 			// 	ts.logger.Info("Got TransferReceived")
 			// 	return nil
@@ -402,7 +405,7 @@ var TransferExceedingBTSBalance Script = Script{
 		// if finalBtsBalance.UserBalance.Cmp(btsBalance.UserBalance) != 0 {
 		// 	return fmt.Errorf("BTS Balance should have been same since txn does not succeed. Init %v  Final %v", btsBalance.UserBalance.String(), finalBtsBalance.UserBalance.String())
 		// }
-		return nil, nil
+		return rec, nil
 	},
 }
 
@@ -482,7 +485,8 @@ var TransferAllBTSBalance Script = Script{
 		if err := ts.ValidateTransactionResultAndEvents(ctx, hash, []string{coinName}, srcAddr, dstAddr, []*big.Int{amt}); err != nil {
 			return nil, errors.Wrapf(err, "ValidateTransactionResultEvents %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
 			chain.TransferReceived: nil,
 			chain.TransferEnd: func(e *evt) error {
 				endEvt, ok := e.msg.EventLog.(*chain.TransferEndEvent)
@@ -505,7 +509,7 @@ var TransferAllBTSBalance Script = Script{
 		// if finalBtsBalance.UserBalance.Cmp(btsBalance.UserBalance) != 0 {
 		// 	return fmt.Errorf("BTS Balance should have been same since txn does not succeed. Init %v  Final %v", btsBalance.UserBalance.String(), finalBtsBalance.UserBalance.String())
 		// }
-		return nil, nil
+		return rec, nil
 	},
 }
 
@@ -639,7 +643,17 @@ var TransferWithApprove Script = Script{
 		if err := ts.ValidateTransactionResultAndEvents(ctx, hash, coinNames, srcAddr, dstAddr, amts); err != nil {
 			return nil, errors.Wrapf(err, "ValidateTransactionResultAndEvents %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
+			chain.TransferStart: func(ev *evt) error {
+				if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
+					return errors.New("Got nil value for event ")
+				}
+				if _, ok := ev.msg.EventLog.(*chain.TransferStartEvent); !ok {
+					return fmt.Errorf("Expected *chain.TransferStartEvent. Got %T", ev.msg.EventLog)
+				}
+				return nil
+			},
 			chain.TransferReceived: nil,
 			chain.TransferEnd: func(ev *evt) error {
 				if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
@@ -676,7 +690,7 @@ var TransferWithApprove Script = Script{
 		// if finalSrcBalance.Usable.Cmp(initSrcBalance.Usable) != -1 {
 		// 	return fmt.Errorf("Balance Compare after Transfer; Src; final Balance should have been less than initial balance; Got Final %v Initial %v", finalSrcBalance.String(), initSrcBalance.String())
 		// }
-		return nil, nil
+		return rec, nil
 	},
 }
 
@@ -821,7 +835,8 @@ var TransferEqualToFee Script = Script{
 			}
 			return nil, errors.Wrapf(err, "ValidateTransactionResultAndEvents Unexpected error %v", err)
 		}
-		err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
+		rec := &txnRecord{}
+		err = ts.WaitForEvents(ctx, hash, rec, map[chain.EventLogType]func(*evt) error{
 			chain.TransferEnd: func(ev *evt) error {
 				if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
 					return errors.New("Got nil value for event ")
@@ -839,6 +854,6 @@ var TransferEqualToFee Script = Script{
 		if err != nil {
 			return nil, errors.Wrapf(err, "WaitForEvents %v", err)
 		}
-		return nil, err
+		return rec, nil
 	},
 }
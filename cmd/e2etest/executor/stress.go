@@ -193,29 +193,8 @@ func stressTransferInterChain(
 		return
 	}
 	//ts.logger.Info("WaitForEvents Now")
-	err = ts.WaitForEvents(ctx, hash, map[chain.EventLogType]func(*evt) error{
-		chain.TransferStart: func(ev *evt) error {
-			if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
-				return errors.New("Got nil value for event ")
-			}
-			startEvt, ok := ev.msg.EventLog.(*chain.TransferStartEvent)
-			if !ok {
-				return fmt.Errorf("Expected *chain.TransferStartEvent Got %T", ev.msg.EventLog)
-			}
-			response.startEvent = startEvt
-			return nil
-		},
-		chain.TransferEnd: func(ev *evt) error {
-			if ev == nil || (ev != nil && ev.msg == nil) || (ev != nil && ev.msg != nil && ev.msg.EventLog == nil) {
-				return errors.New("Got nil value for event ")
-			}
-			endEvt, ok := ev.msg.EventLog.(*chain.TransferEndEvent)
-			if !ok {
-				return fmt.Errorf("Expected *chain.TransferEndEvent Got %T", ev.msg.EventLog)
-			}
-			response.endEvent = endEvt
-			return nil
-		},
+	err = ts.WaitForEvents(ctx, hash, response, map[chain.EventLogType]func(*evt) error{
+		chain.TransferEnd: nil,
 	})
 	if err != nil {
 		err = errors.Wrapf(err, "WaitForEvents %v", err)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,6 +28,7 @@ type testSuite struct {
 	dst                chain.ChainType
 	report             string
 	env                string
+	eventTimeout       time.Duration // of WaitForEvents, defaultEventTimeout if zero
 }
 
 func (ts *testSuite) GetChainPair(srcChain, dstChain chain.ChainType) (src chain.SrcAPI, dst chain.DstAPI, err error) {
@@ -242,7 +244,11 @@ func (ts *testSuite) ValidateTransactionResultAndEvents(ctx context.Context, has
 	return
 }
 
-func (ts *testSuite) WaitForEvents(ctx context.Context, hash string, cbPerEvent map[chain.EventLogType]func(event *evt) error) (err error) {
+// WaitForEvents waits for the events of cbPerEvent of the transfer sent in
+// the transaction hash, calling their callbacks, if not nil. rec, if not nil,
+// is populated with the TransferStart event and its fees, and with the
+// TransferEnd event if waited for.
+func (ts *testSuite) WaitForEvents(ctx context.Context, hash string, rec *txnRecord, cbPerEvent map[chain.EventLogType]func(event *evt) error) (err error) {
 	res, err := ts.ValidateTransactionResult(ctx, hash)
 	if err != nil {
 		return
@@ -257,7 +263,11 @@ func (ts *testSuite) WaitForEvents(ctx context.Context, hash string, cbPerEvent
 		if !tmpOk {
 			return fmt.Errorf("EventLog; Execpted *chain.TransferStartEvent. Got %T Hash %v", el.EventLog, hash)
 		}
-		if startCb, ok := cbPerEvent[chain.TransferStart]; ok {
+		if rec != nil {
+			rec.startEvent = startEvent
+			rec.recordFees(ts.fee, startEvent)
+		}
+		if startCb := cbPerEvent[chain.TransferStart]; startCb != nil {
 			if err := startCb(&evt{chainType: ts.src, msg: el}); err != nil {
 				return err
 				//ts.report += fmt.Sprintf("CallBackPerEvent %v Err:%v \n", "TransferStart", err)
@@ -280,7 +290,7 @@ func (ts *testSuite) WaitForEvents(ctx context.Context, hash string, cbPerEvent
 		err = fmt.Errorf("Client for chain %v not found", ts.dst)
 		return
 	}
-	numExpectedEvents := 0
+	pending := make(map[chain.EventLogType]bool)
 	for ev := range cbPerEvent {
 		if ev == chain.TransferStart {
 			// Trasfer Start event is not watched as it is premise for other watches and as such
@@ -289,44 +299,94 @@ func (ts *testSuite) WaitForEvents(ctx context.Context, hash string, cbPerEvent
 			if err := dstCl.WatchForTransferReceived(ts.id, startEvent.Sn.Int64()); err != nil {
 				return errors.Wrapf(err, "WatchForTransferStart Err=%v", err)
 			}
-			numExpectedEvents++
+			pending[ev] = true
 		} else if ev == chain.TransferEnd {
 			if err := srcCl.WatchForTransferEnd(ts.id, startEvent.Sn.Int64()); err != nil {
 				return errors.Wrapf(err, "WatchForTransferStart Err=%v", err)
 			}
-			numExpectedEvents++
+			pending[ev] = true
 		} else {
 			ts.report += fmt.Sprintf("Event %v not available. Skipping it.", ev)
 		}
 	}
-	// Listen to result from watchEvents
-	newCtx := context.Background()
-	timedContext, timedContextCancel := context.WithTimeout(newCtx, time.Second*120)
+	if rec != nil && pending[chain.TransferEnd] {
+		cbs := make(map[chain.EventLogType]func(event *evt) error, len(cbPerEvent))
+		for ev, cb := range cbPerEvent {
+			cbs[ev] = cb
+		}
+		endCb := cbPerEvent[chain.TransferEnd]
+		cbs[chain.TransferEnd] = func(ev *evt) error {
+			if endEvt, ok := ev.msg.EventLog.(*chain.TransferEndEvent); ok {
+				rec.endEvent = endEvt
+			}
+			if endCb != nil {
+				return endCb(ev)
+			}
+			return nil
+		}
+		cbPerEvent = cbs
+	}
+	return ts.waitForCorrelatedEvents(ctx, startEvent.Sn, pending, cbPerEvent)
+}
 
+// waitForCorrelatedEvents waits for the pending events of the transfer with
+// sequence number sn, calling their callbacks. Events of other transfers,
+// or from the wrong chain, are ignored. It fails with a MissingEventsError
+// if some are still pending after the event timeout.
+func (ts *testSuite) waitForCorrelatedEvents(ctx context.Context, sn *big.Int, pending map[chain.EventLogType]bool, cbPerEvent map[chain.EventLogType]func(event *evt) error) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	timeout := ts.eventTimeout
+	if timeout == 0 {
+		timeout = defaultEventTimeout
+	}
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 	for {
-		defer timedContextCancel()
 		select {
-		case <-timedContext.Done():
+		case <-timer.C:
 			ts.report += "Context Timeout Exiting task"
-			return errors.New("Context Timeout Exiting task----------------")
+			merr := &MissingEventsError{Sn: sn, Waited: time.Since(start)}
+			for ev := range pending {
+				merr.Missing = append(merr.Missing, ev)
+			}
+			sort.Slice(merr.Missing, func(i, j int) bool { return merr.Missing[i] < merr.Missing[j] })
+			return merr
 		case <-ctx.Done():
 			ts.report += "Context Cancelled. Return from Callback watch"
 			return errors.New("Context Cancelled. Return from Callback watch---------------")
 		case ev := <-ts.subChan:
-			if cb, ok := cbPerEvent[ev.msg.EventType]; ok {
-				numExpectedEvents--
-				if cb != nil {
-					if err := cb(ev); err != nil {
-						return err
-						ts.report += fmt.Sprintf("CallBackPerEvent %v Err:%v \n", ev.msg.EventType, err)
-					}
+			if !pending[ev.msg.EventType] || !ts.correlates(ev, sn) {
+				continue
+			}
+			delete(pending, ev.msg.EventType)
+			if cb := cbPerEvent[ev.msg.EventType]; cb != nil {
+				if err := cb(ev); err != nil {
+					return err
 				}
 			}
-			if numExpectedEvents == 0 {
+			if len(pending) == 0 {
 				ts.report += "All events found. Exiting \n"
-				return
+				return nil
 			}
 		}
 	}
-	return nil
+}
+
+// correlates returns whether ev belongs to the transfer with sequence number
+// sn: TransferReceived on the destination chain, TransferEnd on the source.
+func (ts *testSuite) correlates(ev *evt, sn *big.Int) bool {
+	var evSn *big.Int
+	var chainType chain.ChainType
+	switch el := ev.msg.EventLog.(type) {
+	case *chain.TransferReceivedEvent:
+		evSn, chainType = el.Sn, ts.dst
+	case *chain.TransferEndEvent:
+		evSn, chainType = el.Sn, ts.src
+	default:
+		return false
+	}
+	return ev.chainType == chainType && evSn != nil && sn != nil && evSn.Cmp(sn) == 0
 }
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/icon-project/icon-bridge/cmd/e2etest/chain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForCorrelatedEvents(t *testing.T) {
+	subChan := make(chan *evt, 4)
+	ts := &testSuite{src: chain.ICON, dst: chain.BSC, subChan: subChan, eventTimeout: 200 * time.Millisecond}
+	end := func(chainType chain.ChainType, sn int64) *evt {
+		return &evt{chainType: chainType, msg: &chain.EventLogInfo{
+			EventType: chain.TransferEnd,
+			EventLog:  &chain.TransferEndEvent{Sn: big.NewInt(sn)},
+		}}
+	}
+	received := func(chainType chain.ChainType, sn int64) *evt {
+		return &evt{chainType: chainType, msg: &chain.EventLogInfo{
+			EventType: chain.TransferReceived,
+			EventLog:  &chain.TransferReceivedEvent{Sn: big.NewInt(sn)},
+		}}
+	}
+
+	// events of other transfers or from the wrong chain are ignored
+	subChan <- end(chain.ICON, 6)
+	subChan <- end(chain.BSC, 5)
+	subChan <- received(chain.BSC, 5)
+	subChan <- end(chain.ICON, 5)
+	var got []*evt
+	cb := func(ev *evt) error {
+		got = append(got, ev)
+		return nil
+	}
+	err := ts.waitForCorrelatedEvents(context.Background(), big.NewInt(5),
+		map[chain.EventLogType]bool{chain.TransferReceived: true, chain.TransferEnd: true},
+		map[chain.EventLogType]func(*evt) error{chain.TransferReceived: cb, chain.TransferEnd: cb})
+	require.NoError(t, err)
+	require.Equal(t, []*evt{received(chain.BSC, 5), end(chain.ICON, 5)}, got)
+
+	subChan <- end(chain.BSC, 7)
+	err = ts.waitForCorrelatedEvents(context.Background(), big.NewInt(7),
+		map[chain.EventLogType]bool{chain.TransferEnd: true},
+		map[chain.EventLogType]func(*evt) error{chain.TransferEnd: cb})
+	var merr *MissingEventsError
+	require.True(t, errors.As(err, &merr), "error: %v", err)
+	require.Equal(t, big.NewInt(7), merr.Sn)
+	require.Equal(t, []chain.EventLogType{chain.TransferEnd}, merr.Missing)
+	require.GreaterOrEqual(t, merr.Waited, ts.eventTimeout)
+}
@@ -2,7 +2,9 @@ package executor
 
 import (
 	"context"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/icon-project/icon-bridge/cmd/e2etest/chain"
 	"github.com/icon-project/icon-bridge/common/errors"
@@ -33,10 +35,47 @@ type fee struct {
 	denominator *big.Int
 }
 
+// expectedFee returns the fee BTS charges for transferring amount,
+// i.e. amount * numerator / denominator + fixed
+func (f fee) expectedFee(amount *big.Int) *big.Int {
+	charge := new(big.Int).Mul(amount, f.numerator)
+	charge.Div(charge, f.denominator)
+	return charge.Add(charge, f.fixed)
+}
+
+type feeRecord struct {
+	coinName string
+	amount   *big.Int // total amount sent, value + fee
+	expected *big.Int // fee computed from the configured fee policy
+	observed *big.Int // fee reported on the TransferStart event
+}
+
+func (fr *feeRecord) matches() bool {
+	return fr.expected != nil && fr.observed != nil && fr.expected.Cmp(fr.observed) == 0
+}
+
 type txnRecord struct {
 	msg        string
 	startEvent *chain.TransferStartEvent
 	endEvent   *chain.TransferEndEvent
+	fees       []*feeRecord
+}
+
+// recordFees populates rec.fees from the assets of the TransferStart event
+func (rec *txnRecord) recordFees(f fee, startEvt *chain.TransferStartEvent) {
+	rec.fees = rec.fees[:0]
+	for _, as := range startEvt.Assets {
+		if as.Value == nil || as.Fee == nil {
+			continue
+		}
+		amount := new(big.Int).Add(as.Value, as.Fee)
+		rec.fees = append(rec.fees, &feeRecord{
+			coinName: as.Name,
+			amount:   amount,
+			expected: f.expectedFee(amount),
+			observed: new(big.Int).Set(as.Fee),
+		})
+	}
 }
 
 var (
@@ -44,6 +83,22 @@ var (
 	StatusCodeZero = errors.New("Got status code zero(failed)")
 )
 
+// defaultEventTimeout is the time WaitForEvents waits for the events of a
+// transfer after its TransferStart.
+const defaultEventTimeout = 120 * time.Second
+
+// MissingEventsError is returned by WaitForEvents when the events matching
+// a TransferStart by sequence number are not all received in time.
+type MissingEventsError struct {
+	Sn      *big.Int
+	Missing []chain.EventLogType
+	Waited  time.Duration
+}
+
+func (e *MissingEventsError) Error() string {
+	return fmt.Sprintf("Missing events %v of transfer Sn %v after %v", e.Missing, e.Sn, e.Waited)
+}
+
 type Config struct {
 	Env    string          `json:"env"`
 	Chains []*chain.Config `json:"chains"`
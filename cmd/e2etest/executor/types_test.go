@@ -0,0 +1,27 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/icon-project/icon-bridge/cmd/e2etest/chain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFees(t *testing.T) {
+	f := fee{numerator: big.NewInt(FEE_NUMERATOR), denominator: big.NewInt(FEE_DENOMINATOR), fixed: big.NewInt(FIXED_PRICE)}
+	// 1,000,000 * 100 / 10000 + 5000
+	require.Equal(t, big.NewInt(15000), f.expectedFee(big.NewInt(1000000)))
+
+	rec := &txnRecord{}
+	rec.recordFees(f, &chain.TransferStartEvent{
+		Assets: []chain.AssetTransferDetails{
+			{Name: "ICX", Value: big.NewInt(985000), Fee: big.NewInt(15000)},
+			{Name: "bnUSD", Value: big.NewInt(985001), Fee: big.NewInt(14999)},
+		},
+	})
+	require.Len(t, rec.fees, 2)
+	require.True(t, rec.fees[0].matches())
+	require.False(t, rec.fees[1].matches())
+	require.Equal(t, big.NewInt(15000), rec.fees[1].expected)
+}
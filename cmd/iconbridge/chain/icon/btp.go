@@ -0,0 +1,169 @@
+package icon
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/icon-project/goloop/common/codec"
+	"github.com/pkg/errors"
+)
+
+// The btp_ methods of the BTP 2.0 extension of the node serve the network
+// sections of the blocks and the messages of each BTP network, to be proved
+// with the proof of a network type instead of the event logs of the BMC.
+
+// BTPNetworkParam is the param of btp_getNetworkInfo and
+// btp_getNetworkTypeInfo. Height defaults to the last block.
+type BTPNetworkParam struct {
+	Height HexInt `json:"height,omitempty"`
+	ID     HexInt `json:"id"`
+}
+
+// BTPBlockParam is the param of btp_getHeader, btp_getMessages and
+// btp_getProof.
+type BTPBlockParam struct {
+	Height    HexInt `json:"height"`
+	NetworkID HexInt `json:"networkID"`
+}
+
+// BTPNetworkInfo is the result of btp_getNetworkInfo.
+type BTPNetworkInfo struct {
+	StartHeight             HexInt   `json:"startHeight"`
+	NetworkTypeID           HexInt   `json:"networkTypeID"`
+	NetworkTypeName         string   `json:"networkTypeName"`
+	NetworkID               HexInt   `json:"networkID"`
+	NetworkName             string   `json:"networkName"`
+	Open                    HexInt   `json:"open"`
+	Owner                   Address  `json:"owner"`
+	NextMessageSN           HexInt   `json:"nextMessageSN"`
+	NextProofContextChanged HexInt   `json:"nextProofContextChanged"`
+	PrevNSHash              HexBytes `json:"prevNSHash"`
+	LastNSHash              HexBytes `json:"lastNSHash"`
+}
+
+// BTPNetworkTypeInfo is the result of btp_getNetworkTypeInfo.
+type BTPNetworkTypeInfo struct {
+	NetworkTypeID    HexInt   `json:"networkTypeID"`
+	NetworkTypeName  string   `json:"networkTypeName"`
+	NextProofContext HexBytes `json:"nextProofContext"`
+	OpenNetworkIDs   []HexInt `json:"openNetworkIDs"`
+}
+
+// BTPSourceInfo is the result of btp_getSourceInformation.
+type BTPSourceInfo struct {
+	SrcNetworkUID  string   `json:"srcNetworkUID"`
+	NetworkTypeIDs []HexInt `json:"networkTypeIDs"`
+}
+
+// BTPMerkleNode is a node of the path from a network section to the root of
+// the network type sections, on the left of the path if Dir is 0.
+type BTPMerkleNode struct {
+	Dir   int
+	Value []byte
+}
+
+// BTPBlockHeader is the header of a BTP network in a block, as returned by
+// btp_getHeader.
+type BTPBlockHeader struct {
+	MainHeight             int64
+	Round                  int32
+	NextProofContextHash   []byte
+	NetworkSectionToRoot   []BTPMerkleNode
+	NetworkID              int64
+	UpdateNumber           int64 // FirstMessageSN<<1 | NextProofContextChanged
+	PrevNetworkSectionHash []byte
+	MessageCount           int64
+	MessagesRoot           []byte
+	NextProofContext       []byte
+}
+
+// FirstMessageSN returns the sequence number of the first message of the
+// network in the block.
+func (h *BTPBlockHeader) FirstMessageSN() int64 {
+	return h.UpdateNumber >> 1
+}
+
+// NextProofContextChanged returns whether the proof context, e.g. the
+// validators, of the network changes after the block.
+func (h *BTPBlockHeader) NextProofContextChanged() bool {
+	return h.UpdateNumber&1 == 1
+}
+
+// GetBTPNetworkInfo returns the state of the BTP network p.ID.
+func (c *Client) GetBTPNetworkInfo(ctx context.Context, p *BTPNetworkParam) (*BTPNetworkInfo, error) {
+	ni := &BTPNetworkInfo{}
+	if _, err := c.DoContext(ctx, c.method("btp_getNetworkInfo"), p, ni); err != nil {
+		return nil, err
+	}
+	return ni, nil
+}
+
+// GetBTPNetworkTypeInfo returns the state of the BTP network type p.ID.
+func (c *Client) GetBTPNetworkTypeInfo(ctx context.Context, p *BTPNetworkParam) (*BTPNetworkTypeInfo, error) {
+	nti := &BTPNetworkTypeInfo{}
+	if _, err := c.DoContext(ctx, c.method("btp_getNetworkTypeInfo"), p, nti); err != nil {
+		return nil, err
+	}
+	return nti, nil
+}
+
+// GetBTPSourceInfo returns the network address of the chain and the types
+// of its BTP networks.
+func (c *Client) GetBTPSourceInfo(ctx context.Context) (*BTPSourceInfo, error) {
+	si := &BTPSourceInfo{}
+	if _, err := c.DoContext(ctx, c.method("btp_getSourceInformation"), struct{}{}, si); err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// GetBTPHeader returns the header of the BTP network in the block, which
+// has one only if the network has messages or a new proof context in it.
+func (c *Client) GetBTPHeader(ctx context.Context, p *BTPBlockParam) (*BTPBlockHeader, error) {
+	var result string
+	if _, err := c.DoContext(ctx, c.method("btp_getHeader"), p, &result); err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid BTP header: %v", err)
+	}
+	h := &BTPBlockHeader{}
+	if _, err := codec.RLP.UnmarshalFromBytes(b, h); err != nil {
+		return nil, errors.Wrapf(err, "BTPBlockHeader.UnmarshalFromBytes: %v", err)
+	}
+	return h, nil
+}
+
+// GetBTPMessages returns the messages of the BTP network in the block, in
+// order from the FirstMessageSN of its header.
+func (c *Client) GetBTPMessages(ctx context.Context, p *BTPBlockParam) ([][]byte, error) {
+	var result []string
+	if _, err := c.DoContext(ctx, c.method("btp_getMessages"), p, &result); err != nil {
+		return nil, err
+	}
+	msgs := make([][]byte, 0, len(result))
+	for i, v := range result {
+		msg, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid BTP message: index=%d, %v", i, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// GetBTPProof returns the proof of the header of the BTP network in the
+// block, whose format depends on the network type, e.g. the signatures of
+// the validators.
+func (c *Client) GetBTPProof(ctx context.Context, p *BTPBlockParam) ([]byte, error) {
+	var result string
+	if _, err := c.DoContext(ctx, c.method("btp_getProof"), p, &result); err != nil {
+		return nil, err
+	}
+	proof, err := base64.StdEncoding.DecodeString(result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid BTP proof: %v", err)
+	}
+	return proof, nil
+}
@@ -0,0 +1,68 @@
+package icon
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/icon-project/goloop/common/codec"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientBTP(t *testing.T) {
+	header := &BTPBlockHeader{
+		MainHeight:           10,
+		Round:                1,
+		NetworkSectionToRoot: []BTPMerkleNode{{Dir: 1, Value: []byte("sibling")}},
+		NetworkID:            2,
+		UpdateNumber:         5<<1 | 1,
+		MessageCount:         2,
+		MessagesRoot:         []byte("root"),
+	}
+	encode := func(b []byte) string { return base64.StdEncoding.EncodeToString(b) }
+	var params []BTPBlockParam
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"btp_getNetworkInfo": func(json.RawMessage) interface{} {
+			return map[string]interface{}{
+				"networkID": "0x2", "networkTypeName": "eth", "nextMessageSN": "0x7",
+			}
+		},
+		"btp_getHeader": func(raw json.RawMessage) interface{} {
+			var p BTPBlockParam
+			require.NoError(t, json.Unmarshal(raw, &p))
+			params = append(params, p)
+			return encode(codec.RLP.MustMarshalToBytes(header))
+		},
+		"btp_getMessages": func(json.RawMessage) interface{} {
+			return []string{encode([]byte("msg5")), encode([]byte("msg6"))}
+		},
+		"btp_getProof": func(json.RawMessage) interface{} {
+			return &jsonrpc.Error{Code: JsonrpcErrorCodeNotFound, Message: "no proof"}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	ctx := context.Background()
+
+	ni, err := cl.GetBTPNetworkInfo(ctx, &BTPNetworkParam{ID: NewHexInt(2)})
+	require.NoError(t, err)
+	require.Equal(t, "eth", ni.NetworkTypeName)
+	require.Equal(t, NewHexInt(7), ni.NextMessageSN)
+
+	p := &BTPBlockParam{Height: NewHexInt(10), NetworkID: NewHexInt(2)}
+	h, err := cl.GetBTPHeader(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, header, h)
+	require.Equal(t, []BTPBlockParam{*p}, params)
+	require.Equal(t, int64(5), h.FirstMessageSN())
+	require.True(t, h.NextProofContextChanged())
+
+	msgs, err := cl.GetBTPMessages(ctx, p)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("msg5"), []byte("msg6")}, msgs)
+
+	_, err = cl.GetBTPProof(ctx, p)
+	require.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package icon
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// The DecodeCall functions decode the result of Client.Call into an
+// interface{}, in which ICON returns values as strings: integers and bools
+// in hex, bytes as 0x-prefixed hex and addresses with their hx or cx prefix.
+
+func callString(v interface{}, typ string) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expected %s, got %T", typ, v)
+	}
+	return s, nil
+}
+
+// DecodeCallBigInt decodes a call result of type int.
+func DecodeCallBigInt(v interface{}) (*big.Int, error) {
+	s, err := callString(v, "int")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "-0x") {
+		return nil, fmt.Errorf("invalid int %q", s)
+	}
+	n, err := HexInt(s).BigInt()
+	if err != nil {
+		return nil, fmt.Errorf("invalid int %q: %v", s, err)
+	}
+	return n, nil
+}
+
+// DecodeCallBool decodes a call result of type bool, "0x0" or "0x1".
+func DecodeCallBool(v interface{}) (bool, error) {
+	s, err := callString(v, "bool")
+	if err != nil {
+		return false, err
+	}
+	switch s {
+	case "0x0":
+		return false, nil
+	case "0x1":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid bool %q", s)
+	}
+}
+
+// DecodeCallString decodes a call result of type str.
+func DecodeCallString(v interface{}) (string, error) {
+	return callString(v, "str")
+}
+
+// DecodeCallAddress decodes a call result of type Address.
+func DecodeCallAddress(v interface{}) (Address, error) {
+	s, err := callString(v, "Address")
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateAddress(Address(s)); err != nil {
+		return "", err
+	}
+	return Address(s), nil
+}
+
+// DecodeCallBytes decodes a call result of type bytes.
+func DecodeCallBytes(v interface{}) ([]byte, error) {
+	s, err := callString(v, "bytes")
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("invalid bytes %q", s)
+	}
+	b, err := HexBytes(s).Value()
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytes %q: %v", s, err)
+	}
+	return b, nil
+}
+
+// CallResultField returns the field key of a call result of type dict, to
+// be decoded by the DecodeCall functions.
+func CallResultField(v interface{}, key string) (interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected dict, got %T", v)
+	}
+	f, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("missing field %q", key)
+	}
+	return f, nil
+}
@@ -0,0 +1,61 @@
+package icon
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCallResult(t *testing.T) {
+	n, err := DecodeCallBigInt("0x2e90edd00")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(12500000000), n)
+	n, err = DecodeCallBigInt("-0x10")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(-16), n)
+	for _, v := range []interface{}{"10", "0xzz", 10.0, nil} {
+		_, err := DecodeCallBigInt(v)
+		require.Error(t, err, "%v", v)
+	}
+
+	b, err := DecodeCallBool("0x1")
+	require.NoError(t, err)
+	require.True(t, b)
+	b, err = DecodeCallBool("0x0")
+	require.NoError(t, err)
+	require.False(t, b)
+	_, err = DecodeCallBool("0x2")
+	require.EqualError(t, err, `invalid bool "0x2"`)
+
+	s, err := DecodeCallString("ICX")
+	require.NoError(t, err)
+	require.Equal(t, "ICX", s)
+	_, err = DecodeCallString([]interface{}{"ICX"})
+	require.EqualError(t, err, "expected str, got []interface {}")
+
+	cx := "cx" + strings.Repeat("01", 20)
+	a, err := DecodeCallAddress(cx)
+	require.NoError(t, err)
+	require.Equal(t, Address(cx), a)
+	_, err = DecodeCallAddress("cx01")
+	require.Error(t, err)
+
+	bs, err := DecodeCallBytes("0x6d7367")
+	require.NoError(t, err)
+	require.Equal(t, []byte("msg"), bs)
+	_, err = DecodeCallBytes("6d7367")
+	require.Error(t, err)
+
+	res := map[string]interface{}{"usable": "0xa"}
+	f, err := CallResultField(res, "usable")
+	require.NoError(t, err)
+	n, err = DecodeCallBigInt(f)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(10), n)
+	_, err = CallResultField(res, "locked")
+	require.EqualError(t, err, `missing field "locked"`)
+	_, err = CallResultField("0xa", "usable")
+	require.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package icon
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/pkg/errors"
+)
+
+// CheckpointStore persists the progress of a subscription: the height of the
+// last block whose receipts were delivered and the sequence of the last event
+// delivered.
+type CheckpointStore interface {
+	// Load returns the last checkpoint saved, with a zero height if none.
+	Load() (height, seq uint64, err error)
+	Save(height, seq uint64) error
+}
+
+// MemoryCheckpointStore keeps the checkpoint in memory, e.g. to resume a
+// subscription restarted within the same process.
+type MemoryCheckpointStore struct {
+	mtx    sync.Mutex
+	height uint64
+	seq    uint64
+}
+
+func (s *MemoryCheckpointStore) Load() (uint64, uint64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.height, s.seq, nil
+}
+
+func (s *MemoryCheckpointStore) Save(height, seq uint64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.height, s.seq = height, seq
+	return nil
+}
+
+// FileCheckpointStore keeps the checkpoint in a JSON file, replaced on each
+// Save so that a crash leaves either the previous or the new checkpoint.
+type FileCheckpointStore struct {
+	path string
+}
+
+type fileCheckpoint struct {
+	Height uint64 `json:"height"`
+	Seq    uint64 `json:"seq"`
+}
+
+// NewFileCheckpointStore returns a store of the checkpoint in the file at
+// path, created by the first Save.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+func (s *FileCheckpointStore) Load() (uint64, uint64, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	var cp fileCheckpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid checkpoint: %v", err)
+	}
+	return cp.Height, cp.Seq, nil
+}
+
+func (s *FileCheckpointStore) Save(height, seq uint64) error {
+	b, err := json.Marshal(&fileCheckpoint{Height: height, Seq: seq})
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// SubscribeWithCheckpoint subscribes like Subscribe, resuming from the
+// checkpoint in store, or from opts if none was saved, and saves a new
+// checkpoint each time a message was delivered to msgCh. Failing to save
+// is logged, the subscription resuming from an older checkpoint then.
+func (r *receiver) SubscribeWithCheckpoint(
+	ctx context.Context, msgCh chan<- *chain.Message,
+	store CheckpointStore, opts chain.SubscribeOptions) (errCh <-chan error, err error) {
+
+	height, seq, err := store.Load()
+	if err != nil {
+		return nil, errors.Wrapf(err, "CheckpointStore.Load: %v", err)
+	}
+	if height > 0 {
+		r.log.WithFields(log.Fields{"height": height, "seq": seq}).Info("Subscribe: start from checkpoint")
+		opts = chain.SubscribeOptions{Height: height, Seq: seq}
+	}
+	seq = opts.Seq
+
+	subMsgCh := make(chan *chain.Message)
+	subErrCh, err := r.Subscribe(ctx, subMsgCh, opts)
+	if err != nil {
+		return nil, err
+	}
+	_errCh := make(chan error)
+	go func() {
+		defer close(_errCh)
+		// don't block the subscription reporting an error on cancellation
+		defer func() {
+			go func() {
+				for range subErrCh {
+				}
+			}()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-subErrCh:
+				if ok {
+					select {
+					case _errCh <- err:
+					case <-ctx.Done():
+					}
+				}
+				return
+			case msg := <-subMsgCh:
+				select {
+				case msgCh <- msg:
+				case <-ctx.Done():
+					return
+				}
+				for _, receipt := range msg.Receipts {
+					if n := len(receipt.Events); n > 0 {
+						seq = receipt.Events[n-1].Sequence
+					}
+				}
+				height := msg.Receipts[len(msg.Receipts)-1].Height
+				if err := store.Save(height, seq); err != nil {
+					r.log.WithFields(log.Fields{"height": height, "seq": seq, "error": err}).Warn("checkpoint: save failed")
+				}
+			}
+		}
+	}()
+	return _errCh, nil
+}
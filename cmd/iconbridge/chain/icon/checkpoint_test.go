@@ -0,0 +1,74 @@
+package icon
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	s := NewFileCheckpointStore(path)
+	height, seq, err := s.Load()
+	require.NoError(t, err)
+	require.Zero(t, height)
+	require.Zero(t, seq)
+
+	require.NoError(t, s.Save(10, 3))
+	require.NoError(t, s.Save(12, 5))
+	height, seq, err = NewFileCheckpointStore(path).Load()
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), height)
+	require.Equal(t, uint64(5), seq)
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, files, 1, "no temporary file left")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	_, _, err = s.Load()
+	require.Error(t, err)
+}
+
+func TestSubscribeWithCheckpoint(t *testing.T) {
+	r, _ := newTestNodeReceiver(t, [][]uint64{{1}, nil, {2, 3}, {4}}, ReceiverOptions{})
+	subscribe := func(store CheckpointStore, until uint64) []uint64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		msgCh := make(chan *chain.Message)
+		errCh, err := r.SubscribeWithCheckpoint(ctx, msgCh, store, chain.SubscribeOptions{Height: 1})
+		require.NoError(t, err)
+		var seqs []uint64
+		for len(seqs) == 0 || seqs[len(seqs)-1] < until {
+			select {
+			case err := <-errCh:
+				t.Fatalf("subscription failed: %v", err)
+			case msg := <-msgCh:
+				for _, receipt := range msg.Receipts {
+					for _, event := range receipt.Events {
+						seqs = append(seqs, event.Sequence)
+					}
+				}
+			}
+		}
+		cancel()
+		_, ok := <-errCh
+		require.False(t, ok)
+		return seqs
+	}
+
+	store := &MemoryCheckpointStore{}
+	require.Equal(t, []uint64{1, 2, 3, 4}, subscribe(store, 4))
+	height, seq, err := store.Load()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), height)
+	require.Equal(t, uint64(4), seq)
+
+	// resumes after the events of the block of the checkpoint delivered
+	require.NoError(t, store.Save(3, 2))
+	require.Equal(t, []uint64{3, 4}, subscribe(store, 4))
+}
@@ -19,16 +19,18 @@ package icon
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/big"
+	"net"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -36,6 +38,8 @@ import (
 
 	"github.com/icon-project/goloop/common"
 	"github.com/icon-project/goloop/common/codec"
+	gocrypto "github.com/icon-project/goloop/common/crypto"
+	bridgecommon "github.com/icon-project/icon-bridge/common"
 	"github.com/icon-project/icon-bridge/common/crypto"
 	"github.com/icon-project/icon-bridge/common/jsonrpc"
 	"github.com/icon-project/icon-bridge/common/log"
@@ -44,6 +48,12 @@ import (
 const (
 	DefaultSendTransactionRetryInterval        = 3 * time.Second         //3sec
 	DefaultGetTransactionResultPollingInterval = 1500 * time.Millisecond //1.5sec
+	DefaultGetTransactionResultMaxInterval     = 10                      // seconds
+	DefaultGetTransactionResultTimeout         = 60                      // seconds
+	DefaultDialTimeout                         = 10                      // seconds
+	waitForHeightMinInterval                   = 100 * time.Millisecond
+	waitForHeightMaxInterval                   = 2 * time.Second // block interval
+	wsRequestRetryInterval                     = time.Second
 )
 
 type Wallet interface {
@@ -53,107 +63,752 @@ type Wallet interface {
 
 type Client struct {
 	*jsonrpc.Client
-	conns map[string]*websocket.Conn
-	log   log.Logger
-	mtx   sync.Mutex
+	conns   map[string]*websocket.Conn
+	opened  map[string]time.Time // of the conns, by local address
+	wsStats WSStats              // Connects and Lifetimes, guarded by mtx
+	log     log.Logger
+	mtx     sync.Mutex
+	methods map[string]string
+
+	monitors map[string]*MonitorInfo // running, by local address, guarded by mtx
+
+	sentMtx sync.Mutex
+	sent    map[HexInt]*sentTx // signed transactions by nonce
+
+	statsMtx sync.Mutex
+	stats    EndpointStats
+
+	rec *recorder // nil unless recording
+
+	debug *jsonrpc.Client // debug API of the node, for debug_estimateStep
+
+	hash func([]byte) []byte
+
+	userAgent string
+
+	signer *txSigner
+
+	monitorSem      chan struct{} // of the monitors running, nil if unbounded
+	monitorFailFast bool
+
+	wsDialer *websocket.Dialer
+
+	wsRequestRetry int
+	wsRetryable    map[int]bool // codes of WSResponse to retry
+
+	newID func(requestID string) interface{} // JSON-RPC id of a request
+
+	resultPollInterval    time.Duration // first interval of WaitForResults
+	resultPollMaxInterval time.Duration
+	resultWaitTimeout     time.Duration
+
+	rateLimit        *rateLimiter            // of all requests, nil if none
+	methodRateLimits map[string]*rateLimiter // by method name sent
+}
+
+const (
+	// weight of the latest sample in the latency average
+	endpointLatencyAlpha = 0.2
+	// latency sampled for a request failing without a JSON-RPC response
+	endpointErrorLatency = 5 * time.Second
+)
+
+// EndpointStats are the request statistics of the endpoint of a Client.
+type EndpointStats struct {
+	Endpoint string        `json:"endpoint"`
+	Latency  time.Duration `json:"latency"` // moving average, 0 if no request yet
+	Requests uint64        `json:"requests"`
+	Errors   uint64        `json:"errors"`
+}
+
+// Do calls the JSON-RPC method like jsonrpc.Client.Do, tracking its latency.
+func (c *Client) Do(method string, reqPtr, respPtr interface{}) (*jsonrpc.Response, error) {
+	return c.DoContext(context.Background(), method, reqPtr, respPtr)
+}
+
+// DoContext is Do waiting for the rate limits of the client, if any, until
+// ctx is done.
+func (c *Client) DoContext(ctx context.Context, method string, reqPtr, respPtr interface{}) (*jsonrpc.Response, error) {
+	if err := c.waitRateLimit(ctx, method); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	reqID := newULID(start)
+	id := c.newID(reqID)
+	header := http.Header{}
+	header.Set(HeaderKeyRequestID, reqID)
+	resp, err := c.Client.DoWithID(method, id, reqPtr, respPtr, header)
+	latency := time.Since(start)
+	if hErr, ok := err.(*bridgecommon.HttpError); ok && hErr.StatusCode() == http.StatusTooManyRequests {
+		err = &ThrottledError{
+			Method:     method,
+			RetryAfter: parseRetryAfter(hErr.Header().Get("Retry-After"), time.Now()),
+			Err:        err,
+		}
+		c.log.WithFields(log.Fields{"method": method, "retryAfter": err.(*ThrottledError).RetryAfter}).Warn("request throttled")
+	}
+	if err != nil {
+		c.log.WithFields(log.Fields{"method": method, "id": id, "requestId": reqID, "error": err}).Debug("request failed")
+	} else {
+		c.log.WithFields(log.Fields{"method": method, "id": id, "requestId": reqID}).Trace("request")
+	}
+
+	c.statsMtx.Lock()
+	defer c.statsMtx.Unlock()
+	c.stats.Requests++
+	if err != nil {
+		if _, ok := err.(*jsonrpc.Error); !ok {
+			// the endpoint failed rather than the request
+			c.stats.Errors++
+			if latency < endpointErrorLatency {
+				latency = endpointErrorLatency
+			}
+		}
+	}
+	if c.stats.Latency == 0 {
+		c.stats.Latency = latency
+	} else {
+		c.stats.Latency = time.Duration(endpointLatencyAlpha*float64(latency) +
+			(1-endpointLatencyAlpha)*float64(c.stats.Latency))
+	}
+	if c.rec != nil {
+		c.rec.recordCall(method, reqPtr, respPtr, err)
+	}
+	return resp, err
+}
+
+const (
+	// delay before retrying a throttled request without Retry-After
+	defaultRetryAfter = time.Second
+	// longest Retry-After honored, longer ones are cut to it
+	maxRetryAfter = time.Minute
+)
+
+// parseRetryAfter returns the delay of a Retry-After header in seconds or
+// as an HTTP date, or defaultRetryAfter if invalid, up to maxRetryAfter.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	d := defaultRetryAfter
+	if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		if d = t.Sub(now); d < 0 {
+			d = 0
+		}
+	}
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+	return d
+}
+
+// retryDelay returns the delay before retrying a request failed with err:
+// the RetryAfter of a ThrottledError if longer than d, or else d.
+func retryDelay(err error, d time.Duration) time.Duration {
+	var terr *ThrottledError
+	if errors.As(err, &terr) && terr.RetryAfter > d {
+		return terr.RetryAfter
+	}
+	return d
+}
+
+// WSStats are the statistics of the websocket connections of a Client.
+type WSStats struct {
+	Open     int    `json:"open"`
+	Connects uint64 `json:"connects"` // including the open ones
+	// OldestOpen is the age of the oldest connection open, if any.
+	OldestOpen time.Duration `json:"oldestOpen"`
+	// Lifetimes is the histogram of the lifetimes of the closed connections.
+	Lifetimes WSLifetimes `json:"lifetimes"`
+}
+
+// Reconnects returns the number of connections after the first one.
+func (s *WSStats) Reconnects() uint64 {
+	if s.Connects == 0 {
+		return 0
+	}
+	return s.Connects - 1
+}
+
+// WSLifetimeBounds are the upper bounds of the buckets of WSLifetimes, the
+// last bucket counting the longer lifetimes.
+var WSLifetimeBounds = []time.Duration{
+	time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour,
+}
+
+// WSLifetimes counts lifetimes by bucket of WSLifetimeBounds.
+type WSLifetimes []uint64
+
+func (l *WSLifetimes) add(d time.Duration) {
+	if *l == nil {
+		*l = make(WSLifetimes, len(WSLifetimeBounds)+1)
+	}
+	i := 0
+	for i < len(WSLifetimeBounds) && d > WSLifetimeBounds[i] {
+		i++
+	}
+	(*l)[i]++
+}
+
+// WSStats returns the statistics of the websocket connections, those of
+// the monitors, opened by the client.
+func (c *Client) WSStats() WSStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	stats := c.wsStats
+	stats.Lifetimes = append(WSLifetimes(nil), c.wsStats.Lifetimes...)
+	stats.Open = len(c.conns)
+	for _, opened := range c.opened {
+		if age := time.Since(opened); age > stats.OldestOpen {
+			stats.OldestOpen = age
+		}
+	}
+	return stats
+}
+
+// Stats returns the request statistics of the endpoint.
+func (c *Client) Stats() EndpointStats {
+	c.statsMtx.Lock()
+	defer c.statsMtx.Unlock()
+	stats := c.stats
+	stats.Endpoint = c.Endpoint
+	return stats
+}
+
+// MonitorInfo is the state of a monitor running on a Client.
+type MonitorInfo struct {
+	Endpoint    string    `json:"endpoint"`
+	URL         string    `json:"url"` // "/block" or "/event"
+	LocalAddr   string    `json:"localAddr"`
+	StartHeight int64     `json:"startHeight"` // requested
+	Started     time.Time `json:"started"`
+	// LastHeight and LastNotified are the height and time of the last
+	// notification, zero if none was received.
+	LastHeight    int64     `json:"lastHeight"`
+	LastNotified  time.Time `json:"lastNotified"`
+	Notifications uint64    `json:"notifications"`
+}
+
+// Monitors returns the state of the monitors running on the client, the
+// oldest first.
+func (c *Client) Monitors() []MonitorInfo {
+	c.mtx.Lock()
+	ms := make([]MonitorInfo, 0, len(c.monitors))
+	for _, m := range c.monitors {
+		ms = append(ms, *m)
+	}
+	c.mtx.Unlock()
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Started.Before(ms[j].Started)
+	})
+	return ms
+}
+
+func (c *Client) _addMonitor(conn *websocket.Conn, reqUrl string, reqPtr interface{}) {
+	m := &MonitorInfo{
+		Endpoint:  c.Endpoint,
+		URL:       reqUrl,
+		LocalAddr: conn.LocalAddr().String(),
+		Started:   time.Now(),
+	}
+	switch req := reqPtr.(type) {
+	case *BlockRequest:
+		m.StartHeight, _ = req.Height.Value()
+	case *EventRequest:
+		m.StartHeight, _ = req.Height.Value()
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.monitors[m.LocalAddr] = m
+}
+
+func (c *Client) _removeMonitor(conn *websocket.Conn) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.monitors, conn.LocalAddr().String())
+}
+
+// _monitorNotified records a notification of v, if v is one, to the monitor
+// of conn.
+func (c *Client) _monitorNotified(conn *websocket.Conn, v interface{}) {
+	var height HexInt
+	switch n := v.(type) {
+	case *BlockNotification:
+		height = n.Height
+	case *EventNotification:
+		height = n.Height
+	default:
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if m, ok := c.monitors[conn.LocalAddr().String()]; ok {
+		m.LastHeight, _ = height.Value()
+		m.LastNotified = time.Now()
+		m.Notifications++
+	}
 }
 
-var txSerializeExcludes = map[string]bool{"signature": true}
+// ClientOptions customizes a Client at construction time.
+type ClientOptions struct {
+	// Methods maps the default JSON-RPC method name (e.g. "icx_getBlockByHeight")
+	// to the name exposed by an API-compatible node that renamed it.
+	// Methods not present in the map are sent with their default names.
+	Methods map[string]string `json:"methods"`
+
+	// DisableCompression stops the client from requesting gzip-compressed
+	// responses. By default requests carry "Accept-Encoding: gzip" and
+	// compressed responses, such as large proofs, are decompressed
+	// transparently.
+	DisableCompression bool `json:"disableCompression"`
+
+	// Record is the path of a file to which the calls and notifications of
+	// the client are appended, to be served later by a ReplayServer.
+	Record string `json:"record"`
+
+	// Hash names the hash function of the chain, used for transaction
+	// hashes and validator data: "sha3-256" (default) or "keccak-256". It
+	// must match the chain, or every hash silently mismatches: signed
+	// transactions are rejected and validator data fails verification.
+	// Block hashes, proofs and votes are SHA3-256 regardless, as in the
+	// Verifier.
+	Hash string `json:"hash"`
+
+	// UserAgent is sent as the User-Agent of the requests and websocket
+	// connections, for node operators to tell relayers apart.
+	// Each request also carries a unique X-Request-Id, logged by the client
+	// along with the method, to join node and client logs.
+	UserAgent string `json:"userAgent"`
+
+	// VerifySignature makes SignTransaction check that the signature of
+	// the wallet recovers to its address, to catch a misconfigured
+	// (e.g. HSM-backed) wallet before the node rejects its transactions.
+	VerifySignature bool `json:"verifySignature"`
+
+	// WSRequestRetry is the number of times a monitor request answered with
+	// a retryable WSResponse code is retried, on a new connection, before
+	// the monitor fails. Zero fails on the first error response.
+	WSRequestRetry uint64 `json:"wsRequestRetry"`
+
+	// WSRetryableCodes are the WSResponse codes to retry a monitor request
+	// for, replacing DefaultWSRetryableCodes if not empty.
+	WSRetryableCodes []int `json:"wsRetryableCodes"`
+
+	// IDGenerator names how the JSON-RPC ids of the requests are generated:
+	// "timestamp" (default) for the current time in milliseconds,
+	// "monotonic" for a counter from 1, or "request-id" for the
+	// X-Request-Id of the request. The id is logged along with each call.
+	IDGenerator string `json:"idGenerator"`
+
+	// NewID, if set, returns the JSON-RPC id of a request given its
+	// X-Request-Id, instead of IDGenerator.
+	NewID func(requestID string) interface{} `json:"-"`
+
+	// ResultPollMaxInterval caps the interval in seconds between the polls
+	// of WaitForResults, doubling from
+	// DefaultGetTransactionResultPollingInterval after each pending result.
+	// Defaults to DefaultGetTransactionResultMaxInterval.
+	ResultPollMaxInterval uint64 `json:"resultPollMaxInterval"`
 
+	// ResultWaitTimeout is the time in seconds WaitForResults waits for a
+	// transaction to be executed. Defaults to
+	// DefaultGetTransactionResultTimeout.
+	ResultWaitTimeout uint64 `json:"resultWaitTimeout"`
+
+	// SerializeExcludes are the fields of a transaction excluded from its
+	// hash by SignTransaction in addition to "signature", for chains
+	// hashing transactions over a different set of fields.
+	SerializeExcludes []string `json:"serializeExcludes"`
+
+	// RateLimit caps the number of requests per second sent by the client,
+	// including the websocket connections, to stay within the limits of a
+	// public endpoint. Zero doesn't limit them.
+	RateLimit float64 `json:"rateLimit"`
+
+	// RateBurst is the number of requests that can be sent at once within
+	// the rate limits, at least 1.
+	RateBurst int `json:"rateBurst"`
+
+	// MethodRateLimits caps the number of requests per second of methods,
+	// by their default name, in addition to RateLimit.
+	MethodRateLimits map[string]float64 `json:"methodRateLimits"`
+
+	// MaxStepLimit and MaxValue bound the StepLimit and Value of the
+	// transactions signed or sent by the client, which fail with a
+	// TxBoundsError before any request instead of being rejected by the
+	// node. MaxValue is in loop, decimal or 0x-prefixed hex. Zero or empty
+	// leaves them unbounded; they must be non-negative integers anyway.
+	MaxStepLimit uint64 `json:"maxStepLimit"`
+	MaxValue     string `json:"maxValue"`
+
+	// MaxMonitors caps the number of websocket monitors running at once on
+	// the client, e.g. shared by several receivers, not to exhaust the
+	// connection slots of the node. A monitor past the cap waits for one
+	// to finish, or fails with ErrTooManyMonitors if MonitorFailFast.
+	// Zero doesn't cap them.
+	MaxMonitors     uint64 `json:"maxMonitors"`
+	MonitorFailFast bool   `json:"monitorFailFast"`
+
+	// DialTimeout is the time in seconds allowed to connect to the node,
+	// including the websocket handshake, so that an unreachable node fails
+	// fast instead of after the TCP timeout of the OS. Defaults to
+	// DefaultDialTimeout.
+	DialTimeout uint64 `json:"dialTimeout"`
+
+	// Timeout is the time in seconds allowed to each HTTP request, from
+	// connecting to reading the response body. Zero means no timeout.
+	Timeout uint64 `json:"timeout"`
+}
+
+// waitRateLimit waits for the rate limits of the requests of method, or
+// returns ctx.Err() if ctx is done first.
+func (c *Client) waitRateLimit(ctx context.Context, method string) error {
+	if l := c.methodRateLimits[method]; l != nil {
+		if err := l.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if c.rateLimit != nil {
+		return c.rateLimit.Wait(ctx)
+	}
+	return nil
+}
+
+// idGenerators return the JSON-RPC id generators selectable by
+// ClientOptions.IDGenerator, a new one per client.
+var idGenerators = map[string]func() func(string) interface{}{
+	"": newTimestampID, "timestamp": newTimestampID,
+	"monotonic": func() func(string) interface{} {
+		var n int64
+		return func(string) interface{} {
+			return atomic.AddInt64(&n, 1)
+		}
+	},
+	"request-id": func() func(string) interface{} {
+		return func(requestID string) interface{} {
+			return requestID
+		}
+	},
+}
+
+func newTimestampID() func(string) interface{} {
+	return func(string) interface{} {
+		return time.Now().UnixNano() / int64(time.Millisecond)
+	}
+}
+
+// hashFuncs are the hash functions selectable by ClientOptions.Hash.
+var hashFuncs = map[string]func([]byte) []byte{
+	"":           crypto.SHA3Sum256,
+	"sha3-256":   crypto.SHA3Sum256,
+	"keccak-256": crypto.Keccak256,
+}
+
+// method returns the wire name of the JSON-RPC method for the default name.
+func (c *Client) method(name string) string {
+	if m, ok := c.methods[name]; ok && m != "" {
+		return m
+	}
+	return name
+}
+
+// SignTransaction signs p with w and sets its TxHash. The current time is used
+// as Timestamp only if it's not set yet, so that re-signing a transaction for
+// a retry keeps its hash and lets the node detect it as a duplicate.
+// Clear Timestamp to sign it as a new transaction.
 func (c *Client) SignTransaction(w Wallet, p *TransactionParam) error {
-	p.Timestamp = NewHexInt(time.Now().UnixNano() / int64(time.Microsecond))
-	js, err := json.Marshal(p)
+	return c.signer.sign(w, p, c.signer.excludes)
+}
+
+// SignTransactionWithExcludes is SignTransaction excluding the fields in
+// excludes from the hash instead of those of the client. It defaults to
+// excluding only "signature" if excludes is nil.
+func (c *Client) SignTransactionWithExcludes(w Wallet, p *TransactionParam, excludes map[string]bool) error {
+	return c.signer.sign(w, p, excludes)
+}
+
+// recoverAddress returns the address of the key which signed hash with sig.
+func recoverAddress(hash, sig []byte) (Address, error) {
+	s, err := gocrypto.ParseSignature(sig)
 	if err != nil {
-		return err
+		return "", err
+	}
+	pub, err := s.RecoverPublicKey(hash)
+	if err != nil {
+		return "", err
 	}
+	return Address(common.NewAccountAddressFromPublicKey(pub).String()), nil
+}
 
-	bs, err := SerializeJSON(js, nil, txSerializeExcludes)
+// EstimateStep returns the number of steps the node estimates p to use,
+// with debug_estimateStep of its debug API (/api/v3d). p needs no signature
+// and its StepLimit is ignored; Timestamp must be set.
+func (c *Client) EstimateStep(p *TransactionParam) (*big.Int, error) {
+	js, err := json.Marshal(p)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var param map[string]interface{}
+	if err := json.Unmarshal(js, &param); err != nil {
+		return nil, err
 	}
-	bs = append([]byte("icx_sendTransaction."), bs...)
-	txHash := crypto.SHA3Sum256(bs)
-	p.TxHash = NewHexBytes(txHash)
-	sig, err := w.Sign(txHash)
+	delete(param, "stepLimit")
+	delete(param, "signature")
+	var result HexInt
+	if _, err := c.debug.Do(c.method("debug_estimateStep"), param, &result); err != nil {
+		return nil, err
+	}
+	return result.BigInt()
+}
+
+// EstimateAndSignTransaction sets the StepLimit of p to the steps estimated
+// by EstimateStep times multiplier, to leave a margin for state changes
+// before p is executed, then signs p like SignTransaction.
+// A multiplier below 1 is taken as 1.
+func (c *Client) EstimateAndSignTransaction(w Wallet, p *TransactionParam, multiplier float64) error {
+	if p.Timestamp == "" {
+		p.Timestamp = NewHexInt(time.Now().UnixNano() / int64(time.Microsecond))
+	}
+	steps, err := c.EstimateStep(p)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "EstimateStep: %v", err)
 	}
-	p.Signature = base64.StdEncoding.EncodeToString(sig)
-	return nil
+	if multiplier > 1 {
+		steps, _ = new(big.Float).Mul(new(big.Float).SetInt(steps), big.NewFloat(multiplier)).Int(nil)
+	}
+	p.StepLimit = HexInt("0x" + steps.Text(16))
+	return c.SignTransaction(w, p)
+}
+
+// GovernanceAddress is the address of the governance contract of ICON.
+const GovernanceAddress = Address("cx0000000000000000000000000000000000000001")
+
+// GetStepPrice returns the price of a step in loop, from the governance
+// contract. The fee of a transaction is its steps used times the price.
+func (c *Client) GetStepPrice(ctx context.Context) (*big.Int, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var result HexInt
+	if err := c.Call(&CallParam{
+		ToAddress: GovernanceAddress,
+		DataType:  "call",
+		Data:      &CallData{Method: "getStepPrice"},
+	}, &result); err != nil {
+		return nil, err
+	}
+	return result.BigInt()
+}
+
+// GetNetworkInfo returns the network id and the parameters of the chain of
+// the endpoint, e.g. to set the NetworkID of transactions from it instead
+// of configuring it per environment.
+func (c *Client) GetNetworkInfo(ctx context.Context) (*NetworkInfo, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	ni := &NetworkInfo{}
+	if _, err := c.DoContext(ctx, c.method("icx_getNetworkInfo"), struct{}{}, ni); err != nil {
+		return nil, err
+	}
+	return ni, nil
 }
 
 func (c *Client) SendTransaction(p *TransactionParam) (*HexBytes, error) {
+	return c.SendTransactionContext(context.Background(), p)
+}
+
+// SendTransactionContext is SendTransaction waiting for the rate limits of
+// the client until ctx is done.
+func (c *Client) SendTransactionContext(ctx context.Context, p *TransactionParam) (*HexBytes, error) {
+	if err := c.signer.checkBounds(p); err != nil {
+		return nil, err
+	}
 	var result HexBytes
-	if _, err := c.Do("icx_sendTransaction", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_sendTransaction"), p, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
+// sentTxLifetime is how long SendTransactionWithNonce remembers a nonce.
+const sentTxLifetime = time.Hour
+
+// sentTx is a transaction signed by SendTransactionWithNonce.
+type sentTx struct {
+	params string // of the transaction but its signature, to detect a reuse
+	tx     TransactionParam
+	at     time.Time
+}
+
+// SendTransactionWithNonce sends p signed by w at most once per nonce, so
+// that a send whose outcome is unknown (e.g. on timeout) can be retried
+// safely with the same nonce. p is left unchanged.
+//
+// The first call for a nonce signs a copy of p with the nonce (which sets
+// Timestamp and so fixes the transaction hash) and remembers it. Later calls
+// with the same nonce must pass the same p, but for its Timestamp, or an
+// error is returned: if the remembered transaction is known to the node,
+// its hash is returned without sending; otherwise the very same signed
+// transaction is sent again, so the node either accepts it once or rejects
+// it as a duplicate. Only if the node rejects it as expired, which means it
+// can no longer be included, is it re-signed with a new timestamp.
+// Nonces are remembered for sentTxLifetime.
+func (c *Client) SendTransactionWithNonce(w Wallet, p *TransactionParam, nonce HexInt) (*HexBytes, error) {
+	tx := *p
+	tx.Nonce = nonce
+	tx.Signature, tx.TxHash = "", ""
+	unsigned := tx
+	unsigned.Timestamp = ""
+	params, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sentMtx.Lock()
+	now := time.Now()
+	for n, st := range c.sent {
+		if now.Sub(st.at) > sentTxLifetime {
+			delete(c.sent, n)
+		}
+	}
+	st, ok := c.sent[nonce]
+	switch {
+	case ok && st.params != string(params):
+		c.sentMtx.Unlock()
+		return nil, fmt.Errorf("nonce %v already used by transaction %v", nonce, st.tx.TxHash)
+	case ok:
+		tx = st.tx
+	default:
+		if err := c.SignTransaction(w, &tx); err != nil {
+			c.sentMtx.Unlock()
+			return nil, err
+		}
+		if c.sent == nil {
+			c.sent = make(map[HexInt]*sentTx)
+		}
+		c.sent[nonce] = &sentTx{params: string(params), tx: tx, at: now}
+	}
+	c.sentMtx.Unlock()
+
+	if ok {
+		_, err := c.GetTransactionResult(&TransactionHashParam{Hash: tx.TxHash})
+		if err == nil || IsPending(err) {
+			c.log.Debugf("SendTransactionWithNonce: already sent nonce:%v, txh:%v", nonce, tx.TxHash)
+			return &tx.TxHash, nil
+		}
+	}
+
+	txh, err := c.SendTransaction(&tx)
+	switch {
+	case err == nil:
+		return txh, nil
+	case IsDuplicateTransaction(err):
+		return &tx.TxHash, nil
+	case IsExpired(err):
+		tx.Timestamp = "" // sign with a new timestamp
+		if err := c.SignTransaction(w, &tx); err != nil {
+			return nil, err
+		}
+		c.sentMtx.Lock()
+		if st, ok := c.sent[nonce]; ok && st.params == string(params) {
+			st.tx = tx
+		}
+		c.sentMtx.Unlock()
+		return c.SendTransaction(&tx)
+	default:
+		return nil, err
+	}
+}
+
 func (c *Client) SendTransactionAndWait(p *TransactionParam) (*HexBytes, error) {
+	if err := c.signer.checkBounds(p); err != nil {
+		return nil, err
+	}
 	var result HexBytes
-	if _, err := c.Do("icx_sendTransactionAndWait", p, &result); err != nil {
+	if _, err := c.Do(c.method("icx_sendTransactionAndWait"), p, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
 func (c *Client) GetTransactionResult(p *TransactionHashParam) (*TransactionResult, error) {
+	return c.GetTransactionResultContext(context.Background(), p)
+}
+
+// GetTransactionResultContext is GetTransactionResult waiting for the rate
+// limits of the client until ctx is done.
+func (c *Client) GetTransactionResultContext(ctx context.Context, p *TransactionHashParam) (*TransactionResult, error) {
 	tr := &TransactionResult{}
-	if _, err := c.Do("icx_getTransactionResult", p, tr); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getTransactionResult"), p, tr); err != nil {
 		return nil, err
 	}
 	return tr, nil
 }
 
+// GetTransactionByHash returns the transaction with the hash in p, as sent.
+func (c *Client) GetTransactionByHash(ctx context.Context, p *TransactionHashParam) (*Transaction, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	tx := &Transaction{}
+	if _, err := c.DoContext(ctx, c.method("icx_getTransactionByHash"), p, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
 func (c *Client) WaitTransactionResult(p *TransactionHashParam) (*TransactionResult, error) {
 	tr := &TransactionResult{}
-	if _, err := c.Do("icx_waitTransactionResult", p, tr); err != nil {
+	if _, err := c.Do(c.method("icx_waitTransactionResult"), p, tr); err != nil {
 		return nil, err
 	}
 	return tr, nil
 }
 
 func (c *Client) Call(p *CallParam, r interface{}) error {
-	_, err := c.Do("icx_call", p, r)
+	return c.CallContext(context.Background(), p, r)
+}
+
+// CallContext is Call waiting for the rate limits of the client until ctx is
+// done.
+func (c *Client) CallContext(ctx context.Context, p *CallParam, r interface{}) error {
+	_, err := c.DoContext(ctx, c.method("icx_call"), p, r)
 	return err
 }
 
-func (c *Client) SendTransactionAndGetResult(p *TransactionParam) (*HexBytes, *TransactionResult, error) {
-	thp := &TransactionHashParam{}
-txLoop:
+// sendTransactionRetry sends the signed transaction p, retrying while the
+// transaction pool overflows. A duplicate of p is taken as sent.
+func (c *Client) sendTransactionRetry(ctx context.Context, p *TransactionParam) (*HexBytes, error) {
 	for {
-		txh, err := c.SendTransaction(p)
-		if err != nil {
-			switch err {
-			case ErrSendFailByOverflow:
-				//TODO Retry max
-				time.Sleep(DefaultSendTransactionRetryInterval)
-				c.log.Debugf("Retry SendTransaction")
-				continue txLoop
-			default:
-				switch re := err.(type) {
-				case *jsonrpc.Error:
-					switch re.Code {
-					case JsonrpcErrorCodeSystem:
-						if subEc, err := strconv.ParseInt(re.Message[1:5], 0, 32); err == nil {
-							switch subEc {
-							case 2000: //DuplicateTransactionError
-								//Ignore
-								c.log.Debugf("DuplicateTransactionError txh:%v", txh)
-								thp.Hash = *txh
-								break txLoop
-							}
-						}
-					}
-				}
+		txh, err := c.SendTransactionContext(ctx, p)
+		switch {
+		case err == nil:
+			return txh, nil
+		case IsOverflow(err):
+			//TODO Retry max
+			c.log.Debugf("Retry SendTransaction")
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(DefaultSendTransactionRetryInterval):
 			}
+		case IsDuplicateTransaction(err):
+			c.log.Debugf("DuplicateTransactionError txh:%v", p.TxHash)
+			return &p.TxHash, nil
+		default:
 			c.log.Debugf("fail to SendTransaction hash:%v, err:%+v", txh, err)
-			return &thp.Hash, nil, err
+			return nil, err
 		}
-		thp.Hash = *txh
-		break txLoop
 	}
+}
+
+func (c *Client) SendTransactionAndGetResult(p *TransactionParam) (*HexBytes, *TransactionResult, error) {
+	thp := &TransactionHashParam{}
+	txh, err := c.sendTransactionRetry(context.Background(), p)
+	if err != nil {
+		return &thp.Hash, nil, err
+	}
+	thp.Hash = *txh
 
 txrLoop:
 	for {
@@ -175,97 +830,195 @@ txrLoop:
 	}
 }
 
+// TransactionOutcome is the outcome of a transaction sent by SendTransactions.
+type TransactionOutcome struct {
+	TxHash *HexBytes
+	Result *TransactionResult
+	Err    error
+}
+
+// SendTransactions sends the signed transactions ps and waits for their
+// results, returned in the order of ps. Transactions from the same address are
+// sent one at a time in the given order, so that they reach the node in nonce
+// order; those from different addresses are sent concurrently. Each
+// transaction is retried like in SendTransactionAndGetResult.
+func (c *Client) SendTransactions(ctx context.Context, ps []*TransactionParam) []*TransactionOutcome {
+	outcomes := make([]*TransactionOutcome, len(ps))
+	bySender := make(map[Address][]int)
+	for i, p := range ps {
+		bySender[p.FromAddress] = append(bySender[p.FromAddress], i)
+	}
+
+	var wg sync.WaitGroup
+	for _, idxs := range bySender {
+		wg.Add(1)
+		go func(idxs []int) {
+			defer wg.Done()
+			for _, i := range idxs {
+				txh, err := c.sendTransactionRetry(ctx, ps[i])
+				outcomes[i] = &TransactionOutcome{TxHash: txh, Err: err}
+			}
+		}(idxs)
+	}
+	wg.Wait()
+
+	for _, o := range outcomes {
+		if o.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(o *TransactionOutcome) {
+			defer wg.Done()
+			_, o.Result, o.Err = c.WaitForResults(ctx, &TransactionHashParam{Hash: *o.TxHash})
+		}(o)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// WaitForResults polls the result of the transaction until it is executed,
+// backing off from DefaultGetTransactionResultPollingInterval up to the
+// ResultPollMaxInterval of the client between polls. It fails with a
+// ResultTimeoutError if the transaction is still pending after the
+// ResultWaitTimeout of the client.
 func (c *Client) WaitForResults(ctx context.Context, thp *TransactionHashParam) (txh *HexBytes, txr *TransactionResult, err error) {
-	ticker := time.NewTicker(time.Duration(DefaultGetTransactionResultPollingInterval) * time.Nanosecond)
-	retryLimit := 10
-	retryCounter := 0
 	txh = &thp.Hash
-	for {
-		defer ticker.Stop()
+	start := time.Now()
+	interval := c.resultPollInterval
+	for attempts := 1; ; attempts++ {
+		if remaining := c.resultWaitTimeout - time.Since(start); interval > remaining {
+			interval = remaining
+		}
 		select {
 		case <-ctx.Done():
 			err = errors.New("Context Cancelled ReceiptWait Exiting ")
 			return
-		case <-ticker.C:
-			if retryCounter >= retryLimit {
-				err = errors.New("Retry Limit Exceeded while waiting for results of transaction")
-				return
-			}
-			retryCounter++
-			//c.log.Debugf("GetTransactionResult Attempt: %d", retryCounter)
-			txr, err = c.GetTransactionResult(thp)
-			if err != nil {
-				switch re := err.(type) {
-				case *jsonrpc.Error:
-					switch re.Code {
-					case JsonrpcErrorCodePending, JsonrpcErrorCodeExecuting:
-						continue
-					}
-				}
-			}
-			//c.log.Debugf("GetTransactionResult hash:%v, txr:%+v, err:%+v", thp.Hash, txr, err)
+		case <-time.After(interval):
+		}
+		txr, err = c.GetTransactionResultContext(ctx, thp)
+		if !hasJsonrpcErrorCode(err, JsonrpcErrorCodePending, JsonrpcErrorCodeExecuting) {
 			return
 		}
+		if waited := time.Since(start); waited >= c.resultWaitTimeout {
+			err = &ResultTimeoutError{Hash: thp.Hash, Waited: waited, Attempts: attempts, Err: err}
+			return
+		}
+		if interval *= 2; interval > c.resultPollMaxInterval {
+			interval = c.resultPollMaxInterval
+		}
 	}
 }
 
 func (c *Client) GetLastBlock() (*Block, error) {
+	return c.GetLastBlockContext(context.Background())
+}
+
+// GetLastBlockContext is GetLastBlock waiting for the rate limits of the
+// client until ctx is done.
+func (c *Client) GetLastBlockContext(ctx context.Context) (*Block, error) {
 	result := &Block{}
-	if _, err := c.Do("icx_getLastBlock", struct{}{}, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getLastBlock"), struct{}{}, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// WaitForHeight polls the last block until its height reaches target,
+// backing off from waitForHeightMinInterval to waitForHeightMaxInterval
+// between polls. It returns ctx.Err() if ctx is done first. Failed polls are
+// retried.
+func (c *Client) WaitForHeight(ctx context.Context, target int64) error {
+	interval := waitForHeightMinInterval
+	for {
+		blk, err := c.GetLastBlockContext(ctx)
+		if err == nil && blk.Height >= target {
+			return nil
+		}
+		if err != nil {
+			c.log.WithFields(log.Fields{"target": target, "error": err}).Debug("WaitForHeight: GetLastBlock failed")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > waitForHeightMaxInterval {
+			interval = waitForHeightMaxInterval
+		}
+	}
+}
+
 func (c *Client) GetBlockByHeight(p *BlockHeightParam) (*Block, error) {
+	return c.GetBlockByHeightContext(context.Background(), p)
+}
+
+// GetBlockByHeightContext is GetBlockByHeight waiting for the rate limits of
+// the client until ctx is done.
+func (c *Client) GetBlockByHeightContext(ctx context.Context, p *BlockHeightParam) (*Block, error) {
+	result := &Block{}
+	if _, err := c.DoContext(ctx, c.method("icx_getBlockByHeight"), p, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetBlockByHash returns the block of the hash, e.g. to cross-check it with
+// the block of the same height.
+func (c *Client) GetBlockByHash(ctx context.Context, p *BlockHashParam) (*Block, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	result := &Block{}
-	if _, err := c.Do("icx_getBlockByHeight", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getBlockByHash"), p, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) GetBlockHeaderByHeight(p *BlockHeightParam) ([]byte, error) {
+func (c *Client) GetBlockHeaderByHeight(ctx context.Context, p *BlockHeightParam) ([]byte, error) {
 	var result []byte
-	if _, err := c.Do("icx_getBlockHeaderByHeight", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getBlockHeaderByHeight"), p, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) GetVotesByHeight(p *BlockHeightParam) ([]byte, error) {
+func (c *Client) GetVotesByHeight(ctx context.Context, p *BlockHeightParam) ([]byte, error) {
 	var result []byte
-	if _, err := c.Do("icx_getVotesByHeight", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getVotesByHeight"), p, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) GetDataByHash(p *DataHashParam) ([]byte, error) {
+func (c *Client) GetDataByHash(ctx context.Context, p *DataHashParam) ([]byte, error) {
 	var result []byte
-	_, err := c.Do("icx_getDataByHash", p, &result)
+	_, err := c.DoContext(ctx, c.method("icx_getDataByHash"), p, &result)
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) GetProofForResult(p *ProofResultParam) ([][]byte, error) {
+func (c *Client) GetProofForResult(ctx context.Context, p *ProofResultParam) ([][]byte, error) {
 	var result [][]byte
-	if _, err := c.Do("icx_getProofForResult", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getProofForResult"), p, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) GetProofForEvents(p *ProofEventsParam) ([][][]byte, error) {
+func (c *Client) GetProofForEvents(ctx context.Context, p *ProofEventsParam) ([][][]byte, error) {
 	var result [][][]byte
-	if _, err := c.Do("icx_getProofForEvents", p, &result); err != nil {
+	if _, err := c.DoContext(ctx, c.method("icx_getProofForEvents"), p, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// MonitorBlock calls cb with the blocks notified from p.Height on, until cb
+// or the connection fails or ctx is done. scb is called once subscribed and
+// errCb on errors; either may be nil if not needed.
 func (c *Client) MonitorBlock(ctx context.Context, p *BlockRequest, cb func(conn *websocket.Conn, v *BlockNotification) error, scb func(conn *websocket.Conn), errCb func(*websocket.Conn, error)) error {
 	resp := &BlockNotification{}
 	return c.Monitor(ctx, "/block", p, resp, func(conn *websocket.Conn, v interface{}) error {
@@ -281,21 +1034,28 @@ func (c *Client) MonitorBlock(ctx context.Context, p *BlockRequest, cb func(conn
 			case WSEventInit:
 				if scb != nil {
 					scb(conn)
-				} else {
-					return errors.New("Second Callback function (scb) is nil ")
 				}
 			}
 		case error:
-			errCb(conn, t)
+			if errCb != nil {
+				errCb(conn, t)
+			}
 			return t
 		default:
-			errCb(conn, fmt.Errorf("not supported type %T", t))
+			if errCb != nil {
+				errCb(conn, fmt.Errorf("not supported type %T", t))
+			}
 			return errors.New("Not supported type")
 		}
 		return nil
 	})
 }
 
+// MonitorEvent calls cb with the events notified from p.Height on, until cb
+// or the connection fails or ctx is done. An error of cb ends the monitor
+// and is returned, so that the caller resumes from the event it failed on.
+// errCb, which may be nil, is called on the errors notified, which don't
+// end the monitor.
 func (c *Client) MonitorEvent(ctx context.Context, p *EventRequest, cb func(conn *websocket.Conn, v *EventNotification) error, errCb func(*websocket.Conn, error)) error {
 	resp := &EventNotification{}
 	return c.Monitor(ctx, "/event", p, resp, func(conn *websocket.Conn, v interface{}) error {
@@ -303,44 +1063,236 @@ func (c *Client) MonitorEvent(ctx context.Context, p *EventRequest, cb func(conn
 		case *EventNotification:
 			if err := cb(conn, t); err != nil {
 				c.log.Debugf("MonitorEvent callback return err:%+v", err)
+				return err
 			}
+		case WSEvent:
+			c.log.Debugf("MonitorEvent WSEvent %s %+v", conn.LocalAddr().String(), t)
 		case error:
-			errCb(conn, t)
+			if errCb != nil {
+				errCb(conn, t)
+			}
 		default:
-			errCb(conn, fmt.Errorf("not supported type %T", t))
+			if errCb != nil {
+				errCb(conn, fmt.Errorf("not supported type %T", t))
+			}
 		}
 		return nil
 	})
 }
 
+// PollBlock calls cb with the blocks from p.Height on, as notified by
+// MonitorBlock, discovering them by polling the last block every interval
+// over HTTP, for nodes without websocket. Matching p.EventFilters requires
+// the results of all the transactions of each block, so it is much heavier
+// than MonitorBlock.
+func (c *Client) PollBlock(ctx context.Context, p *BlockRequest, interval time.Duration, cb func(v *BlockNotification) error) error {
+	height, err := p.Height.Value()
+	if err != nil {
+		return errors.Wrapf(err, "invalid height: %v", err)
+	}
+	for {
+		last, err := c.GetLastBlock()
+		if err != nil {
+			return errors.Wrapf(err, "GetLastBlock: %v", err)
+		}
+		for ; height <= last.Height; height++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			bn, err := c.pollBlockNotification(height, p.EventFilters)
+			if err != nil {
+				return err
+			}
+			if err := cb(bn); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollBlockNotification returns the notification of the block at height for
+// the filters, finding the matching events in the transaction results.
+func (c *Client) pollBlockNotification(height int64, filters []*EventFilter) (*BlockNotification, error) {
+	blk, err := c.GetBlockByHeight(&BlockHeightParam{Height: NewHexInt(height)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetBlockByHeight: height=%d, %v", height, err)
+	}
+	bn := &BlockNotification{Hash: blk.BlockHash, Height: NewHexInt(height)}
+	if !strings.HasPrefix(string(bn.Hash), "0x") {
+		bn.Hash = "0x" + bn.Hash // served without prefix
+	}
+	if len(filters) == 0 {
+		return bn, nil
+	}
+	bn.Indexes = make([][]HexInt, len(filters))
+	bn.Events = make([][][]HexInt, len(filters))
+	for i, tx := range blk.NormalTransactions {
+		txr, err := c.GetTransactionResult(&TransactionHashParam{Hash: tx.TxHash})
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetTransactionResult: height=%d, index=%d, %v", height, i, err)
+		}
+		for f, ef := range filters {
+			var events []HexInt
+			for j, el := range txr.EventLogs {
+				if ef.match(el.Addr, el.Indexed, el.Data) {
+					events = append(events, NewHexInt(int64(j)))
+				}
+			}
+			if len(events) > 0 {
+				bn.Indexes[f] = append(bn.Indexes[f], NewHexInt(int64(i)))
+				bn.Events[f] = append(bn.Events[f], events)
+			}
+		}
+	}
+	return bn, nil
+}
+
+// match returns whether the event log matches the filter, as checked by the
+// node for monitors.
+func (ef *EventFilter) match(addr Address, indexed, data []string) bool {
+	if ef.Addr != "" && ef.Addr != addr {
+		return false
+	}
+	if len(indexed) == 0 || indexed[0] != ef.Signature {
+		return false
+	}
+	for i, v := range ef.Indexed {
+		if v != nil && (i+1 >= len(indexed) || indexed[i+1] != *v) {
+			return false
+		}
+	}
+	for i, v := range ef.Data {
+		if v != nil && (i >= len(data) || data[i] != *v) {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Client) Monitor(ctx context.Context, reqUrl string, reqPtr, respPtr interface{}, cb wsReadCallback) error {
 	if cb == nil {
 		return fmt.Errorf("callback function cannot be nil")
 	}
-	conn, err := c.wsConnect(reqUrl, nil)
+	if rec := c.rec; rec != nil {
+		next := cb
+		cb = func(conn *websocket.Conn, v interface{}) error {
+			switch v.(type) {
+			case WSEvent, error:
+			default:
+				rec.recordNotification(reqUrl, v)
+			}
+			return next(conn, v)
+		}
+	}
+	if c.monitorSem != nil {
+		if err := c.acquireMonitor(ctx, reqUrl); err != nil {
+			return err
+		}
+		defer func() { <-c.monitorSem }()
+	}
+	conn, err := c.wsMonitorRequest(ctx, reqUrl, reqPtr)
 	if err != nil {
-		return ErrConnectFail
+		return err
 	}
+	c._addMonitor(conn, reqUrl, reqPtr)
 	defer func() {
 		c.log.Debugf("Monitor finish %s", conn.LocalAddr().String())
+		c._removeMonitor(conn)
 		c.wsClose(conn)
 	}()
-	if err = c.wsRequest(conn, reqPtr); err != nil {
-		return err
-	}
 	if err := cb(conn, WSEventInit); err != nil {
 		return err
 	}
+	next := cb
+	cb = func(conn *websocket.Conn, v interface{}) error {
+		c._monitorNotified(conn, v)
+		return next(conn, v)
+	}
 	return c.wsReadJSONLoop(ctx, conn, respPtr, cb)
 }
 
+// acquireMonitor takes a slot of monitorSem, waiting for one to be released
+// unless monitorFailFast.
+func (c *Client) acquireMonitor(ctx context.Context, reqUrl string) error {
+	select {
+	case c.monitorSem <- struct{}{}:
+		return nil
+	default:
+	}
+	if c.monitorFailFast {
+		return ErrTooManyMonitors
+	}
+	c.log.WithFields(log.Fields{"url": reqUrl, "max": cap(c.monitorSem)}).Debug("Monitor: wait for a slot")
+	select {
+	case c.monitorSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextErr is ctx.Err(), also once the deadline of ctx passed but before
+// ctx is done, as when a dial bounded by the deadline times out first.
+func contextErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// wsMonitorRequest connects to reqUrl and sends the monitor request,
+// retrying it on a new connection up to wsRequestRetry times while the node
+// answers with a retryable code.
+func (c *Client) wsMonitorRequest(ctx context.Context, reqUrl string, reqPtr interface{}) (*websocket.Conn, error) {
+	for retry := 0; ; retry++ {
+		conn, err := c.wsConnect(ctx, reqUrl, nil)
+		if err != nil {
+			if err := contextErr(ctx); err != nil {
+				return nil, err
+			}
+			return nil, ErrConnectFail
+		}
+		err = c.wsRequest(conn, reqPtr)
+		if err == nil {
+			return conn, nil
+		}
+		c.wsClose(conn)
+		wsErr, ok := err.(wsRequestError)
+		if !ok || wsErr.wsResp == nil || !c.wsRetryable[wsErr.wsResp.Code] || retry >= c.wsRequestRetry {
+			return nil, err
+		}
+		c.log.WithFields(log.Fields{
+			"url": reqUrl, "code": wsErr.wsResp.Code, "retry": retry + 1,
+		}).Warn("Monitor: retry request")
+		select {
+		case <-time.After(wsRequestRetryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 func (c *Client) CloseMonitor(conn *websocket.Conn) {
 	c.log.Debugf("CloseMonitor %s", conn.LocalAddr().String())
 	c.wsClose(conn)
 }
 
 func (c *Client) CloseAllMonitor() {
+	c.mtx.Lock()
+	conns := make([]*websocket.Conn, 0, len(c.conns))
 	for _, conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.mtx.Unlock()
+	for _, conn := range conns {
 		c.log.Debugf("CloseAllMonitor %s", conn.LocalAddr().String())
 		c.wsClose(conn)
 	}
@@ -354,6 +1306,16 @@ func (c *Client) _addWsConn(conn *websocket.Conn) {
 
 	la := conn.LocalAddr().String()
 	c.conns[la] = conn
+	c.opened[la] = time.Now()
+	c.wsStats.Connects++
+}
+
+func (c *Client) _hasWsConn(conn *websocket.Conn) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	_, ok := c.conns[conn.LocalAddr().String()]
+	return ok
 }
 
 func (c *Client) _removeWsConn(conn *websocket.Conn) {
@@ -364,6 +1326,8 @@ func (c *Client) _removeWsConn(conn *websocket.Conn) {
 	_, ok := c.conns[la]
 	if ok {
 		delete(c.conns, la)
+		c.wsStats.Lifetimes.add(time.Since(c.opened[la]))
+		delete(c.opened, la)
 	}
 }
 
@@ -372,9 +1336,21 @@ type wsConnectError struct {
 	httpResp *http.Response
 }
 
-func (c *Client) wsConnect(reqUrl string, reqHeader http.Header) (*websocket.Conn, error) {
+// wsConnect dials the websocket endpoint; cancelling ctx aborts a pending handshake.
+func (c *Client) wsConnect(ctx context.Context, reqUrl string, reqHeader http.Header) (*websocket.Conn, error) {
 	wsEndpoint := strings.Replace(c.Endpoint, "http", "ws", 1)
-	conn, httpResp, err := websocket.DefaultDialer.Dial(wsEndpoint+reqUrl, reqHeader)
+	if c.rateLimit != nil {
+		if err := c.rateLimit.Wait(ctx); err != nil {
+			return nil, wsConnectError{error: err}
+		}
+	}
+	if c.userAgent != "" {
+		if reqHeader == nil {
+			reqHeader = http.Header{}
+		}
+		reqHeader.Set("User-Agent", c.userAgent)
+	}
+	conn, httpResp, err := c.wsDialer.DialContext(ctx, wsEndpoint+reqUrl, reqHeader)
 	if err != nil {
 		wsErr := wsConnectError{error: err}
 		wsErr.httpResp = httpResp
@@ -441,7 +1417,7 @@ func (c *Client) wsReadJSONLoop(ctx context.Context, conn *websocket.Conn, respP
 		default:
 			v := reflect.New(elem.Type())
 			ptr := v.Interface()
-			if _, ok := c.conns[conn.LocalAddr().String()]; !ok {
+			if !c._hasWsConn(conn) {
 				c.log.Debugf("wsReadJSONLoop c.conns[%s] is nil", conn.LocalAddr().String())
 				return errors.New("wsReadJSONLoop c.conns is nil")
 			}
@@ -460,9 +1436,9 @@ func (c *Client) wsReadJSONLoop(ctx context.Context, conn *websocket.Conn, respP
 	}
 }
 
-func (c *Client) getBlockHeaderByHeight(height int64) (*BlockHeader, error) {
+func (c *Client) getBlockHeaderByHeight(ctx context.Context, height int64) (*BlockHeader, error) {
 	p := &BlockHeightParam{Height: NewHexInt(height)}
-	b, err := c.GetBlockHeaderByHeight(p)
+	b, err := c.GetBlockHeaderByHeight(ctx, p)
 	if err != nil {
 		return nil, mapError(err)
 	}
@@ -475,40 +1451,244 @@ func (c *Client) getBlockHeaderByHeight(height int64) (*BlockHeader, error) {
 	return &bh, nil
 }
 
-func (c *Client) getCommitVoteListByHeight(height int64) (*commitVoteList, error) {
-	p := &BlockHeightParam{Height: NewHexInt(height)}
-	b, err := c.GetVotesByHeight(p)
+// VerifyResultProof fetches the proof of the receipt at index of the block at
+// height and proves it against the receipt hash of the block header.
+// It returns the serialized receipt and whether the proof is valid. An error
+// is returned only if the header or the proof could not be fetched.
+func (c *Client) VerifyResultProof(ctx context.Context, height, index int64) ([]byte, bool, error) {
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+	bh, err := c.getBlockHeaderByHeight(ctx, height)
 	if err != nil {
-		return nil, mapError(err)
+		return nil, false, errors.Wrapf(err, "getBlockHeaderByHeight: %v", err)
+	}
+	var hr BlockHeaderResult
+	if _, err := codec.RLP.UnmarshalFromBytes(bh.Result, &hr); err != nil {
+		return nil, false, errors.Wrapf(err, "BlockHeaderResult.UnmarshalFromBytes: %v", err)
 	}
-	var cvl commitVoteList
-	_, err = codec.RLP.UnmarshalFromBytes(b, &cvl)
+
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+	proofs, err := c.GetProofForResult(ctx, &ProofResultParam{
+		BlockHash: NewHexBytes(crypto.SHA3Sum256(bh.serialized)),
+		Index:     NewHexInt(index),
+	})
 	if err != nil {
-		return nil, err
+		return nil, false, errors.Wrapf(err, "GetProofForResult: %v", err)
+	}
+	receipt, err := mptProve(NewHexInt(index), proofs, hr.ReceiptHash)
+	if err != nil {
+		c.log.WithFields(log.Fields{"height": height, "index": index, "error": err}).Debug("VerifyResultProof: invalid proof")
+		return nil, false, nil
+	}
+	return receipt, true, nil
+}
+
+// ResultLocation locates the receipt at Index of the block at Height.
+type ResultLocation struct {
+	Height int64
+	Index  int64
+}
+
+// ResultProof is the proof of the receipt at a ResultLocation.
+type ResultProof struct {
+	ResultLocation
+	Proof [][]byte
+	Err   error
+}
+
+// GetProofsForResults fetches the proofs of the receipts at locs, with at most
+// concurrency requests at a time, and returns them in the order of locs.
+// Each block header is fetched once, however many receipts of it are located.
+func (c *Client) GetProofsForResults(ctx context.Context, locs []ResultLocation, concurrency int) []*ResultProof {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	type blockHash struct {
+		once sync.Once
+		hash HexBytes
+		err  error
+	}
+	var mtx sync.Mutex
+	hashes := make(map[int64]*blockHash)
+	getBlockHash := func(height int64) (HexBytes, error) {
+		mtx.Lock()
+		bh, ok := hashes[height]
+		if !ok {
+			bh = &blockHash{}
+			hashes[height] = bh
+		}
+		mtx.Unlock()
+		bh.once.Do(func() {
+			header, err := c.getBlockHeaderByHeight(ctx, height)
+			if err != nil {
+				bh.err = errors.Wrapf(err, "getBlockHeaderByHeight: %v", err)
+				return
+			}
+			bh.hash = NewHexBytes(crypto.SHA3Sum256(header.serialized))
+		})
+		return bh.hash, bh.err
+	}
+
+	rps := make([]*ResultProof, len(locs))
+	ich := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(locs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ich {
+				rp := &ResultProof{ResultLocation: locs[i]}
+				rps[i] = rp
+				if rp.Err = ctx.Err(); rp.Err != nil {
+					continue
+				}
+				hash, err := getBlockHash(rp.Height)
+				if err != nil {
+					rp.Err = err
+					continue
+				}
+				rp.Proof, err = c.GetProofForResult(ctx, &ProofResultParam{
+					BlockHash: hash,
+					Index:     NewHexInt(rp.Index),
+				})
+				if err != nil {
+					rp.Err = errors.Wrapf(err, "GetProofForResult: %v", err)
+				}
+			}
+		}()
+	}
+	for i := range locs {
+		ich <- i
+	}
+	close(ich)
+	wg.Wait()
+	return rps
+}
+
+func (c *Client) getCommitVoteListByHeight(ctx context.Context, height int64) (*CommitVoteList, error) {
+	p := &BlockHeightParam{Height: NewHexInt(height)}
+	b, err := c.GetVotesByHeight(ctx, p)
+	if err != nil {
+		return nil, mapError(err)
 	}
-	return &cvl, nil
+	return DecodeCommitVoteList(b)
 }
 
-func (c *Client) getValidatorsByHash(hash common.HexHash) ([]common.Address, error) {
-	data, err := c.GetDataByHash(&DataHashParam{Hash: NewHexBytes(hash.Bytes())})
+func (c *Client) getValidatorsByHash(ctx context.Context, hash common.HexHash) ([]common.Address, error) {
+	data, err := c.GetDataByHash(ctx, &DataHashParam{Hash: NewHexBytes(hash.Bytes())})
 	if err != nil {
 		return nil, errors.Wrapf(err, "GetDataByHash; %v", err)
 	}
-	if !bytes.Equal(hash, crypto.SHA3Sum256(data)) {
-		return nil, errors.Errorf(
-			"invalid data: hash=%v, data=%v", hash, common.HexBytes(data))
+	if !bytes.Equal(hash, c.hash(data)) {
+		return nil, &DataHashMismatchError{Hash: hash, Len: len(data)}
 	}
-	var validators []common.Address
-	_, err = codec.BC.UnmarshalFromBytes(data, &validators)
+	validators, err := decodeValidators(data)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Unmarshal Validators: %v", err)
 	}
+	if err := checkValidators(validators); err != nil {
+		return nil, errors.Errorf("%v at hash %v", err, hash)
+	}
+	return validators, nil
+}
+
+// ValidateVerifierOptions checks that opts.ValidatorsHash is the hash of the
+// validators of the block at opts.BlockHeight, as declared by the previous
+// block, and that the validators can be fetched by it.
+func (c *Client) ValidateVerifierOptions(ctx context.Context, opts *VerifierOptions) error {
+	if err := c.checkVerifierValidatorsHash(ctx, opts); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if _, err := c.getValidatorsByHash(ctx, opts.ValidatorsHash); err != nil {
+		return errors.Wrapf(err, "getValidatorsByHash: %v", err)
+	}
+	return nil
+}
+
+// checkVerifierValidatorsHash checks opts.ValidatorsHash against the next
+// validators hash of the block before opts.BlockHeight. The check is skipped
+// below height 2, as the genesis block declares no validators.
+func (c *Client) checkVerifierValidatorsHash(ctx context.Context, opts *VerifierOptions) error {
+	if opts.BlockHeight < 2 {
+		return nil
+	}
+	prev, err := c.getBlockHeaderByHeight(ctx, int64(opts.BlockHeight)-1)
+	if err != nil {
+		return errors.Wrapf(err, "getBlockHeaderByHeight: %v", err)
+	}
+	if !bytes.Equal(prev.NextValidatorsHash, opts.ValidatorsHash) {
+		return fmt.Errorf(
+			"verifier validatorsHash mismatch at height %d: configured=%v, chain=%v",
+			opts.BlockHeight, opts.ValidatorsHash, common.HexHash(prev.NextValidatorsHash))
+	}
+	return nil
+}
+
+// checkValidators returns an error if no quorum can be formed by validators,
+// as when the set is empty or lists the same validator more than once.
+func checkValidators(validators []common.Address) error {
+	if len(validators) == 0 {
+		return errors.New("validator set empty")
+	}
+	seen := make(map[common.Address]bool, len(validators))
+	for i, v := range validators {
+		if seen[v] {
+			return fmt.Errorf("duplicate validator: index=%d, address=%v", i, &v)
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+// decodeValidators decodes a validator list as a list of addresses, falling
+// back to a list of byte strings each of which is either an address or the
+// public key of the validator, as serialized by other node versions.
+func decodeValidators(data []byte) ([]common.Address, error) {
+	var validators []common.Address
+	_, errAddrs := codec.BC.UnmarshalFromBytes(data, &validators)
+	if errAddrs == nil {
+		return validators, nil
+	}
+	var items [][]byte
+	if _, err := codec.BC.UnmarshalFromBytes(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid validators: len(data)=%d, as addresses: %v, as bytes list: %v",
+			len(data), errAddrs, err)
+	}
+	validators = make([]common.Address, 0, len(items))
+	for i, item := range items {
+		if len(item) == common.AddressBytes {
+			addr, err := common.NewAddress(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid validator address: index=%d, %v", i, err)
+			}
+			validators = append(validators, *addr)
+			continue
+		}
+		pub, err := gocrypto.ParsePublicKey(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validator: index=%d, len=%d, neither address nor public key: %v",
+				i, len(item), err)
+		}
+		validators = append(validators, *common.NewAccountAddressFromPublicKey(pub))
+	}
 	return validators, nil
 }
 
 func (c *Client) GetBalance(param *AddressParam) (*big.Int, error) {
+	return c.GetBalanceContext(context.Background(), param)
+}
+
+// GetBalanceContext is GetBalance waiting for the rate limits of the client
+// until ctx is done.
+func (c *Client) GetBalanceContext(ctx context.Context, param *AddressParam) (*big.Int, error) {
 	var result HexInt
-	_, err := c.Do("icx_getBalance", param, &result)
+	_, err := c.DoContext(ctx, c.method("icx_getBalance"), param, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -521,6 +1701,7 @@ func (c *Client) GetBalance(param *AddressParam) (*big.Int, error) {
 
 const (
 	HeaderKeyIconOptions = "Icon-Options"
+	HeaderKeyRequestID   = "X-Request-Id"
 	IconOptionsDebug     = "debug"
 	IconOptionsTimeout   = "timeout"
 )
@@ -596,15 +1777,117 @@ func NewIconOptionsByHeader(h http.Header) IconOptions {
 }
 
 func NewClient(uri string, l log.Logger) *Client {
+	c, err := NewClientWithOptions(uri, l, nil)
+	if err != nil {
+		l.Panicf("%v", err) // the default options are valid
+	}
+	return c
+}
+
+// NewClientWithOptions returns a client of the node at uri customized by
+// opts, which may be nil, or an error if opts are invalid.
+func NewClientWithOptions(uri string, l log.Logger, opts *ClientOptions) (*Client, error) {
 	//TODO options {MaxRetrySendTx, MaxRetryGetResult, MaxIdleConnsPerHost, Debug, Dump}
-	tr := &http.Transport{MaxIdleConnsPerHost: 1000}
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	dialTimeout := DefaultDialTimeout * time.Second
+	if opts.DialTimeout > 0 {
+		dialTimeout = time.Duration(opts.DialTimeout) * time.Second
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	tr := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: dialTimeout,
+		MaxIdleConnsPerHost: 1000,
+		DisableCompression:  opts.DisableCompression,
+	}
+	hc := &http.Client{Transport: tr, Timeout: time.Duration(opts.Timeout) * time.Second}
 	c := &Client{
-		Client: jsonrpc.NewJsonRpcClient(&http.Client{Transport: tr}, uri),
-		conns:  make(map[string]*websocket.Conn),
-		log:    l,
+		Client:  jsonrpc.NewJsonRpcClient(hc, uri),
+		debug:   jsonrpc.NewJsonRpcClient(hc, strings.Replace(uri, "/api/v3", "/api/v3d", 1)),
+		conns:   make(map[string]*websocket.Conn),
+		opened:  make(map[string]time.Time),
+		log:     l,
+		methods: make(map[string]string),
+		hash:    hashFuncs[opts.Hash],
+
+		monitors: make(map[string]*MonitorInfo),
+
+		wsDialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment, // as websocket.DefaultDialer
+			NetDialContext:   dialer.DialContext,
+			HandshakeTimeout: dialTimeout,
+		},
+
+		wsRequestRetry: int(opts.WSRequestRetry),
+		wsRetryable:    make(map[int]bool),
+
+		resultPollInterval:    DefaultGetTransactionResultPollingInterval,
+		resultPollMaxInterval: DefaultGetTransactionResultMaxInterval * time.Second,
+		resultWaitTimeout:     DefaultGetTransactionResultTimeout * time.Second,
 	}
-	opts := IconOptions{}
-	opts.SetBool(IconOptionsDebug, true)
-	c.CustomHeader[HeaderKeyIconOptions] = opts.ToHeaderValue()
-	return c
+	if opts.ResultPollMaxInterval > 0 {
+		c.resultPollMaxInterval = time.Duration(opts.ResultPollMaxInterval) * time.Second
+	}
+	if opts.ResultWaitTimeout > 0 {
+		c.resultWaitTimeout = time.Duration(opts.ResultWaitTimeout) * time.Second
+	}
+	if c.hash == nil {
+		return nil, fmt.Errorf("unknown hash %q", opts.Hash)
+	}
+	if c.newID = opts.NewID; c.newID == nil {
+		newIDs, ok := idGenerators[opts.IDGenerator]
+		if !ok {
+			return nil, fmt.Errorf("unknown id generator %q", opts.IDGenerator)
+		}
+		c.newID = newIDs()
+	}
+	for k, v := range opts.Methods {
+		c.methods[k] = v
+	}
+	if opts.RateLimit > 0 {
+		c.rateLimit = newRateLimiter(opts.RateLimit, opts.RateBurst)
+	}
+	for method, limit := range opts.MethodRateLimits {
+		if limit > 0 {
+			if c.methodRateLimits == nil {
+				c.methodRateLimits = make(map[string]*rateLimiter)
+			}
+			c.methodRateLimits[c.method(method)] = newRateLimiter(limit, opts.RateBurst)
+		}
+	}
+	if opts.MaxMonitors > 0 {
+		c.monitorSem = make(chan struct{}, opts.MaxMonitors)
+		c.monitorFailFast = opts.MonitorFailFast
+	}
+	signer, err := newTxSigner(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.signer = signer
+	codes := opts.WSRetryableCodes
+	if len(codes) == 0 {
+		codes = DefaultWSRetryableCodes
+	}
+	for _, code := range codes {
+		c.wsRetryable[code] = true
+	}
+	if opts.Record != "" {
+		rec, err := openRecorder(opts.Record)
+		if err != nil {
+			l.Errorf("fail to open record file %s err:%+v", opts.Record, err)
+		} else {
+			c.rec = rec
+		}
+	}
+	iconOpts := IconOptions{}
+	iconOpts.SetBool(IconOptionsDebug, true)
+	c.CustomHeader[HeaderKeyIconOptions] = iconOpts.ToHeaderValue()
+	if opts.UserAgent != "" {
+		c.userAgent = opts.UserAgent
+		c.CustomHeader["User-Agent"] = opts.UserAgent
+		c.debug.CustomHeader["User-Agent"] = opts.UserAgent
+	}
+	return c, nil
 }
@@ -1,13 +1,26 @@
 package icon
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/icon-project/goloop/common/codec"
+	"github.com/icon-project/icon-bridge/common/crypto"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
 	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/icon-project/icon-bridge/common/wallet"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,6 +30,522 @@ func NewTestClient() *Client {
 	return NewClient(uri, l)
 }
 
+func TestClientMethodNames(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		got = req.Method
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{"height":1}}`, req.ID)
+	}))
+	defer srv.Close()
+
+	cl := NewClient(srv.URL, log.New())
+	_, err := cl.GetLastBlock()
+	require.NoError(t, err)
+	require.Equal(t, "icx_getLastBlock", got)
+
+	cl = newTestClient(t, srv.URL, &ClientOptions{
+		Methods: map[string]string{"icx_getLastBlock": "fork_getLastBlock"},
+	})
+	_, err = cl.GetLastBlock()
+	require.NoError(t, err)
+	require.Equal(t, "fork_getLastBlock", got)
+}
+
+// newTestRPCServer serves JSON-RPC requests with handlers keyed by method.
+// A handler returns *jsonrpc.Error to respond with an error.
+func newTestRPCServer(t *testing.T, handlers map[string]func(params json.RawMessage) interface{}) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if h, ok := handlers[req.Method]; !ok {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		} else if res := h(req.Params); isJsonrpcError(res) {
+			resp["error"] = res
+		} else {
+			resp["result"] = res
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient returns a client of uri with opts, failing t on error.
+func newTestClient(t *testing.T, uri string, opts *ClientOptions) *Client {
+	cl, err := NewClientWithOptions(uri, log.New(), opts)
+	require.NoError(t, err)
+	return cl
+}
+
+func isJsonrpcError(v interface{}) bool {
+	_, ok := v.(*jsonrpc.Error)
+	return ok
+}
+
+func TestVerifyResultProof(t *testing.T) {
+	root, proofs := newTestMPT(t, "receipt0", "receipt1", "receipt2")
+	result, err := codec.RLP.MarshalToBytes(&BlockHeaderResult{ReceiptHash: root})
+	require.NoError(t, err)
+	header, err := codec.RLP.MarshalToBytes(&BlockHeader{Height: 10, Result: result})
+	require.NoError(t, err)
+	blockHash := NewHexBytes(crypto.SHA3Sum256(header))
+
+	tamper := false
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(json.RawMessage) interface{} {
+			return header
+		},
+		"icx_getProofForResult": func(params json.RawMessage) interface{} {
+			var p ProofResultParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, blockHash, p.BlockHash)
+			index, err := p.Index.Value()
+			require.NoError(t, err)
+			if tamper {
+				_, forged := newTestMPT(t, "forged0", "forged1")
+				return forged[index]
+			}
+			return proofs[index]
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	receipt, ok, err := cl.VerifyResultProof(context.Background(), 10, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("receipt1"), receipt)
+
+	tamper = true
+	_, ok, err = cl.VerifyResultProof(context.Background(), 10, 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = cl.VerifyResultProof(ctx, 10, 1)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetProofsForResults(t *testing.T) {
+	root, proofs := newTestMPT(t, "receipt0", "receipt1", "receipt2")
+	result, err := codec.RLP.MarshalToBytes(&BlockHeaderResult{ReceiptHash: root})
+	require.NoError(t, err)
+	header, err := codec.RLP.MarshalToBytes(&BlockHeader{Height: 10, Result: result})
+	require.NoError(t, err)
+	blockHash := NewHexBytes(crypto.SHA3Sum256(header))
+
+	var mtx sync.Mutex
+	headerCalls := 0
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			if p.Height != NewHexInt(10) {
+				return &jsonrpc.Error{Code: jsonrpc.ErrorCodeInvalidParams, Message: "no block"}
+			}
+			mtx.Lock()
+			headerCalls++
+			mtx.Unlock()
+			return header
+		},
+		"icx_getProofForResult": func(params json.RawMessage) interface{} {
+			var p ProofResultParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, blockHash, p.BlockHash)
+			index, err := p.Index.Value()
+			require.NoError(t, err)
+			return proofs[index]
+		},
+	})
+	// block hashes are SHA3-256 whatever the Hash of the client
+	cl := newTestClient(t, srv.URL, &ClientOptions{Hash: "keccak-256"})
+
+	locs := []ResultLocation{{10, 2}, {11, 0}, {10, 0}, {10, 1}}
+	rps := cl.GetProofsForResults(context.Background(), locs, 2)
+	require.Len(t, rps, len(locs))
+	for i, rp := range rps {
+		require.Equal(t, locs[i], rp.ResultLocation)
+		if rp.Height != 10 {
+			require.Error(t, rp.Err)
+			continue
+		}
+		require.NoError(t, rp.Err)
+		receipt, err := mptProve(NewHexInt(rp.Index), rp.Proof, root)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("receipt%d", rp.Index), string(receipt))
+	}
+	require.Equal(t, 1, headerCalls)
+}
+
+func TestSendTransactionWithNonce(t *testing.T) {
+	var sent []TransactionParam
+	landed := false
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_sendTransaction": func(params json.RawMessage) interface{} {
+			var p TransactionParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			if sent = append(sent, p); len(sent) == 1 {
+				// the first send is lost, e.g. by timeout
+				return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E1000:timeout"}
+			}
+			return "0x01"
+		},
+		"icx_getTransactionResult": func(json.RawMessage) interface{} {
+			if landed {
+				return &TransactionResult{Status: ResultStatusSuccess}
+			}
+			return &jsonrpc.Error{Code: JsonrpcErrorCodeNotFound, Message: "not found"}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	w := wallet.New()
+	newParam := func() *TransactionParam {
+		return &TransactionParam{
+			Version:     NewHexInt(JsonrpcApiVersion),
+			FromAddress: Address(w.Address()),
+			ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+			StepLimit:   NewHexInt(100000),
+			NetworkID:   NewHexInt(1),
+		}
+	}
+	nonce := NewHexInt(7)
+
+	p := newParam()
+	_, err := cl.SendTransactionWithNonce(w, p, nonce)
+	require.Error(t, err)
+	require.Equal(t, newParam(), p)
+
+	// retry resends the identical signed transaction
+	time.Sleep(time.Millisecond)
+	_, err = cl.SendTransactionWithNonce(w, newParam(), nonce)
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+	require.Equal(t, nonce, sent[1].Nonce)
+	require.Equal(t, sent[0].Timestamp, sent[1].Timestamp)
+	require.Equal(t, sent[0].Signature, sent[1].Signature)
+
+	// no send once the transaction is known to the node
+	landed = true
+	_, err = cl.SendTransactionWithNonce(w, newParam(), nonce)
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+
+	// the nonce is not reused for another transaction until forgotten
+	other := newParam()
+	other.StepLimit = NewHexInt(200000)
+	_, err = cl.SendTransactionWithNonce(w, other, nonce)
+	require.Error(t, err)
+	require.Len(t, sent, 2)
+	cl.sent[nonce].at = time.Now().Add(-sentTxLifetime - time.Second)
+	_, err = cl.SendTransactionWithNonce(w, other, nonce)
+	require.NoError(t, err)
+	require.Len(t, sent, 3)
+	require.Equal(t, NewHexInt(200000), sent[2].StepLimit)
+}
+
+func TestSignTransactionKeepsTimestamp(t *testing.T) {
+	cl := NewClient("http://localhost/api/v3", log.New())
+	w := wallet.New()
+	p := &TransactionParam{
+		Version:     NewHexInt(JsonrpcApiVersion),
+		FromAddress: Address(w.Address()),
+		ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+		StepLimit:   NewHexInt(100000),
+		NetworkID:   NewHexInt(1),
+	}
+	require.NoError(t, cl.SignTransaction(w, p))
+	ts, txh := p.Timestamp, p.TxHash
+	require.NotEmpty(t, ts)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, cl.SignTransaction(w, p))
+	require.Equal(t, ts, p.Timestamp)
+	require.Equal(t, txh, p.TxHash)
+
+	p.Timestamp = ""
+	require.NoError(t, cl.SignTransaction(w, p))
+	require.NotEqual(t, ts, p.Timestamp)
+	require.NotEqual(t, txh, p.TxHash)
+
+	for _, from := range []Address{"hx1234", p.ToAddress[:41], "cx" + p.FromAddress[2:]} {
+		p.FromAddress, p.TxHash = from, ""
+		require.Error(t, cl.SignTransaction(w, p), "from: %q", from)
+		require.Empty(t, p.TxHash)
+	}
+}
+
+func TestSignTransactionExcludes(t *testing.T) {
+	w := wallet.New()
+	newParam := func(nonce int64) *TransactionParam {
+		return &TransactionParam{
+			Version:     NewHexInt(JsonrpcApiVersion),
+			FromAddress: Address(w.Address()),
+			ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+			StepLimit:   NewHexInt(100000),
+			NetworkID:   NewHexInt(1),
+			Timestamp:   NewHexInt(1),
+			Nonce:       NewHexInt(nonce),
+		}
+	}
+	sign := func(cl *Client, p *TransactionParam, excludes map[string]bool) HexBytes {
+		require.NoError(t, cl.SignTransactionWithExcludes(w, p, excludes))
+		return p.TxHash
+	}
+	cl := NewClient("http://localhost/api/v3", log.New())
+	require.NotEqual(t, sign(cl, newParam(1), nil), sign(cl, newParam(2), nil))
+	withoutNonce := map[string]bool{"signature": true, "nonce": true}
+	require.Equal(t, sign(cl, newParam(1), withoutNonce), sign(cl, newParam(2), withoutNonce))
+
+	p := newParam(1)
+	require.NoError(t, cl.SignTransaction(w, p))
+	require.Equal(t, sign(cl, newParam(1), nil), p.TxHash)
+
+	cl = newTestClient(t, "http://localhost/api/v3", &ClientOptions{SerializeExcludes: []string{"nonce"}})
+	p = newParam(2)
+	require.NoError(t, cl.SignTransaction(w, p))
+	require.Equal(t, sign(cl, newParam(1), withoutNonce), p.TxHash)
+}
+
+func TestClientTxBounds(t *testing.T) {
+	w := wallet.New()
+	var sent int
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_sendTransaction": func(json.RawMessage) interface{} {
+			sent++
+			return "0x01"
+		},
+	})
+	cl := newTestClient(t, srv.URL, &ClientOptions{
+		MaxStepLimit: 1000, MaxValue: "1000000000000000000000",
+	})
+	newParam := func(stepLimit, value HexInt) *TransactionParam {
+		return &TransactionParam{
+			Version:     NewHexInt(JsonrpcApiVersion),
+			FromAddress: Address(w.Address()),
+			ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+			StepLimit:   stepLimit,
+			Value:       value,
+			NetworkID:   NewHexInt(1),
+		}
+	}
+	require.NoError(t, cl.SignTransaction(w, newParam(NewHexInt(1000), "0x3635c9adc5dea00000")))
+
+	for _, tc := range []struct {
+		stepLimit, value HexInt
+		field            string
+	}{
+		{NewHexInt(1001), "", "stepLimit"},
+		{NewHexInt(-1), "", "stepLimit"},
+		{"0xzz", "", "stepLimit"},
+		{NewHexInt(1), "0x3635c9adc5dea00001", "value"},
+		{NewHexInt(1), NewHexInt(-1), "value"},
+	} {
+		p := newParam(tc.stepLimit, tc.value)
+		var berr *TxBoundsError
+		require.True(t, errors.As(cl.SignTransaction(w, p), &berr), "%s %s", tc.stepLimit, tc.value)
+		require.Equal(t, tc.field, berr.Field)
+		require.Empty(t, p.Signature)
+		_, err := cl.SendTransaction(p)
+		require.True(t, errors.As(err, &berr))
+	}
+	require.Zero(t, sent)
+
+	// unbounded by default, but still non-negative
+	cl = NewClient(srv.URL, log.New())
+	require.NoError(t, cl.SignTransaction(w, newParam("0x1000000000000000000000000", "")))
+	require.Error(t, cl.SignTransaction(w, newParam(NewHexInt(-1), "")))
+
+	_, err := NewClientWithOptions(srv.URL, log.New(), &ClientOptions{MaxValue: "abc"})
+	require.EqualError(t, err, `invalid max value "abc"`)
+}
+
+func TestClientHash(t *testing.T) {
+	w := wallet.New()
+	p := &TransactionParam{
+		Version:     NewHexInt(JsonrpcApiVersion),
+		FromAddress: Address(w.Address()),
+		ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+		StepLimit:   NewHexInt(100000),
+		NetworkID:   NewHexInt(1),
+		Timestamp:   NewHexInt(1),
+	}
+	hashes := make(map[HexBytes]bool)
+	for _, hash := range []string{"", "sha3-256", "keccak-256"} {
+		cl := newTestClient(t, "http://localhost/api/v3", &ClientOptions{Hash: hash})
+		require.NoError(t, cl.SignTransaction(w, p))
+		hashes[p.TxHash] = true
+	}
+	require.Len(t, hashes, 2, "default is sha3-256")
+
+	_, err := NewClientWithOptions("http://localhost/api/v3", log.New(), &ClientOptions{Hash: "md5"})
+	require.EqualError(t, err, `unknown hash "md5"`)
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	hash := HexBytes("0x" + strings.Repeat("ab", 32))
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockByHash": func(params json.RawMessage) interface{} {
+			var p BlockHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, hash, p.Hash)
+			return map[string]interface{}{"height": 10, "block_hash": hash}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	blk, err := cl.GetBlockByHash(context.Background(), &BlockHashParam{Hash: hash})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), blk.Height)
+	require.Equal(t, hash, blk.BlockHash)
+}
+
+func TestGetTransactionByHash(t *testing.T) {
+	hash := HexBytes("0x" + strings.Repeat("cd", 32))
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getTransactionByHash": func(params json.RawMessage) interface{} {
+			var p TransactionHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, hash, p.Hash)
+			return map[string]interface{}{
+				"version":     "0x3",
+				"from":        "hx0000000000000000000000000000000000000001",
+				"to":          "cx0000000000000000000000000000000000000002",
+				"stepLimit":   "0x186a0",
+				"timestamp":   "0x5f8b7c5c1e3c0",
+				"nid":         "0x1",
+				"signature":   "c2lnbmF0dXJl",
+				"dataType":    "call",
+				"data":        map[string]interface{}{"method": "transfer"},
+				"txHash":      hash,
+				"txIndex":     "0x1",
+				"blockHeight": "0x64",
+				"blockHash":   "0x" + strings.Repeat("ef", 32),
+			}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	tx, err := cl.GetTransactionByHash(context.Background(), &TransactionHashParam{Hash: hash})
+	require.NoError(t, err)
+	require.Equal(t, hash, tx.TxHash)
+	require.Equal(t, Address("hx0000000000000000000000000000000000000001"), tx.FromAddress)
+	require.Equal(t, "call", tx.DataType)
+	require.Equal(t, map[string]interface{}{"method": "transfer"}, tx.Data)
+	height, err := tx.BlockHeight.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(100), height)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cl.GetTransactionByHash(ctx, &TransactionHashParam{Hash: hash})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClientStats(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return &Block{Height: 1}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	_, err := cl.GetLastBlock()
+	require.NoError(t, err)
+	_, err = cl.GetBlockByHeight(&BlockHeightParam{Height: NewHexInt(1)}) // method not found
+	require.Error(t, err)
+
+	stats := cl.Stats()
+	require.Equal(t, srv.URL, stats.Endpoint)
+	require.Equal(t, uint64(2), stats.Requests)
+	require.Equal(t, uint64(0), stats.Errors)
+	require.Greater(t, int64(stats.Latency), int64(0))
+}
+
+func TestSendTransactionShortSystemError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID int64 `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"error":{"code":%d,"message":"E"}}`, req.ID, JsonrpcErrorCodeSystem)
+	}))
+	defer srv.Close()
+
+	cl := NewClient(srv.URL, log.New())
+	_, _, err := cl.SendTransactionAndGetResult(&TransactionParam{})
+	require.Error(t, err)
+	require.False(t, IsDuplicateTransaction(err))
+}
+
+func TestMonitorDialContextCancel(t *testing.T) {
+	// accept tcp connections but never complete the websocket handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cl := NewClient("http://"+ln.Addr().String()+"/api/v3", log.New())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = cl.MonitorBlock(ctx, &BlockRequest{Height: NewHexInt(1)},
+		func(conn *websocket.Conn, v *BlockNotification) error { return nil },
+		func(conn *websocket.Conn) {},
+		func(conn *websocket.Conn, err error) {})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestClientDialTimeout(t *testing.T) {
+	// accept tcp connections but never respond
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cl := newTestClient(t, "http://"+ln.Addr().String()+"/api/v3", &ClientOptions{DialTimeout: 1, Timeout: 1})
+	start := time.Now()
+	err = cl.MonitorBlock(context.Background(), &BlockRequest{Height: NewHexInt(1)},
+		func(conn *websocket.Conn, v *BlockNotification) error { return nil },
+		func(conn *websocket.Conn) {},
+		func(conn *websocket.Conn, err error) {})
+	require.Equal(t, ErrConnectFail, err)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+	start = time.Now()
+	_, err = cl.GetLastBlock()
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
 func TestContextCancel(t *testing.T) {
 	urls := []string{
 		"https://ctz.solidwallet.io/api/v3/icon_dex",
@@ -105,3 +634,653 @@ func TestContextCancel(t *testing.T) {
 // 	require.NoError(t, err)
 // 	fmt.Println(common.HexBytes(votes))
 // }
+
+func TestClientCompression(t *testing.T) {
+	var acceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		body := `{"jsonrpc":"2.0","id":1,"result":"0x64"}`
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(acceptEncoding, "gzip") {
+			fmt.Fprint(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, body)
+		require.NoError(t, zw.Close())
+	}))
+	defer srv.Close()
+
+	for _, disable := range []bool{false, true} {
+		cl := newTestClient(t, srv.URL, &ClientOptions{DisableCompression: disable})
+		balance, err := cl.GetBalance(&AddressParam{Address: "hx0000000000000000000000000000000000000001"})
+		require.NoError(t, err)
+		require.Equal(t, int64(100), balance.Int64())
+		require.Equal(t, !disable, strings.Contains(acceptEncoding, "gzip"))
+	}
+}
+
+func TestClientRequestHeaders(t *testing.T) {
+	var userAgents, reqIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		reqIDs = append(reqIDs, r.Header.Get(HeaderKeyRequestID))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":"0x64"}`)
+	}))
+	defer srv.Close()
+
+	cl := newTestClient(t, srv.URL, &ClientOptions{UserAgent: "relayer-1"})
+	for i := 0; i < 2; i++ {
+		_, err := cl.GetBalance(&AddressParam{Address: "hx0000000000000000000000000000000000000001"})
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"relayer-1", "relayer-1"}, userAgents)
+	require.Len(t, reqIDs, 2)
+	require.Len(t, reqIDs[0], 26)
+	require.NotEqual(t, reqIDs[0], reqIDs[1])
+	require.LessOrEqual(t, reqIDs[0][:10], reqIDs[1][:10])
+}
+
+func TestClientIDGenerator(t *testing.T) {
+	var ids []json.RawMessage
+	var reqIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		ids = append(ids, req.ID)
+		reqIDs = append(reqIDs, r.Header.Get(HeaderKeyRequestID))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x64"}`, req.ID)
+	}))
+	defer srv.Close()
+	call := func(opts *ClientOptions) {
+		ids, reqIDs = nil, nil
+		cl := newTestClient(t, srv.URL, opts)
+		for i := 0; i < 2; i++ {
+			_, err := cl.GetBalance(&AddressParam{Address: "hx0000000000000000000000000000000000000001"})
+			require.NoError(t, err)
+		}
+	}
+
+	call(&ClientOptions{IDGenerator: "monotonic"})
+	require.Equal(t, []json.RawMessage{json.RawMessage("1"), json.RawMessage("2")}, ids)
+	call(&ClientOptions{IDGenerator: "request-id"})
+	for i, id := range ids {
+		require.Equal(t, `"`+reqIDs[i]+`"`, string(id))
+	}
+	call(&ClientOptions{NewID: func(requestID string) interface{} { return "relayer-1/" + requestID }})
+	require.Equal(t, `"relayer-1/`+reqIDs[1]+`"`, string(ids[1]))
+	_, err := NewClientWithOptions(srv.URL, log.New(), &ClientOptions{IDGenerator: "random"})
+	require.EqualError(t, err, `unknown id generator "random"`)
+}
+
+func TestSendTransactions(t *testing.T) {
+	var mtx sync.Mutex
+	sent := make(map[Address][]HexInt)
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_sendTransaction": func(params json.RawMessage) interface{} {
+			var p TransactionParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			mtx.Lock()
+			sent[p.FromAddress] = append(sent[p.FromAddress], p.Nonce)
+			mtx.Unlock()
+			switch {
+			case p.FromAddress == "hx02" && p.Nonce == "0x2":
+				return &jsonrpc.Error{Code: jsonrpc.ErrorCodeInvalidParams, Message: "invalid"}
+			case p.Nonce == "0x3":
+				return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E2000:duplicate"}
+			}
+			return "0x" + string(p.FromAddress[2:]) + string(p.Nonce[2:])
+		},
+		"icx_getTransactionResult": func(params json.RawMessage) interface{} {
+			var p TransactionHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			return &TransactionResult{TxHash: p.Hash, Status: "0x1"}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	var ps []*TransactionParam
+	for nonce := int64(1); nonce <= 3; nonce++ {
+		for _, from := range []Address{"hx01", "hx02"} {
+			ps = append(ps, &TransactionParam{
+				FromAddress: from,
+				Nonce:       NewHexInt(nonce),
+				TxHash:      HexBytes(fmt.Sprintf("0x%s%x", from[2:], nonce)),
+			})
+		}
+	}
+	outcomes := cl.SendTransactions(context.Background(), ps)
+	require.Len(t, outcomes, len(ps))
+	for i, o := range outcomes {
+		if ps[i].FromAddress == "hx02" && ps[i].Nonce == "0x2" {
+			require.Error(t, o.Err)
+			continue
+		}
+		require.NoError(t, o.Err)
+		require.Equal(t, ps[i].TxHash, *o.TxHash)
+		require.Equal(t, ps[i].TxHash, o.Result.TxHash)
+	}
+	for _, from := range []Address{"hx01", "hx02"} {
+		require.Equal(t, []HexInt{"0x1", "0x2", "0x3"}, sent[from])
+	}
+}
+
+func TestEstimateAndSignTransaction(t *testing.T) {
+	var path string
+	var params map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64                  `json:"id"`
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "debug_estimateStep", req.Method)
+		path, params = r.URL.Path, req.Params
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"0x1000"}`, req.ID)
+	}))
+	defer srv.Close()
+	cl := NewClient(srv.URL+"/api/v3/icon_dex", log.New())
+
+	w := wallet.New()
+	p := &TransactionParam{
+		Version:     NewHexInt(JsonrpcApiVersion),
+		FromAddress: Address(w.Address()),
+		ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+		StepLimit:   NewHexInt(1),
+		NetworkID:   NewHexInt(1),
+	}
+	require.NoError(t, cl.EstimateAndSignTransaction(w, p, 1.5))
+	require.Equal(t, "/api/v3d/icon_dex", path)
+	require.NotContains(t, params, "stepLimit")
+	require.NotContains(t, params, "signature")
+	require.Equal(t, string(p.Timestamp), params["timestamp"])
+	require.Equal(t, NewHexInt(0x1800), p.StepLimit)
+	require.NotEmpty(t, p.Signature)
+
+	require.NoError(t, cl.EstimateAndSignTransaction(w, p, 0))
+	require.Equal(t, NewHexInt(0x1000), p.StepLimit)
+}
+
+func TestConcurrentMonitors(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := openRecorder(record)
+	require.NoError(t, err)
+	rec.recordNotification("/block", &BlockNotification{Height: NewHexInt(1)})
+	rs, err := NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+	cl := NewClient(rs.URL(), log.New())
+
+	const n = 4
+	var started, done sync.WaitGroup
+	started.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			var once sync.Once
+			cl.MonitorBlock(context.Background(), &BlockRequest{Height: NewHexInt(1)},
+				func(conn *websocket.Conn, v *BlockNotification) error {
+					once.Do(started.Done)
+					return nil
+				},
+				func(conn *websocket.Conn) {},
+				func(conn *websocket.Conn, err error) {})
+		}()
+	}
+	started.Wait()
+	cl.CloseAllMonitor()
+	done.Wait()
+	cl.mtx.Lock()
+	defer cl.mtx.Unlock()
+	require.Empty(t, cl.conns)
+}
+
+func TestMonitorBlockNilCallbacks(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := openRecorder(record)
+	require.NoError(t, err)
+	rec.recordNotification("/block", &BlockNotification{Height: NewHexInt(1)})
+	rs, err := NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+
+	cl := NewClient(rs.URL(), log.New())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = cl.MonitorBlock(ctx, &BlockRequest{Height: NewHexInt(1)},
+		func(conn *websocket.Conn, v *BlockNotification) error {
+			cancel()
+			return nil
+		}, nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMonitorEventCallbackError(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := openRecorder(record)
+	require.NoError(t, err)
+	rec.recordNotification("/event", &EventNotification{Height: NewHexInt(1)})
+	rs, err := NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+
+	cl := NewClient(rs.URL(), log.New())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cbErr := errors.New("callback failed")
+	err = cl.MonitorEvent(ctx, &EventRequest{Height: NewHexInt(1)},
+		func(conn *websocket.Conn, v *EventNotification) error {
+			return cbErr
+		}, nil)
+	require.ErrorIs(t, err, cbErr)
+}
+
+func TestPollBlock(t *testing.T) {
+	bmc := Address("cx" + strings.Repeat("01", 20))
+	next := "btp://0x1.hmny/0x01"
+	last := int64(2)
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return &Block{Height: last}
+		},
+		"icx_getBlockByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			return map[string]interface{}{
+				"block_hash": fmt.Sprintf("%064x", height),
+				"height":     height,
+				"confirmed_transaction_list": []map[string]interface{}{
+					{"txHash": fmt.Sprintf("0x%02x00", height)},
+					{"txHash": fmt.Sprintf("0x%02x01", height)},
+				},
+			}
+		},
+		"icx_getTransactionResult": func(params json.RawMessage) interface{} {
+			var p TransactionHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			event := func(addr Address, next string) map[string]interface{} {
+				return map[string]interface{}{
+					"scoreAddress": addr,
+					"indexed":      []string{EventSignature, next, "0x1"},
+					"data":         []string{"0x00"},
+				}
+			}
+			if p.Hash != "0x0201" {
+				return map[string]interface{}{"eventLogs": []interface{}{}}
+			}
+			return map[string]interface{}{"eventLogs": []interface{}{
+				event(bmc, "btp://0x2.bsc/0x02"),
+				event(bmc, next),
+				event("cx"+Address(strings.Repeat("02", 20)), next),
+				event(bmc, next),
+			}}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []*BlockNotification
+	err := cl.PollBlock(ctx, &BlockRequest{
+		Height: NewHexInt(1),
+		EventFilters: []*EventFilter{{
+			Addr: bmc, Signature: EventSignature, Indexed: []*string{&next},
+		}},
+	}, 10*time.Millisecond, func(v *BlockNotification) error {
+		if got = append(got, v); len(got) == 2 {
+			last = 3 // a new block on the next poll
+		} else if len(got) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, got, 3)
+	for i, bn := range got {
+		require.Equal(t, NewHexInt(int64(i+1)), bn.Height)
+		require.Equal(t, HexBytes(fmt.Sprintf("0x%064x", i+1)), bn.Hash)
+	}
+	require.Equal(t, [][]HexInt{nil}, got[0].Indexes)
+	require.Equal(t, [][]HexInt{{"0x1"}}, got[1].Indexes)
+	require.Equal(t, [][][]HexInt{{{"0x1", "0x3"}}}, got[1].Events)
+}
+
+func TestGetStepPrice(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_call": func(params json.RawMessage) interface{} {
+			var p struct {
+				To   Address  `json:"to"`
+				Data CallData `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, GovernanceAddress, p.To)
+			require.Equal(t, "getStepPrice", p.Data.Method)
+			return "0x2e90edd00"
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	price, err := cl.GetStepPrice(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(12500000000), price.Int64())
+}
+
+func TestGetNetworkInfo(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getNetworkInfo": func(json.RawMessage) interface{} {
+			return map[string]string{
+				"platform": "icon", "nid": "0x3", "channel": "icon_dex",
+				"earliest": "0x0", "latest": "0x1f", "stepPrice": "0x2e90edd00",
+			}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	ni, err := cl.GetNetworkInfo(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &NetworkInfo{
+		Platform: "icon", NID: "0x3", Channel: "icon_dex",
+		Earliest: "0x0", Latest: "0x1f", StepPrice: "0x2e90edd00",
+	}, ni)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cl.GetNetworkInfo(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClientThrottled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32000,"message":"rate limited"},"id":1}`))
+	}))
+	defer srv.Close()
+	cl := NewClient(srv.URL, log.New())
+
+	start := time.Now()
+	_, err := cl.GetLastBlock()
+	var terr *ThrottledError
+	require.True(t, errors.As(err, &terr), "error: %v", err)
+	require.Equal(t, "icx_getLastBlock", terr.Method)
+	require.Equal(t, time.Second, terr.RetryAfter)
+	// the delay is left to the caller
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, time.Second, retryDelay(fmt.Errorf("wrapped: %w", err), 500*time.Millisecond))
+	require.Equal(t, 2*time.Second, retryDelay(err, 2*time.Second))
+	require.Equal(t, 500*time.Millisecond, retryDelay(errors.New("other"), 500*time.Millisecond))
+
+	now := time.Now()
+	for v, d := range map[string]time.Duration{
+		"":     defaultRetryAfter,
+		"3":    3 * time.Second,
+		"3600": maxRetryAfter,
+		"soon": defaultRetryAfter,
+		now.Add(-time.Second).UTC().Format(http.TimeFormat): 0,
+	} {
+		require.Equal(t, d, parseRetryAfter(v, now), "Retry-After: %q", v)
+	}
+}
+
+func TestWaitForHeight(t *testing.T) {
+	var mtx sync.Mutex
+	height := int64(10)
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			mtx.Lock()
+			defer mtx.Unlock()
+			height++
+			if height == 12 {
+				return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "unavailable"}
+			}
+			return &Block{Height: height}
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+	require.NoError(t, cl.WaitForHeight(context.Background(), 11))
+	require.NoError(t, cl.WaitForHeight(context.Background(), 14))
+	mtx.Lock()
+	require.Equal(t, int64(14), height)
+	mtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, cl.WaitForHeight(ctx, 100), context.DeadlineExceeded)
+}
+
+// otherKeyWallet signs with the key of another wallet than its address.
+type otherKeyWallet struct {
+	Wallet
+	signer Wallet
+}
+
+func (w otherKeyWallet) Sign(data []byte) ([]byte, error) {
+	return w.signer.Sign(data)
+}
+
+func TestSignTransactionVerifySignature(t *testing.T) {
+	w := wallet.New()
+	newParam := func() *TransactionParam {
+		return &TransactionParam{
+			Version:     NewHexInt(JsonrpcApiVersion),
+			FromAddress: Address(w.Address()),
+			ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+			StepLimit:   NewHexInt(100000),
+			NetworkID:   NewHexInt(1),
+		}
+	}
+	misconfigured := otherKeyWallet{Wallet: w, signer: wallet.New()}
+
+	cl := newTestClient(t, "http://localhost/api/v3", &ClientOptions{VerifySignature: true})
+	require.NoError(t, cl.SignTransaction(w, newParam()))
+	p := newParam()
+	err := cl.SignTransaction(misconfigured, p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "signature mismatch")
+	require.Empty(t, p.Signature)
+
+	cl = NewClient("http://localhost/api/v3", log.New())
+	require.NoError(t, cl.SignTransaction(misconfigured, newParam()))
+}
+
+func TestClientWSStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	cl := NewClient(srv.URL, log.New())
+	require.Equal(t, WSStats{}, cl.WSStats())
+
+	var conns []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := cl.wsConnect(context.Background(), "/block", nil)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+	}
+	time.Sleep(10 * time.Millisecond)
+	stats := cl.WSStats()
+	require.Equal(t, 3, stats.Open)
+	require.Equal(t, uint64(3), stats.Connects)
+	require.Equal(t, uint64(2), stats.Reconnects())
+	require.GreaterOrEqual(t, stats.OldestOpen, 10*time.Millisecond)
+	require.Nil(t, stats.Lifetimes)
+
+	cl.CloseMonitor(conns[0])
+	cl.CloseMonitor(conns[0]) // counted once
+	cl.CloseAllMonitor()
+	stats = cl.WSStats()
+	require.Zero(t, stats.Open)
+	require.Zero(t, stats.OldestOpen)
+	require.Equal(t, WSLifetimes{3, 0, 0, 0, 0, 0}, stats.Lifetimes)
+}
+
+func TestMonitorRetryWSResponse(t *testing.T) {
+	var mtx sync.Mutex
+	var codes []int // codes to respond with, then 0
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		mtx.Lock()
+		resp := &WSResponse{}
+		if requests < len(codes) {
+			resp.Code, resp.Message = codes[requests], "failed"
+		}
+		requests++
+		mtx.Unlock()
+		require.NoError(t, conn.WriteJSON(resp))
+		if resp.Code != WSResponseCodeOK {
+			return
+		}
+		conn.WriteJSON(&BlockNotification{Height: br.Height})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	monitor := func(opts *ClientOptions, respond ...int) (int, error) {
+		mtx.Lock()
+		codes, requests = respond, 0
+		mtx.Unlock()
+		cl := newTestClient(t, srv.URL, opts)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := cl.MonitorBlock(ctx, &BlockRequest{Height: NewHexInt(1)},
+			func(conn *websocket.Conn, v *BlockNotification) error {
+				cancel()
+				return nil
+			}, nil, nil)
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests, err
+	}
+
+	// retryable codes are retried up to WSRequestRetry times
+	n, err := monitor(&ClientOptions{WSRequestRetry: 1}, WSResponseCodeSystem)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 2, n)
+	n, err = monitor(&ClientOptions{WSRequestRetry: 1}, WSResponseCodeSystem, WSResponseCodeSystem)
+	require.IsType(t, wsRequestError{}, err)
+	require.Equal(t, 2, n)
+
+	// other codes fail at once
+	n, err = monitor(&ClientOptions{WSRequestRetry: 1}, WSResponseCodeInvalidParams)
+	require.IsType(t, wsRequestError{}, err)
+	require.Equal(t, 1, n)
+	n, err = monitor(&ClientOptions{
+		WSRequestRetry:   1,
+		WSRetryableCodes: []int{WSResponseCodeInvalidParams},
+	}, WSResponseCodeInvalidParams)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 2, n)
+}
+
+func TestClientMaxMonitors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		require.NoError(t, conn.WriteJSON(&WSResponse{}))
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	// monitor runs a block monitor until ctx is done, signaling connected
+	monitor := func(ctx context.Context, cl *Client, connected chan<- struct{}) error {
+		return cl.MonitorBlock(ctx, &BlockRequest{Height: NewHexInt(1)},
+			func(*websocket.Conn, *BlockNotification) error { return nil },
+			func(*websocket.Conn) { connected <- struct{}{} }, nil)
+	}
+
+	for _, failFast := range []bool{false, true} {
+		cl := newTestClient(t, srv.URL, &ClientOptions{MaxMonitors: 1, MonitorFailFast: failFast})
+		ctx1, cancel1 := context.WithCancel(context.Background())
+		connected := make(chan struct{}, 2)
+		errCh := make(chan error, 1)
+		go func() { errCh <- monitor(ctx1, cl, connected) }()
+		<-connected
+
+		ctx2, cancel2 := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err := monitor(ctx2, cl, connected)
+		cancel2()
+		if failFast {
+			require.ErrorIs(t, err, ErrTooManyMonitors)
+		} else {
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		require.Equal(t, 1, cl.WSStats().Open)
+
+		// the slot is released once the first monitor finishes
+		cancel1()
+		cl.CloseAllMonitor() // unblock its read
+		require.Error(t, <-errCh)
+		go func() {
+			<-connected
+			cl.CloseAllMonitor()
+		}()
+		require.Error(t, monitor(context.Background(), cl, connected))
+		require.Zero(t, cl.WSStats().Open)
+	}
+}
+
+func TestWaitForResultsBackoff(t *testing.T) {
+	var mtx sync.Mutex
+	var polls []time.Time
+	pending := 3
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getTransactionResult": func(json.RawMessage) interface{} {
+			mtx.Lock()
+			defer mtx.Unlock()
+			if polls = append(polls, time.Now()); len(polls) <= pending {
+				return &jsonrpc.Error{Code: JsonrpcErrorCodePending, Message: "pending"}
+			}
+			return &TransactionResult{Status: ResultStatusSuccess}
+		},
+	})
+	cl := newTestClient(t, srv.URL, &ClientOptions{ResultWaitTimeout: 1})
+	cl.resultPollInterval, cl.resultPollMaxInterval = 50*time.Millisecond, 150*time.Millisecond
+
+	_, txr, err := cl.WaitForResults(context.Background(), &TransactionHashParam{Hash: "0x01"})
+	require.NoError(t, err)
+	require.Equal(t, HexInt(ResultStatusSuccess), txr.Status)
+	require.Len(t, polls, 4)
+	// intervals of 100ms, 150ms (capped) and 150ms
+	require.GreaterOrEqual(t, polls[2].Sub(polls[1]), 150*time.Millisecond)
+	require.GreaterOrEqual(t, polls[3].Sub(polls[2]), 150*time.Millisecond)
+
+	polls, pending = nil, 100
+	start := time.Now()
+	_, _, err = cl.WaitForResults(context.Background(), &TransactionHashParam{Hash: "0x01"})
+	var terr *ResultTimeoutError
+	require.True(t, errors.As(err, &terr), "error: %v", err)
+	require.Equal(t, HexBytes("0x01"), terr.Hash)
+	require.GreaterOrEqual(t, terr.Waited, time.Second)
+	require.Less(t, time.Since(start), 2*time.Second)
+	require.Equal(t, len(polls), terr.Attempts)
+	require.True(t, hasJsonrpcErrorCode(err, JsonrpcErrorCodePending))
+}
@@ -0,0 +1,99 @@
+package icon
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ReceiverDebugState is the internal state of a receiver, as dumped by its
+// DebugHandler.
+type ReceiverDebugState struct {
+	// Running is true while a receiveLoop runs, the fields below up to
+	// Reconnects being those of the last one.
+	Running bool  `json:"running"`
+	Next    int64 `json:"next"` // height of the next block to forward
+	// BlockResults and BlockNotifications are the numbers of block results
+	// and notifications buffered, of capacity CatchUpBatchSize and
+	// SyncConcurrency.
+	BlockResults       int `json:"blockResults"`
+	BlockNotifications int `json:"blockNotifications"`
+	// BufferedMessages is the number of messages buffered by Subscribe, of
+	// capacity MessageBufferSize.
+	BufferedMessages int `json:"bufferedMessages"`
+	// Reconnects counts the reconnections of the block monitor, after the
+	// first connection.
+	Reconnects  uint64    `json:"reconnects"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+
+	Verifier  *VerifierStatus `json:"verifier,omitempty"`
+	Endpoints []EndpointStats `json:"endpoints"`
+	// Monitors are those running on the clients of the receiver, the one
+	// of the receiveLoop being live unless a reconnect is pending.
+	Monitors []MonitorInfo `json:"monitors"`
+}
+
+// receiverDebug is the part of ReceiverDebugState updated by receiveLoop.
+type receiverDebug struct {
+	running    bool
+	next       int64
+	depths     func() (results, notifications int) // nil unless running
+	buffered   func() int                          // nil unless buffering
+	reconnects uint64
+	lastErr    error
+	lastErrAt  time.Time
+}
+
+func (r *receiver) updateDebug(update func(d *receiverDebug)) {
+	r.debugMtx.Lock()
+	defer r.debugMtx.Unlock()
+	update(&r.debug)
+}
+
+func (r *receiver) setDebugError(err error) {
+	r.updateDebug(func(d *receiverDebug) {
+		d.lastErr, d.lastErrAt = err, time.Now()
+	})
+}
+
+// DebugState returns the current internal state of the receiver.
+func (r *receiver) DebugState() *ReceiverDebugState {
+	r.debugMtx.Lock()
+	s := &ReceiverDebugState{
+		Running:     r.debug.running,
+		Next:        r.debug.next,
+		Reconnects:  r.debug.reconnects,
+		LastErrorAt: r.debug.lastErrAt,
+	}
+	if r.debug.depths != nil {
+		s.BlockResults, s.BlockNotifications = r.debug.depths()
+	}
+	if r.debug.buffered != nil {
+		s.BufferedMessages = r.debug.buffered()
+	}
+	if r.debug.lastErr != nil {
+		s.LastError = r.debug.lastErr.Error()
+	}
+	r.debugMtx.Unlock()
+	s.Verifier = r.VerifierStatus()
+	s.Endpoints = r.EndpointStats()
+	s.Monitors = []MonitorInfo{}
+	for _, cl := range r.clients() {
+		s.Monitors = append(s.Monitors, cl.Monitors()...)
+	}
+	return s
+}
+
+// DebugHandler returns a handler responding with the DebugState of the
+// receiver in JSON, e.g. to be mounted on an admin port.
+func (r *receiver) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r.DebugState()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package icon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiverDebugHandler(t *testing.T) {
+	r, node := newTestNodeReceiver(t, [][]uint64{{1}, nil, {2}}, ReceiverOptions{})
+	dump := func() *ReceiverDebugState {
+		w := httptest.NewRecorder()
+		r.DebugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var s ReceiverDebugState
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+		return &s
+	}
+	s := dump()
+	require.False(t, s.Running)
+	require.Equal(t, node.URL, s.Endpoints[0].Endpoint)
+	require.Empty(t, s.Monitors)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msgCh := make(chan *chain.Message)
+	errCh, err := r.Subscribe(ctx, msgCh, chain.SubscribeOptions{Height: 1})
+	require.NoError(t, err)
+	for height := uint64(0); height < 3; {
+		select {
+		case err := <-errCh:
+			t.Fatalf("subscription failed: %v", err)
+		case msg := <-msgCh:
+			height = msg.Receipts[len(msg.Receipts)-1].Height
+		}
+	}
+	s = dump()
+	require.True(t, s.Running)
+	require.GreaterOrEqual(t, s.Next, int64(3))
+	require.Zero(t, s.Reconnects)
+	require.Empty(t, s.LastError)
+	require.NotZero(t, s.Endpoints[0].Requests)
+	require.Len(t, s.Monitors, 1)
+	m := s.Monitors[0]
+	require.Equal(t, "/block", m.URL)
+	require.Equal(t, int64(1), m.StartHeight)
+	require.Equal(t, int64(3), m.LastHeight)
+	require.Equal(t, uint64(3), m.Notifications)
+	require.False(t, m.LastNotified.Before(m.Started))
+
+	cancel()
+	_, ok := <-errCh
+	require.False(t, ok)
+	require.False(t, dump().Running)
+	r.cl.CloseAllMonitor()
+	require.Eventually(t, func() bool {
+		return len(dump().Monitors) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -17,9 +17,15 @@
 package icon
 
 import (
+	stderrors "errors"
 	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/icon-project/icon-bridge/common/errors"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
 )
 
 var (
@@ -28,8 +34,257 @@ var (
 	ErrSendFailByFuture       = fmt.Errorf("reject by future")
 	ErrSendFailByOverflow     = fmt.Errorf("reject by overflow")
 	ErrGetResultFailByPending = fmt.Errorf("fail to getresult by pending")
+	ErrTooManyMonitors        = fmt.Errorf("too many monitors")
+	ErrMessageBufferFull      = fmt.Errorf("message buffer full")
+
+	// matched by EventMatchError and ReceiptEventCountError
+	ErrEventMatchFailed          = fmt.Errorf("event match failed")
+	ErrReceiptEventCountMismatch = fmt.Errorf("receipt event count mismatch")
 )
 
+// SequenceGapError is returned by a subscription receiving an event with a
+// sequence past the expected one.
+type SequenceGapError struct {
+	Expected uint64
+	Got      uint64
+	Height   uint64 // of the event with sequence Got
+	// Started is false if no event was received before, as when
+	// subscribing from a height past the event with sequence Expected.
+	Started bool
+}
+
+func (e *SequenceGapError) Error() string {
+	if !e.Started {
+		return fmt.Sprintf("sequence gap: expected %d never found, got %d at height %d",
+			e.Expected, e.Got, e.Height)
+	}
+	return fmt.Sprintf("sequence gap: expected %d, got %d at height %d",
+		e.Expected, e.Got, e.Height)
+}
+
+// SyncStuckError is returned by a receiver whose verifier failed to fetch
+// the block at Height in SyncMaxAttempts consecutive windows of its sync.
+// The verifier can't skip a block, since it needs the validators of each
+// block to verify the next one.
+type SyncStuckError struct {
+	Height   int64
+	Attempts int
+	Err      error // last error fetching the block, nil on window timeouts
+}
+
+func (e *SyncStuckError) Error() string {
+	return fmt.Sprintf("sync stuck at height %d after %d attempts: %v",
+		e.Height, e.Attempts, e.Err)
+}
+
+func (e *SyncStuckError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownError is returned by ReceiverManager.StopAll with the errors of
+// the subscriptions, by name, that failed or didn't terminate in time.
+type ShutdownError struct {
+	Names []string
+	Errs  []error
+}
+
+func (e *ShutdownError) add(name string, err error) {
+	e.Names = append(e.Names, name)
+	e.Errs = append(e.Errs, err)
+}
+
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = fmt.Sprintf("%s: %v", e.Names[i], err)
+	}
+	return "shutdown: " + strings.Join(msgs, "; ")
+}
+
+// DataHashMismatchError is returned when the data served by
+// icx_getDataByHash doesn't hash to the requested hash, as for a corrupted
+// or truncated response.
+type DataHashMismatchError struct {
+	Hash []byte
+	Len  int // of the data served
+}
+
+func (e *DataHashMismatchError) Error() string {
+	return fmt.Sprintf("invalid data: hash=%#x, len=%d", e.Hash, e.Len)
+}
+
+// ResultTimeoutError is returned by WaitForResults when the transaction is
+// still pending after the ResultWaitTimeout of the client.
+type ResultTimeoutError struct {
+	Hash     HexBytes
+	Waited   time.Duration
+	Attempts int
+	Err      error // last error of GetTransactionResult
+}
+
+func (e *ResultTimeoutError) Error() string {
+	return fmt.Sprintf("timeout waiting for result of transaction %s: waited %v, %d attempts: %v",
+		e.Hash, e.Waited, e.Attempts, e.Err)
+}
+
+func (e *ResultTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// ThrottledError is returned by a Client whose request was answered with
+// HTTP 429. The request is to be retried no sooner than RetryAfter.
+type ThrottledError struct {
+	Method     string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled %s: retry after %v: %v", e.Method, e.RetryAfter, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// TxBoundsError is returned by a Client for a transaction whose Field is
+// not a non-negative integer of at most Max, if not nil, before it is
+// signed or sent.
+type TxBoundsError struct {
+	Field string
+	Value HexInt
+	Max   *big.Int
+}
+
+func (e *TxBoundsError) Error() string {
+	if e.Max == nil {
+		return fmt.Sprintf("invalid transaction %s: %q", e.Field, e.Value)
+	}
+	return fmt.Sprintf("invalid transaction %s: %q, max=%#x", e.Field, e.Value, e.Max)
+}
+
+// StaleBlockError is returned for a block notified with a timestamp older
+// than the MaxBlockAge of the receiver, once it caught up with the chain, as
+// for a replay of old notifications by a misbehaving node.
+type StaleBlockError struct {
+	Height int64
+	Age    time.Duration
+	MaxAge time.Duration
+}
+
+func (e *StaleBlockError) Error() string {
+	return fmt.Sprintf("stale block: height=%d, age=%v, maxAge=%v",
+		e.Height, e.Age, e.MaxAge)
+}
+
+// EventMatchError is returned for an event of a receipt, notified as
+// matching the event filter of the receiver, whose Field ("addr", "sig" or
+// "next") doesn't match it. It matches ErrEventMatchFailed with errors.Is.
+type EventMatchError struct {
+	Height   int64
+	Index    HexInt // of the receipt in the block
+	Event    int    // of the event in the receipt
+	Field    string
+	Got      []byte
+	Expected []byte
+}
+
+func (e *EventMatchError) Error() string {
+	return fmt.Sprintf("invalid event: height=%d, index=%s, event=%d, %s=%#x, expected=%#x",
+		e.Height, e.Index, e.Event, e.Field, e.Got, e.Expected)
+}
+
+func (e *EventMatchError) Is(target error) bool {
+	return target == ErrEventMatchFailed
+}
+
+// ReceiptEventCountError is returned for a receipt of which only Got of the
+// Expected events notified or proved were taken, Skipped being those for
+// another destination. It matches ErrReceiptEventCountMismatch with
+// errors.Is.
+type ReceiptEventCountError struct {
+	Height   int64
+	Index    HexInt // of the receipt in the block
+	Got      int
+	Skipped  int
+	Expected int
+}
+
+func (e *ReceiptEventCountError) Error() string {
+	return fmt.Sprintf("failed to verify all events for the receipt: height=%d, index=%s, got=%d, skipped=%d, expected=%d",
+		e.Height, e.Index, e.Got, e.Skipped, e.Expected)
+}
+
+func (e *ReceiptEventCountError) Is(target error) bool {
+	return target == ErrReceiptEventCountMismatch
+}
+
+// SystemErrorCodeOf returns the sub-code of err if it is, or wraps,
+// a JsonrpcErrorCodeSystem error with a well-formed message.
+func SystemErrorCodeOf(err error) (SystemErrorCode, bool) {
+	var je *jsonrpc.Error
+	if !stderrors.As(err, &je) || je.Code != JsonrpcErrorCodeSystem {
+		return 0, false
+	}
+	return parseSystemErrorCode(je.Message)
+}
+
+// parseSystemErrorCode parses the sub-code from a message like "E2000:...".
+func parseSystemErrorCode(msg string) (SystemErrorCode, bool) {
+	if len(msg) < 5 {
+		return 0, false
+	}
+	c, err := strconv.ParseInt(msg[1:5], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return SystemErrorCode(c), true
+}
+
+func hasJsonrpcErrorCode(err error, codes ...jsonrpc.ErrorCode) bool {
+	var je *jsonrpc.Error
+	if !stderrors.As(err, &je) {
+		return false
+	}
+	for _, c := range codes {
+		if je.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSystemErrorCode(err error, code SystemErrorCode) bool {
+	c, ok := SystemErrorCodeOf(err)
+	return ok && c == code
+}
+
+func IsDuplicateTransaction(err error) bool {
+	return hasSystemErrorCode(err, DuplicateTransactionError)
+}
+
+func IsOverflow(err error) bool {
+	return stderrors.Is(err, ErrSendFailByOverflow) ||
+		hasJsonrpcErrorCode(err, JsonrpcErrorCodeTxPoolOverflow) ||
+		hasSystemErrorCode(err, TransactionPoolOverflowError)
+}
+
+func IsExpired(err error) bool {
+	return stderrors.Is(err, ErrSendFailByExpired) ||
+		hasSystemErrorCode(err, ExpiredTransactionError)
+}
+
+func IsFuture(err error) bool {
+	return stderrors.Is(err, ErrSendFailByFuture) ||
+		hasSystemErrorCode(err, FutureTransactionError)
+}
+
+// IsPending reports whether err means the transaction result is not ready yet.
+func IsPending(err error) bool {
+	return stderrors.Is(err, ErrGetResultFailByPending) ||
+		hasJsonrpcErrorCode(err, JsonrpcErrorCodePending, JsonrpcErrorCodeExecuting)
+}
+
 const (
 	CodeBTP      errors.Code = 0
 	CodeBMC      errors.Code = 10
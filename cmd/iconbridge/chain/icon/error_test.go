@@ -0,0 +1,55 @@
+package icon
+
+import (
+	"testing"
+
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemErrorCodeOf(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code SystemErrorCode
+		ok   bool
+	}{
+		{&jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E2000:DuplicateTransaction"}, DuplicateTransactionError, true},
+		{errors.Wrap(&jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E2002:Expired"}, "send"), ExpiredTransactionError, true},
+		{&jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E20"}, 0, false},
+		{&jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: ""}, 0, false},
+		{&jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "Exxxx:"}, 0, false},
+		{&jsonrpc.Error{Code: JsonrpcErrorCodeScore, Message: "E2000:"}, 0, false},
+		{errors.New("E2000:DuplicateTransaction"), 0, false},
+		{nil, 0, false},
+	} {
+		code, ok := SystemErrorCodeOf(tc.err)
+		require.Equal(t, tc.ok, ok, "err: %v", tc.err)
+		require.Equal(t, tc.code, code, "err: %v", tc.err)
+	}
+}
+
+func TestErrorClassifiers(t *testing.T) {
+	system := func(msg string) error {
+		return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: msg}
+	}
+	require.True(t, IsDuplicateTransaction(system("E2000:duplicate")))
+	require.False(t, IsDuplicateTransaction(system("E2001:overflow")))
+	require.True(t, IsOverflow(system("E2001:overflow")))
+	require.True(t, IsOverflow(&jsonrpc.Error{Code: JsonrpcErrorCodeTxPoolOverflow}))
+	require.True(t, IsOverflow(errors.Wrap(ErrSendFailByOverflow, "send")))
+	require.True(t, IsExpired(system("E2002:expired")))
+	require.True(t, IsFuture(system("E2003:future")))
+	require.True(t, IsPending(&jsonrpc.Error{Code: JsonrpcErrorCodeExecuting}))
+	require.False(t, IsPending(system("E")))
+}
+
+func TestSequenceGapError(t *testing.T) {
+	err := errors.Wrapf(&SequenceGapError{Expected: 5, Got: 7, Height: 100}, "callback")
+	var sge *SequenceGapError
+	require.True(t, errors.As(err, &sge))
+	require.Equal(t, "sequence gap: expected 5 never found, got 7 at height 100", sge.Error())
+
+	sge.Started = true
+	require.Equal(t, "sequence gap: expected 5, got 7 at height 100", sge.Error())
+}
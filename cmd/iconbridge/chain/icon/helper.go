@@ -2,21 +2,48 @@ package icon
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/icon-project/goloop/common"
 	vlcodec "github.com/icon-project/goloop/common/codec"
 	"github.com/icon-project/goloop/common/db"
 	"github.com/icon-project/goloop/common/trie/ompt"
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/icon-project/icon-bridge/common/crypto"
+	"github.com/icon-project/icon-bridge/common/intconv"
+	"github.com/pkg/errors"
 )
 
+// mptProve proves the value of key in the merkle patricia trie of root hash
+// with proofs. The proofs must start with the root node of the trie.
 func mptProve(key HexInt, proofs [][]byte, hash []byte) ([]byte, error) {
+	if len(hash) == 0 {
+		return nil, fmt.Errorf("mptProve: empty root hash: key=%s", key)
+	}
+	if len(proofs) == 0 {
+		return nil, fmt.Errorf("mptProve: empty proofs: key=%s, root=%s", key, common.HexBytes(hash))
+	}
+	for i, proof := range proofs {
+		if len(proof) == 0 {
+			return nil, fmt.Errorf("mptProve: empty proof at %d: key=%s, root=%s", i, key, common.HexBytes(hash))
+		}
+	}
+	if computed := crypto.SHA3Sum256(proofs[0]); !bytes.Equal(computed, hash) {
+		return nil, fmt.Errorf("mptProve: root mismatch: key=%s, expected=%s, computed=%s",
+			key, common.HexBytes(hash), common.HexBytes(computed))
+	}
 	db := db.NewMapDB()
 	defer db.Close()
 	index, err := key.Value()
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "mptProve: invalid key=%s", key)
 	}
 	indexKey, err := vlcodec.RLP.MarshalToBytes(index)
 	if err != nil {
@@ -25,12 +52,217 @@ func mptProve(key HexInt, proofs [][]byte, hash []byte) ([]byte, error) {
 	mpt := ompt.NewMPTForBytes(db, hash)
 	trie, err1 := mpt.Prove(indexKey, proofs)
 	if err1 != nil {
-		return nil, err1
+		return nil, errors.Wrapf(err1, "mptProve: key=%s, root=%s, proofs=%d", key, common.HexBytes(hash), len(proofs))
 
 	}
 	return trie, nil
 }
 
+// BTPToIconAddress returns the ICON address of the contract in a BTP address
+// of the form btp://<nid>.icon/<address>.
+func BTPToIconAddress(ba chain.BTPAddress) (Address, error) {
+	if p := ba.Protocol(); p != "btp" {
+		return "", fmt.Errorf("invalid btp address: %q: unsupported protocol: %q", ba, p)
+	}
+	switch bc := ba.BlockChain(); bc {
+	case "icon", "iconee":
+	default:
+		return "", fmt.Errorf("invalid btp address: %q: unsupported blockchain: %q", ba, bc)
+	}
+	addr := Address(ba.ContractAddress())
+	if _, err := addr.Value(); err != nil {
+		return "", errors.Wrapf(err, "invalid btp address: %q: %v", ba, err)
+	}
+	return addr, nil
+}
+
+// ValidateAddress checks that a is a well-formed ICON address: "hx" for an
+// account or "cx" for a contract followed by 40 lowercase hex digits.
+func ValidateAddress(a Address) error {
+	if len(a) != 42 {
+		return fmt.Errorf("invalid address %q: length %d", string(a), len(a))
+	}
+	if p := a[:2]; p != "hx" && p != "cx" {
+		return fmt.Errorf("invalid address %q: prefix %q", string(a), p)
+	}
+	for _, c := range a[2:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Errorf("invalid address %q: character %q", string(a), c)
+		}
+	}
+	return nil
+}
+
+// ParseEventLogs decodes the event logs of a transaction result into the
+// form of the receipts: the signature as a string, followed by the indexed
+// and data values as bytes according to their types in the signature; str
+// as UTF-8, int and bool as big-endian two's complement, bytes as is and
+// Address as its 21-byte form.
+func ParseEventLogs(tr *TransactionResult) ([]*EventLog, error) {
+	els := make([]*EventLog, 0, len(tr.EventLogs))
+	for i, l := range tr.EventLogs {
+		el, err := parseEventLog(l.Addr, l.Indexed, l.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "eventLogs[%d]: %v", i, err)
+		}
+		els = append(els, el)
+	}
+	return els, nil
+}
+
+func parseEventLog(addr Address, indexed, data []string) (*EventLog, error) {
+	if len(indexed) == 0 {
+		return nil, errors.New("missing signature")
+	}
+	sig := indexed[0]
+	types, err := signatureTypes(sig)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(indexed) - 1 + len(data); n != len(types) {
+		return nil, fmt.Errorf("signature %q: got %d values", sig, n)
+	}
+	a, err := addr.Value()
+	if err != nil {
+		return nil, err
+	}
+	el := &EventLog{Addr: a, Indexed: [][]byte{[]byte(sig)}}
+	values := append(append([]string(nil), indexed[1:]...), data...)
+	for i, v := range values {
+		b, err := decodeEventValue(types[i], v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signature %q: value %d: %v", sig, i, err)
+		}
+		if i < len(indexed)-1 {
+			el.Indexed = append(el.Indexed, b)
+		} else {
+			el.Data = append(el.Data, b)
+		}
+	}
+	return el, nil
+}
+
+// signatureTypes returns the types of the parameters of an event signature
+// like "Message(str,int,bytes)".
+func signatureTypes(sig string) ([]string, error) {
+	open, end := strings.IndexByte(sig, '('), len(sig)-1
+	if open < 1 || sig[end] != ')' {
+		return nil, fmt.Errorf("invalid signature %q", sig)
+	}
+	var types []string
+	if params := sig[open+1 : end]; params != "" {
+		types = strings.Split(params, ",")
+	}
+	return types, nil
+}
+
+func decodeEventValue(typ, v string) ([]byte, error) {
+	switch typ {
+	case "str":
+		return []byte(v), nil
+	case "int", "bool":
+		n, err := HexInt(v).BigInt()
+		if err != nil {
+			return nil, err
+		}
+		return intconv.BigIntToBytes(n), nil
+	case "bytes":
+		return HexBytes(v).Value()
+	case "Address":
+		return Address(v).Value()
+	default:
+		return nil, fmt.Errorf("unsupported type %q", typ)
+	}
+}
+
+// IconToBTPAddress returns the BTP address of addr on the ICON network
+// identified by network, e.g. "0x1.icon".
+func IconToBTPAddress(network string, addr Address) (chain.BTPAddress, error) {
+	ba := chain.BTPAddress("btp://" + network + "/" + string(addr))
+	if ba.NetworkAddress() != network || ba.NetworkID() == "" {
+		return "", fmt.Errorf("invalid network address: %q", network)
+	}
+	if _, err := BTPToIconAddress(ba); err != nil {
+		return "", err
+	}
+	return ba, nil
+}
+
+// BuildMessageEventFilter returns the filter of the BTP message events of
+// signature emitted by the BMC contractAddr to dstAddr, as requested to the
+// node and as matched against the event logs of the receipts. The signature
+// must have the layout of EventSignature: the next BTP address (str) and
+// the sequence (int) indexed, followed by the message.
+func BuildMessageEventFilter(contractAddr Address, dstAddr chain.BTPAddress, signature string) (*EventFilter, eventLogRawFilter, error) {
+	if err := ValidateAddress(contractAddr); err != nil {
+		return nil, eventLogRawFilter{}, err
+	} else if !strings.HasPrefix(string(contractAddr), "cx") {
+		return nil, eventLogRawFilter{}, fmt.Errorf("not a contract address %q", contractAddr)
+	}
+	if p := dstAddr.Protocol(); p != "btp" || dstAddr.ContractAddress() == "" {
+		return nil, eventLogRawFilter{}, fmt.Errorf("invalid btp address: %q", dstAddr)
+	}
+	types, err := signatureTypes(signature)
+	if err != nil {
+		return nil, eventLogRawFilter{}, err
+	}
+	if len(types) <= EventIndexSequence ||
+		types[EventIndexNext-1] != "str" || types[EventIndexSequence-1] != "int" {
+		return nil, eventLogRawFilter{}, fmt.Errorf(
+			"signature %q: expected (str,int,...) as in %q", signature, EventSignature)
+	}
+	addr, err := contractAddr.Value()
+	if err != nil {
+		return nil, eventLogRawFilter{}, err
+	}
+	next := dstAddr.String()
+	ef := &EventFilter{
+		Addr:      contractAddr,
+		Signature: signature,
+		Indexed:   []*string{&next},
+	}
+	return ef, eventLogRawFilter{
+		addr:      addr,
+		signature: []byte(signature),
+		next:      []byte(next),
+	}, nil
+}
+
+// logLimiter limits repeated logs of the same kind to one per interval.
+// A nil logLimiter allows everything.
+type logLimiter struct {
+	interval   time.Duration
+	mtx        sync.Mutex
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+func newLogLimiter(interval time.Duration) *logLimiter {
+	return &logLimiter{
+		interval:   interval,
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// Allow reports whether a log of kind key may be written now, and if so the
+// number of logs of the kind suppressed since the last one allowed.
+func (l *logLimiter) Allow(key string) (bool, int) {
+	if l == nil {
+		return true, 0
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now()
+	if last, ok := l.last[key]; ok && now.Sub(last) < l.interval {
+		l.suppressed[key]++
+		return false, 0
+	}
+	suppressed := l.suppressed[key]
+	l.last[key], l.suppressed[key] = now, 0
+	return true, suppressed
+}
+
 func listContains(list []common.HexBytes, data common.HexBytes) bool {
 	for _, current := range list {
 		if bytes.Equal(data, current) {
@@ -50,3 +282,23 @@ func isUnexpectedEOFError(err error) bool {
 	}
 	return false
 }
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID of t: a 48-bit millisecond timestamp followed by 80
+// random bits, encoded as 26 characters of Crockford's base32, so that ids
+// sort by time.
+func newULID(t time.Time) string {
+	var b [16]byte
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	rand.Read(b[6:])
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockfordBase32[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
@@ -0,0 +1,196 @@
+package icon
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	vlcodec "github.com/icon-project/goloop/common/codec"
+	"github.com/icon-project/goloop/common/db"
+	"github.com/icon-project/goloop/common/trie/ompt"
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMPT(t *testing.T, values ...string) (root []byte, proofs [][][]byte) {
+	mpt := ompt.NewMPTForBytes(db.NewMapDB(), nil)
+	for i, v := range values {
+		k, err := vlcodec.RLP.MarshalToBytes(int64(i))
+		require.NoError(t, err)
+		_, err = mpt.Set(k, []byte(v))
+		require.NoError(t, err)
+	}
+	ss := mpt.GetSnapshot()
+	for i := range values {
+		k, err := vlcodec.RLP.MarshalToBytes(int64(i))
+		require.NoError(t, err)
+		proofs = append(proofs, ss.GetProof(k))
+	}
+	return ss.Hash(), proofs
+}
+
+func TestMPTProve(t *testing.T) {
+	root, proofs := newTestMPT(t, "receipt0", "receipt1", "receipt2")
+
+	v, err := mptProve(NewHexInt(1), proofs[1], root)
+	require.NoError(t, err)
+	require.Equal(t, []byte("receipt1"), v)
+
+	_, err = mptProve(NewHexInt(1), nil, root)
+	require.EqualError(t, err, "mptProve: empty proofs: key=0x1, root="+string(NewHexBytes(root)))
+
+	_, err = mptProve(NewHexInt(1), proofs[1], nil)
+	require.Error(t, err)
+
+	other, _ := newTestMPT(t, "stale")
+	_, err = mptProve(NewHexInt(1), proofs[1], other)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "root mismatch")
+	require.Contains(t, err.Error(), string(NewHexBytes(other)))
+}
+
+func TestBTPAddressConversion(t *testing.T) {
+	const contract = Address("cx997849d3920d338ed81800833fbb270c785e743d")
+
+	ba, err := IconToBTPAddress("0x1.icon", contract)
+	require.NoError(t, err)
+	require.Equal(t, chain.BTPAddress("btp://0x1.icon/"+contract), ba)
+	addr, err := BTPToIconAddress(ba)
+	require.NoError(t, err)
+	require.Equal(t, contract, addr)
+
+	for _, ba := range []chain.BTPAddress{
+		"",
+		"0x1.icon/" + chain.BTPAddress(contract),
+		"http://0x1.icon/" + chain.BTPAddress(contract),
+		"btp://0x63564c40.hmny/0xa69712a3813d0505bbD55AeD3fd8471Bc2f722DD",
+		"btp://0x1.icon/",
+		"btp://0x1.icon/cx1234",
+		"btp://0x1.icon/xx997849d3920d338ed81800833fbb270c785e743d",
+	} {
+		_, err := BTPToIconAddress(ba)
+		require.Error(t, err, "address: %q", ba)
+	}
+
+	for _, network := range []string{"", "icon", "0x1.hmny", "0x1.icon/cx"} {
+		_, err := IconToBTPAddress(network, contract)
+		require.Error(t, err, "network: %q", network)
+	}
+	_, err = IconToBTPAddress("0x1.icon", "cx1234")
+	require.Error(t, err)
+}
+
+func TestValidateAddress(t *testing.T) {
+	for _, a := range []Address{
+		"hx0000000000000000000000000000000000000001",
+		"cx997849d3920d338ed81800833fbb270c785e743d",
+	} {
+		require.NoError(t, ValidateAddress(a), "address: %q", a)
+	}
+	for _, a := range []Address{
+		"",
+		"hx1234",
+		"0x997849d3920d338ed81800833fbb270c785e743d",
+		"cx997849D3920D338ED81800833FBB270C785E743D",
+		"hx997849d3920d338ed81800833fbb270c785e743g",
+		"hx997849d3920d338ed81800833fbb270c785e743d00",
+	} {
+		require.Error(t, ValidateAddress(a), "address: %q", a)
+	}
+}
+
+func TestBuildMessageEventFilter(t *testing.T) {
+	bmc := Address("cx" + strings.Repeat("01", 20))
+	dst := chain.BTPAddress("btp://0x1.hmny/0x01")
+	ef, filter, err := BuildMessageEventFilter(bmc, dst, EventSignature)
+	require.NoError(t, err)
+	require.Equal(t, bmc, ef.Addr)
+	require.Equal(t, EventSignature, ef.Signature)
+	require.Len(t, ef.Indexed, 1)
+	require.Equal(t, string(dst), *ef.Indexed[0])
+
+	// the event logs matched by the node are matched by the receiver
+	tr := &TransactionResult{}
+	require.NoError(t, json.Unmarshal([]byte(`{"eventLogs": [{
+		"scoreAddress": "`+string(bmc)+`",
+		"indexed": ["`+EventSignature+`", "`+string(dst)+`", "0x5"],
+		"data": ["0x6d7367"]
+	}]}`), tr))
+	require.True(t, ef.match(tr.EventLogs[0].Addr, tr.EventLogs[0].Indexed, tr.EventLogs[0].Data))
+	els, err := ParseEventLogs(tr)
+	require.NoError(t, err)
+	filter.seq = 5
+	r := &receiver{log: log.New()}
+	receipt, err := r.newReceipt(10, NewHexInt(0), els, &filter)
+	require.NoError(t, err)
+	require.Len(t, receipt.Events, 1)
+	require.Equal(t, uint64(5), receipt.Events[0].Sequence)
+
+	for _, tc := range []struct {
+		addr Address
+		dst  chain.BTPAddress
+		sig  string
+	}{
+		{"hx" + bmc[2:], dst, EventSignature},
+		{"cx01", dst, EventSignature},
+		{bmc, "0x1.hmny/0x01", EventSignature},
+		{bmc, dst, "Message"},
+		{bmc, dst, "Message(str,int)"},
+		{bmc, dst, "Message(int,str,bytes)"},
+	} {
+		_, _, err := BuildMessageEventFilter(tc.addr, tc.dst, tc.sig)
+		require.Error(t, err, "%+v", tc)
+	}
+}
+
+func TestLogLimiter(t *testing.T) {
+	l := newLogLimiter(time.Hour)
+	ok, suppressed := l.Allow("addr")
+	require.True(t, ok)
+	require.Equal(t, 0, suppressed)
+	for i := 0; i < 3; i++ {
+		ok, _ = l.Allow("addr")
+		require.False(t, ok)
+	}
+	ok, _ = l.Allow("sig")
+	require.True(t, ok, "kinds are limited independently")
+
+	l.last["addr"] = time.Now().Add(-2 * time.Hour)
+	ok, suppressed = l.Allow("addr")
+	require.True(t, ok)
+	require.Equal(t, 3, suppressed)
+
+	ok, _ = (*logLimiter)(nil).Allow("addr")
+	require.True(t, ok)
+}
+
+func TestParseEventLogs(t *testing.T) {
+	from := Address("hx" + strings.Repeat("02", 20))
+	bts := Address("cx" + strings.Repeat("01", 20))
+	var tr TransactionResult
+	require.NoError(t, json.Unmarshal([]byte(`{"eventLogs": [{
+		"scoreAddress": "`+string(bts)+`",
+		"indexed": ["TransferStart(Address,str,int,bytes)", "`+string(from)+`"],
+		"data": ["btp://0x1.hmny/0x01", "0x1ff", "0xc0"]
+	}]}`), &tr))
+	els, err := ParseEventLogs(&tr)
+	require.NoError(t, err)
+	require.Len(t, els, 1)
+	addr, _ := bts.Value()
+	fromBytes, _ := from.Value()
+	require.Equal(t, &EventLog{
+		Addr:    addr,
+		Indexed: [][]byte{[]byte("TransferStart(Address,str,int,bytes)"), fromBytes},
+		Data:    [][]byte{[]byte("btp://0x1.hmny/0x01"), {0x01, 0xff}, {0xc0}},
+	}, els[0])
+
+	tr.EventLogs[0].Data = tr.EventLogs[0].Data[:2]
+	_, err = ParseEventLogs(&tr)
+	require.Error(t, err)
+	tr.EventLogs[0].Indexed[0] = "TransferStart(Address,str,int,uint)"
+	tr.EventLogs[0].Data = append(tr.EventLogs[0].Data, "0x0")
+	_, err = ParseEventLogs(&tr)
+	require.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package icon
+
+import (
+	"context"
+	"sync"
+
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/pkg/errors"
+)
+
+// ReceiverManager tracks the subscriptions of several receivers, e.g. one
+// per link of a relayer, so that they are stopped together by StopAll
+// instead of cancelling their contexts one by one.
+type ReceiverManager struct {
+	mtx  sync.Mutex
+	subs []*managedSubscription
+}
+
+type managedSubscription struct {
+	name    string
+	r       chain.Receiver
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed by StopAll before cancelling it
+	done    chan struct{} // closed once the subscription terminated
+	err     error         // last error received after StopAll cancelled it
+}
+
+// NewReceiverManager returns a manager tracking no subscription yet.
+func NewReceiverManager() *ReceiverManager {
+	return &ReceiverManager{}
+}
+
+// Subscribe subscribes to r like r.Subscribe, with a context cancelled by
+// StopAll, and tracks the subscription under name until it terminates. The
+// errors of the subscription are forwarded to the returned channel, which is
+// closed when it terminates; those received once StopAll cancelled it are
+// reported by StopAll instead.
+func (m *ReceiverManager) Subscribe(
+	ctx context.Context, name string, r chain.Receiver,
+	msgCh chan<- *chain.Message, opts chain.SubscribeOptions) (<-chan error, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	errCh, err := r.Subscribe(ctx, msgCh, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	sub := &managedSubscription{
+		name:    name,
+		r:       r,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	m.mtx.Lock()
+	m.subs = append(m.subs, sub)
+	m.mtx.Unlock()
+
+	fwdCh := make(chan error)
+	go func() {
+		defer close(sub.done)
+		defer close(fwdCh)
+		defer m.remove(sub)
+		for err := range errCh {
+			select {
+			case fwdCh <- err:
+			case <-sub.stopped:
+				if !errors.Is(err, context.Canceled) {
+					sub.err = err
+				}
+			}
+		}
+	}()
+	return fwdCh, nil
+}
+
+// remove stops tracking sub, once terminated by itself or by StopAll.
+func (m *ReceiverManager) remove(sub *managedSubscription) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i, s := range m.subs {
+		if s == sub {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// StopAll cancels all the subscriptions and waits for them to terminate,
+// until ctx is done, then closes the websockets of their receivers that
+// are still open. It returns a ShutdownError listing the subscriptions that
+// failed or didn't terminate in time, if any.
+func (m *ReceiverManager) StopAll(ctx context.Context) error {
+	m.mtx.Lock()
+	subs := m.subs
+	m.subs = nil
+	m.mtx.Unlock()
+
+	for _, sub := range subs {
+		close(sub.stopped)
+		sub.cancel()
+	}
+	serr := &ShutdownError{}
+	for _, sub := range subs {
+		select {
+		case <-sub.done:
+			if sub.err != nil {
+				serr.add(sub.name, sub.err)
+			}
+		case <-ctx.Done():
+			serr.add(sub.name, errors.Wrapf(ctx.Err(), "not terminated: %v", ctx.Err()))
+		}
+	}
+	for _, sub := range subs {
+		if r, ok := sub.r.(interface{ CloseMonitors() }); ok {
+			r.CloseMonitors()
+		}
+	}
+	if len(serr.Errs) > 0 {
+		return serr
+	}
+	return nil
+}
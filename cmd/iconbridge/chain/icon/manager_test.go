@@ -0,0 +1,95 @@
+package icon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReceiver terminates its subscriptions on cancel, after sending
+// stopErr if not nil, unless hang is set.
+type fakeReceiver struct {
+	stopErr error
+	hang    bool
+	closed  bool
+}
+
+func (f *fakeReceiver) Subscribe(ctx context.Context, msgCh chan<- *chain.Message, opts chain.SubscribeOptions) (<-chan error, error) {
+	errCh := make(chan error)
+	go func() {
+		<-ctx.Done()
+		if f.hang {
+			return
+		}
+		defer close(errCh)
+		if f.stopErr != nil {
+			errCh <- f.stopErr
+		}
+		errCh <- ctx.Err()
+	}()
+	return errCh, nil
+}
+
+func (f *fakeReceiver) CloseMonitors() {
+	f.closed = true
+}
+
+func TestReceiverManagerStopAll(t *testing.T) {
+	m := NewReceiverManager()
+	subscribe := func(name string, r *fakeReceiver) <-chan error {
+		errCh, err := m.Subscribe(context.Background(), name, r, nil, chain.SubscribeOptions{})
+		require.NoError(t, err)
+		return errCh
+	}
+	clean, failing, hanging := &fakeReceiver{}, &fakeReceiver{stopErr: errors.New("failed")}, &fakeReceiver{hang: true}
+	errChs := []<-chan error{
+		subscribe("clean", clean),
+		subscribe("failing", failing),
+		subscribe("hanging", hanging),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := m.StopAll(ctx)
+	var serr *ShutdownError
+	require.True(t, errors.As(err, &serr), "error: %v", err)
+	require.Equal(t, []string{"failing", "hanging"}, serr.Names)
+	require.EqualError(t, serr.Errs[0], "failed")
+	require.ErrorIs(t, serr.Errs[1], context.DeadlineExceeded)
+	require.True(t, clean.closed && failing.closed && hanging.closed)
+	for _, errCh := range errChs[:2] {
+		_, ok := <-errCh
+		require.False(t, ok)
+	}
+
+	// the subscriptions stopped are no longer tracked
+	require.NoError(t, m.StopAll(context.Background()))
+}
+
+func TestReceiverManagerSubscriptionEnded(t *testing.T) {
+	m := NewReceiverManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &fakeReceiver{stopErr: errors.New("failed")}
+	errCh, err := m.Subscribe(ctx, "ended", r, nil, chain.SubscribeOptions{})
+	require.NoError(t, err)
+
+	// cancelled by the caller, not StopAll: the errors are still forwarded
+	cancel()
+	require.EqualError(t, <-errCh, "failed")
+	require.ErrorIs(t, <-errCh, context.Canceled)
+	_, ok := <-errCh
+	require.False(t, ok)
+
+	// and the subscription is no longer tracked
+	require.Eventually(t, func() bool {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		return len(m.subs) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, m.StopAll(context.Background()))
+	require.False(t, r.closed)
+}
@@ -0,0 +1,57 @@
+package icon
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket of burst tokens refilled at rate tokens per
+// second, a request taking one token.
+type rateLimiter struct {
+	mtx    sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time // of the last refill
+}
+
+// newRateLimiter returns a full bucket, with a burst of at least 1.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait takes a token, waiting for it to be refilled if the bucket is empty.
+// It returns ctx.Err() without taking one if ctx is done first.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	l.mtx.Lock()
+	now := time.Now()
+	if l.tokens += now.Sub(l.last).Seconds() * l.rate; l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mtx.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mtx.Lock()
+		l.tokens++ // not taken
+		l.mtx.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,72 @@
+package icon
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(50, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		require.NoError(t, l.Wait(context.Background()))
+	}
+	// 2 at once, then 4 refilled every 20ms
+	require.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	l = newRateLimiter(1, 1)
+	require.NoError(t, l.Wait(ctx))
+	start = time.Now()
+	require.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestClientRateLimit(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getNetworkInfo": func(json.RawMessage) interface{} {
+			return map[string]string{"nid": "0x3"}
+		},
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return map[string]interface{}{"height": 1}
+		},
+	})
+	cl := newTestClient(t, srv.URL, &ClientOptions{
+		RateLimit:        100,
+		MethodRateLimits: map[string]float64{"icx_getNetworkInfo": 1},
+	})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := cl.GetLastBlock()
+		require.NoError(t, err)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	_, err := cl.GetNetworkInfo(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = cl.GetNetworkInfo(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientRateLimitContext(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getVotesByHeight": func(json.RawMessage) interface{} { return "0x00" },
+	})
+	cl := newTestClient(t, srv.URL, &ClientOptions{
+		MethodRateLimits: map[string]float64{"icx_getVotesByHeight": 1},
+	})
+	p := &BlockHeightParam{Height: NewHexInt(1)}
+	_, err := cl.GetVotesByHeight(context.Background(), p)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = cl.GetVotesByHeight(ctx, p)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
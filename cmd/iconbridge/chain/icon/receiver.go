@@ -21,13 +21,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"runtime/debug"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/icon-project/goloop/common"
 	"github.com/icon-project/goloop/common/codec"
 	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
+	"github.com/icon-project/icon-bridge/common/intconv"
 	"github.com/icon-project/icon-bridge/common/log"
 	"github.com/pkg/errors"
 )
@@ -43,11 +49,134 @@ const (
 const RECONNECT_ON_UNEXPECTED_HEIGHT = "Unexpected Block Height. Should Reconnect"
 const (
 	MonitorBlockMaxConcurrency = 300
+	mismatchLogInterval        = time.Minute
+	DefaultPollInterval        = 1 // seconds
+	blockObserverBuffer        = 64
 )
 
 type ReceiverOptions struct {
-	SyncConcurrency uint64           `json:"syncConcurrency"`
-	Verifier        *VerifierOptions `json:"verifier"`
+	SyncConcurrency uint64 `json:"syncConcurrency"`
+	// CatchUpBatchSize is the max number of block notifications processed
+	// per iteration of receiveLoop, independent of the notification buffer
+	// size (SyncConcurrency). Results are still sorted by height and only
+	// forwarded contiguously from the next expected height, so a larger
+	// batch does not relax ordering; it only delays the first callback of
+	// the batch until all of its blocks have been fetched.
+	// Defaults to SyncConcurrency; clamped to [1, MonitorBlockMaxConcurrency].
+	CatchUpBatchSize uint64           `json:"catchUpBatchSize"`
+	Verifier         *VerifierOptions `json:"verifier"`
+	Client           *ClientOptions   `json:"client"`
+	// EventMonitor subscribes to matching events (/event) instead of every
+	// block (/block) and fetches proofs only for those. It requires no
+	// verifier since the headers of the skipped blocks are never fetched.
+	// Falls back to block monitoring if the node doesn't support it.
+	EventMonitor bool `json:"eventMonitor"`
+	// ProofConcurrency limits the number of concurrent GetProofForEvents
+	// calls across all blocks being fetched. Zero means no limit other
+	// than the one implied by SyncConcurrency.
+	ProofConcurrency uint64 `json:"proofConcurrency"`
+	// MaxBufferedBytes bounds the approximate memory held by the fetched
+	// block results waiting to be forwarded. The number of blocks fetched
+	// per batch is reduced according to the average size of recent results,
+	// so a slow consumer slows down fetching instead of growing the buffers.
+	// Zero means the batch is bounded only by CatchUpBatchSize.
+	MaxBufferedBytes uint64 `json:"maxBufferedBytes"`
+	// TolerateExtraProofs accepts, with a warning, a response of
+	// icx_getProofForEvents having more proofs than requested as returned
+	// by some node versions; the extra trailing proofs are ignored.
+	// By default the number of proofs must match exactly.
+	TolerateExtraProofs bool `json:"tolerateExtraProofs"`
+	// FailOnGap makes the subscription fail if a block can't be fetched
+	// after the RPCCallRetry retries, since the blocks after it can't be
+	// delivered in order. By default the receiver reconnects and fetches
+	// again from the missing block.
+	FailOnGap bool `json:"failOnGap"`
+	// SyncWindowTimeout is the time in seconds allowed to fetch each window
+	// of SyncConcurrency blocks while syncing the verifier. On timeout the
+	// progress is logged, the blocks fetched so far are verified and the
+	// rest are fetched again. Zero means no timeout.
+	SyncWindowTimeout uint64 `json:"syncWindowTimeout"`
+	// SeqIndex is the path of a file recording the height of each event
+	// received by sequence. A subscription then starts from the height of
+	// the last event before the requested sequence if it is past the
+	// requested height, skipping the blocks in between.
+	SeqIndex string `json:"seqIndex"`
+	// RPCCallRetry is the number of times the requests fetching a block or
+	// event are retried before giving up on it, zero for no retry. Defaults
+	// to RPCCallRetry if not set.
+	RPCCallRetry *uint64 `json:"rpcCallRetry"`
+	// PreserveResults keeps the block results already fetched when the
+	// receiver reconnects, instead of discarding them, and reuses those
+	// notified again with the same hash rather than fetching them again.
+	// The results are still verified and forwarded in order.
+	PreserveResults bool `json:"preserveResults"`
+	// TrustNode takes the events from the transaction results served by
+	// the node instead of proving them with icx_getProofForEvents, which
+	// cuts the requests per event. It REDUCES SECURITY: a faulty or
+	// malicious node can forge or drop messages undetected, so use it only
+	// with a node under your control. Ignored if a verifier is configured.
+	TrustNode bool `json:"trustNode"`
+	// Polling discovers the blocks by polling the node over HTTP instead of
+	// monitoring them over websocket, for nodes without websocket support.
+	// The results of all the transactions of each block are fetched to
+	// find the events, so it is much heavier than monitoring.
+	// EventMonitor is ignored with Polling.
+	Polling bool `json:"polling"`
+	// PollingFallback switches to Polling if the websocket connection to
+	// the node fails.
+	PollingFallback bool `json:"pollingFallback"`
+	// PollInterval is the time in seconds between polls of the last block.
+	// Defaults to DefaultPollInterval.
+	PollInterval uint64 `json:"pollInterval"`
+	// StrictOrdering retries fetching the blocks of a batch until all of
+	// them are fetched before forwarding any, so that no block is skipped
+	// or fetched again from a reconnect, at the cost of stalling on a
+	// block that can't be fetched. By default a block failing after
+	// RPCCallRetry retries makes the receiver reconnect from it (or fail
+	// with FailOnGap), after forwarding nothing past it.
+	StrictOrdering bool `json:"strictOrdering"`
+	// SyncMaxAttempts is the number of consecutive windows of the verifier
+	// sync allowed to fail fetching the next block to verify, after which
+	// the subscription fails with a SyncStuckError. Zero means retrying
+	// forever; each failure is logged with the height and error anyway.
+	SyncMaxAttempts uint64 `json:"syncMaxAttempts"`
+	// MaxBlockGap is the number of blocks the head of the chain may be
+	// ahead of the next block to process when (re)connecting, beyond which
+	// the blocks up to the head are fetched by polling (see Polling) before
+	// monitoring resumes from the head, rather than having the websocket
+	// replay them. Zero disables it.
+	MaxBlockGap uint64 `json:"maxBlockGap"`
+	// CheckReceiptStatus skips, with a warning, the events of the receipts
+	// of failed transactions instead of forwarding them. By default the
+	// status is not checked, as the node drops the events of a failed
+	// transaction anyway.
+	CheckReceiptStatus bool `json:"checkReceiptStatus"`
+	// InitialSyncConcurrency is the number of blocks fetched per window of
+	// the first verifier sync of a subscription, the cold start from
+	// VerifierOptions.BlockHeight, which may be far behind.
+	// SteadySyncConcurrency is the window of the syncs on reconnects.
+	// Both default to SyncConcurrency; clamped to
+	// [1, MonitorBlockMaxConcurrency].
+	InitialSyncConcurrency uint64 `json:"initialSyncConcurrency"`
+	SteadySyncConcurrency  uint64 `json:"steadySyncConcurrency"`
+	// MaxBlockAge is the max age in seconds, by its timestamp, of a block
+	// notified once the receiver caught up with the chain, that is once it
+	// forwarded a block younger than that. An older block is rejected with
+	// a StaleBlockError, like a block failing to be fetched but without
+	// fetching it again, to guard against a node replaying old
+	// notifications: the node is reconnected to, up to RPCCallRetry times in
+	// a row, before the error is returned by Subscribe. The header of each
+	// block is then fetched. Zero disables it.
+	MaxBlockAge uint64 `json:"maxBlockAge"`
+	// MessageBufferSize is the number of messages buffered by Subscribe
+	// for a slow consumer of msgCh, so that the blocks keep being fetched
+	// meanwhile instead of stalling the monitor. Zero means no buffer.
+	MessageBufferSize uint64 `json:"messageBufferSize"`
+	// FailOnFullBuffer makes the subscription fail with
+	// ErrMessageBufferFull when a message can't be buffered, to be
+	// resubscribed from the last message received, rather than waiting for
+	// the consumer. Ignored without MessageBufferSize.
+	FailOnFullBuffer bool `json:"failOnFullBuffer"`
 }
 
 func (opts *ReceiverOptions) Unmarshal(v map[string]interface{}) error {
@@ -58,6 +187,14 @@ func (opts *ReceiverOptions) Unmarshal(v map[string]interface{}) error {
 	return json.Unmarshal(b, opts)
 }
 
+// rpcCallRetry returns the RPCCallRetry of opts, the default if not set.
+func (opts *ReceiverOptions) rpcCallRetry() int {
+	if opts.RPCCallRetry == nil {
+		return RPCCallRetry
+	}
+	return int(*opts.RPCCallRetry)
+}
+
 type eventLogRawFilter struct {
 	addr      []byte
 	signature []byte
@@ -66,66 +203,256 @@ type eventLogRawFilter struct {
 }
 
 type receiver struct {
+	head      uint64 // accessed atomically; first for 64-bit alignment
 	log       log.Logger
 	src       chain.BTPAddress
 	dst       chain.BTPAddress
-	cl        *Client
+	cl        *Client   // primary client, connected to the first url
+	cls       []*Client // clients of all urls, to spread heavy reads
 	opts      ReceiverOptions
 	blockReq  BlockRequest
 	logFilter eventLogRawFilter
+
+	mismatchLog *logLimiter
+
+	seqIdx *seqIndex // nil if SeqIndex is not set
+
+	pauseMtx sync.Mutex
+	resumeCh chan struct{} // non-nil while paused
+
+	proofSem chan struct{} // nil if ProofConcurrency is not limited
+
+	vrMtx sync.RWMutex
+	vr    *Verifier // verifier of the running receiveLoop
+
+	stateMtx sync.Mutex
+	stateCb  func(ConnStateEvent)
+
+	observerMtx sync.Mutex
+	observer    func(*BlockNotification)
+
+	resolverMtx   sync.Mutex
+	proofResolver ProofResolver
+
+	debugMtx sync.Mutex
+	debug    receiverDebug
+}
+
+// ConnState is the state of the websocket connection of a receiver.
+type ConnState int
+
+const (
+	ConnStateConnecting ConnState = iota
+	ConnStateConnected
+	ConnStateReconnecting
+	ConnStateDisconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnStateConnecting:
+		return "connecting"
+	case ConnStateConnected:
+		return "connected"
+	case ConnStateReconnecting:
+		return "reconnecting"
+	case ConnStateDisconnected:
+		return "disconnected"
+	default:
+		return fmt.Sprintf("ConnState(%d)", int(s))
+	}
+}
+
+// ConnStateEvent is a transition of the websocket connection of a receiver.
+type ConnStateEvent struct {
+	State    ConnState
+	Endpoint string
+	Reason   error // cause of disconnection, if known
+}
+
+// SetStateCallback sets cb to be called on each transition of the websocket
+// connection, replacing any previous callback; nil removes it. cb is called
+// from the receiving goroutines and must not block.
+func (r *receiver) SetStateCallback(cb func(ConnStateEvent)) {
+	r.stateMtx.Lock()
+	defer r.stateMtx.Unlock()
+	r.stateCb = cb
+}
+
+func (r *receiver) setState(state ConnState, reason error) {
+	r.stateMtx.Lock()
+	cb := r.stateCb
+	r.stateMtx.Unlock()
+	if cb != nil {
+		cb(ConnStateEvent{State: state, Endpoint: r.cl.Endpoint, Reason: reason})
+	}
+}
+
+// SetBlockObserver sets cb to be called with each block notification
+// received, before it is processed, replacing any previous observer; nil
+// removes it. cb is called from a separate goroutine and must not modify the
+// notification; notifications are dropped while it is too slow to keep up.
+func (r *receiver) SetBlockObserver(cb func(*BlockNotification)) {
+	r.observerMtx.Lock()
+	defer r.observerMtx.Unlock()
+	r.observer = cb
+}
+
+func (r *receiver) blockObserver() func(*BlockNotification) {
+	r.observerMtx.Lock()
+	defer r.observerMtx.Unlock()
+	return r.observer
+}
+
+// CloseMonitors closes the websockets of the monitors of all the clients,
+// as left open by a subscription that didn't terminate on cancel.
+func (r *receiver) CloseMonitors() {
+	for _, cl := range r.clients() {
+		cl.CloseAllMonitor()
+	}
+}
+
+// VerifierStatus returns the status of the verifier of the running
+// subscription, or nil if there is none or no verifier is configured.
+func (r *receiver) VerifierStatus() *VerifierStatus {
+	r.vrMtx.RLock()
+	defer r.vrMtx.RUnlock()
+	if r.vr == nil {
+		return nil
+	}
+	return r.vr.Status()
+}
+
+func (r *receiver) setVerifier(vr *Verifier) {
+	r.vrMtx.Lock()
+	defer r.vrMtx.Unlock()
+	r.vr = vr
+}
+
+// Pause stops forwarding messages to the subscriber without closing the
+// websocket or resetting the verifier. Block notifications are buffered
+// until the buffers fill up, after which the receiver stops advancing.
+func (r *receiver) Pause() {
+	r.pauseMtx.Lock()
+	defer r.pauseMtx.Unlock()
+	if r.resumeCh == nil {
+		r.resumeCh = make(chan struct{})
+		r.log.Info("receiver: paused")
+	}
+}
+
+// Resume continues forwarding messages from where Pause left off.
+func (r *receiver) Resume() {
+	r.pauseMtx.Lock()
+	defer r.pauseMtx.Unlock()
+	if r.resumeCh != nil {
+		close(r.resumeCh)
+		r.resumeCh = nil
+		r.log.Info("receiver: resumed")
+	}
+}
+
+// waitIfPaused blocks while the receiver is paused or until ctx is done.
+func (r *receiver) waitIfPaused(ctx context.Context) error {
+	r.pauseMtx.Lock()
+	resumeCh := r.resumeCh
+	r.pauseMtx.Unlock()
+	if resumeCh == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumeCh:
+		return nil
+	}
 }
 
 func NewReceiver(src, dst chain.BTPAddress, urls []string, rawOpts json.RawMessage, l log.Logger) (chain.Receiver, error) {
 	if len(urls) == 0 {
 		return nil, errors.New("List of Urls is empty")
 	}
-	client := NewClient(urls[0], l)
-
 	var recvOpts ReceiverOptions
 	if err := json.Unmarshal(rawOpts, &recvOpts); err != nil {
 		return nil, errors.Wrapf(err, "recvOpts.Unmarshal: %v", err)
 	}
+	clients := make([]*Client, 0, len(urls))
+	for _, url := range urls {
+		cl, err := NewClientWithOptions(url, l, recvOpts.Client)
+		if err != nil {
+			return nil, errors.Wrapf(err, "NewClientWithOptions: %v", err)
+		}
+		clients = append(clients, cl)
+	}
+	client := clients[0]
 
-	dstAddr := dst.String()
-	ef := &EventFilter{
-		Addr:      Address(src.ContractAddress()),
-		Signature: EventSignature,
-		Indexed:   []*string{&dstAddr},
+	srcAddr, err := BTPToIconAddress(src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "BTPToIconAddress: %v", err)
+	}
+	ef, logFilter, err := BuildMessageEventFilter(srcAddr, dst, EventSignature)
+	if err != nil {
+		return nil, errors.Wrapf(err, "BuildMessageEventFilter: %v", err)
 	}
 	evtReq := BlockRequest{
 		EventFilters: []*EventFilter{ef},
 	} // fill height later
 
-	efAddr, err := ef.Addr.Value()
-	if err != nil {
-		return nil, errors.Wrapf(err, "ef.Addr.Value: %v", err)
-	}
-
 	if recvOpts.SyncConcurrency < 1 {
 		recvOpts.SyncConcurrency = 1
 	} else if recvOpts.SyncConcurrency > MonitorBlockMaxConcurrency {
 		recvOpts.SyncConcurrency = MonitorBlockMaxConcurrency
 	}
+	for _, c := range []*uint64{&recvOpts.InitialSyncConcurrency, &recvOpts.SteadySyncConcurrency} {
+		if *c < 1 {
+			*c = recvOpts.SyncConcurrency
+		} else if *c > MonitorBlockMaxConcurrency {
+			*c = MonitorBlockMaxConcurrency
+		}
+	}
+	if recvOpts.CatchUpBatchSize < 1 {
+		recvOpts.CatchUpBatchSize = recvOpts.SyncConcurrency
+	} else if recvOpts.CatchUpBatchSize > MonitorBlockMaxConcurrency {
+		recvOpts.CatchUpBatchSize = MonitorBlockMaxConcurrency
+	}
+	if recvOpts.PollInterval < 1 {
+		recvOpts.PollInterval = DefaultPollInterval
+	}
+	if recvOpts.TrustNode && recvOpts.Verifier == nil {
+		l.Warn("trustNode: events are taken from the node without proofs")
+	}
 
 	recvr := &receiver{
-		log:      l,
-		src:      src,
-		dst:      dst,
-		cl:       client,
-		opts:     recvOpts,
-		blockReq: evtReq,
-		logFilter: eventLogRawFilter{
-			addr:      efAddr,
-			signature: []byte(EventSignature),
-			next:      []byte(dstAddr),
-		}, // fill seq later
+		log:         l,
+		src:         src,
+		dst:         dst,
+		cl:          client,
+		cls:         clients,
+		opts:        recvOpts,
+		blockReq:    evtReq,
+		logFilter:   logFilter, // fill seq later
+		mismatchLog: newLogLimiter(mismatchLogInterval),
+	}
+	if recvOpts.ProofConcurrency > 0 {
+		recvr.proofSem = make(chan struct{}, recvOpts.ProofConcurrency)
+	}
+	if recvOpts.SeqIndex != "" {
+		if recvr.seqIdx, err = loadSeqIndex(recvOpts.SeqIndex); err != nil {
+			return nil, errors.Wrapf(err, "loadSeqIndex: %v", err)
+		}
 	}
 
 	return recvr, nil
 }
 
-func (r *receiver) newVerifer(opts *VerifierOptions) (*Verifier, error) {
-	validators, err := r.cl.getValidatorsByHash(opts.ValidatorsHash)
+func (r *receiver) newVerifer(ctx context.Context, opts *VerifierOptions) (*Verifier, error) {
+	if err := r.cl.checkVerifierValidatorsHash(ctx, opts); err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	validators, err := r.getValidatorsByHash(ctx, opts.ValidatorsHash)
 	if err != nil {
 		return nil, err
 	}
@@ -135,12 +462,17 @@ func (r *receiver) newVerifer(opts *VerifierOptions) (*Verifier, error) {
 		validators: map[string][]common.Address{
 			opts.ValidatorsHash.String(): validators,
 		},
+		hashes:        []string{opts.ValidatorsHash.String()},
+		maxValidators: opts.ValidatorsCacheSize,
+	}
+	if vr.maxValidators < 1 {
+		vr.maxValidators = DefaultValidatorsCacheSize
 	}
-	header, err := r.cl.getBlockHeaderByHeight(int64(vr.next))
+	header, err := r.cl.getBlockHeaderByHeight(ctx, int64(vr.next))
 	if err != nil {
 		return nil, err
 	}
-	votes, err := r.cl.GetVotesByHeight(
+	votes, err := r.cl.GetVotesByHeight(ctx,
 		&BlockHeightParam{Height: NewHexInt(vr.next)})
 	if err != nil {
 		return nil, err
@@ -155,10 +487,16 @@ func (r *receiver) newVerifer(opts *VerifierOptions) (*Verifier, error) {
 	return &vr, nil
 }
 
-func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
+// syncVerifier verifies the blocks up to height, fetching them by windows
+// of window blocks, or SyncConcurrency if zero. It returns ctx.Err() if ctx
+// is done first.
+func (r *receiver) syncVerifier(ctx context.Context, vr *Verifier, height int64, window uint64) error {
 	if height == vr.Next() {
 		return nil
 	}
+	if window < 1 {
+		window = r.opts.SyncConcurrency
+	}
 	if vr.Next() > height {
 		return fmt.Errorf(
 			"invalid target height: verifier height (%d) > target height (%d)",
@@ -179,15 +517,46 @@ func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
 		retry  int64
 	}
 
-	r.log.WithFields(log.Fields{"height": vr.Next(), "target": height}).Info("syncVerifier: start")
+	r.log.WithFields(log.Fields{
+		"height": vr.Next(), "target": height, "window": window,
+	}).Info("syncVerifier: start")
 
+	attempts := 0 // consecutive windows failing to fetch the next block
 	for vr.Next() < height {
-		rqch := make(chan *req, r.opts.SyncConcurrency)
+		next := vr.Next()
+		var nextErr error // of the next block, if failed
+		rqch := make(chan *req, window)
 		for i := vr.Next(); len(rqch) < cap(rqch); i++ {
 			rqch <- &req{height: i}
 		}
 		sres := make([]*res, 0, len(rqch))
-		for q := range rqch {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if r.opts.SyncWindowTimeout > 0 {
+			timer = time.NewTimer(time.Duration(r.opts.SyncWindowTimeout) * time.Second)
+			timeout = timer.C
+		}
+	window:
+		for {
+			var q *req
+			select {
+			case q = <-rqch:
+				if q == nil { // closed
+					break window
+				}
+			case <-ctx.Done():
+				// pending requests short-circuit; rqch is large enough for
+				// them to be sent back without blocking.
+				return ctx.Err()
+			case <-timeout:
+				// pending requests are abandoned; rqch is large enough for
+				// them to be sent back without blocking.
+				r.log.WithFields(log.Fields{
+					"height": vr.Next(), "target": height,
+					"fetched": len(sres), "window": cap(sres),
+				}).Warn("syncVerifier: window timeout, retrying")
+				break window
+			}
 			switch {
 			case q.err != nil:
 				if q.retry > 0 {
@@ -198,6 +567,9 @@ func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
 				}
 				r.log.WithFields(log.Fields{
 					"height": q.height, "error": q.err.Error()}).Debug("syncVerifier: req error")
+				if q.height == next {
+					nextErr = q.err
+				}
 				sres = append(sres, nil)
 				if len(sres) == cap(sres) {
 					close(rqch)
@@ -210,26 +582,33 @@ func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
 			default:
 				go func(q *req) {
 					defer func() {
-						time.Sleep(500 * time.Millisecond)
+						select {
+						case <-time.After(retryDelay(q.err, 500*time.Millisecond)):
+						case <-ctx.Done():
+						}
 						rqch <- q
 					}()
+					defer r.recoverPanic(q.height, &q.err)
+					if q.err = ctx.Err(); q.err != nil {
+						return
+					}
 					if q.res == nil {
 						q.res = &res{}
 					}
 					q.res.Height = q.height
-					q.res.Header, q.err = r.cl.getBlockHeaderByHeight(q.height)
+					q.res.Header, q.err = r.cl.getBlockHeaderByHeight(ctx, q.height)
 					if q.err != nil {
 						q.err = errors.Wrapf(q.err, "syncVerifier: getBlockHeader: %v", q.err)
 						return
 					}
-					q.res.Votes, q.err = r.cl.GetVotesByHeight(
+					q.res.Votes, q.err = r.cl.GetVotesByHeight(ctx,
 						&BlockHeightParam{Height: NewHexInt(int64(q.height))})
 					if q.err != nil {
 						q.err = errors.Wrapf(q.err, "syncVerifier: GetVotesByHeight: %v", q.err)
 						return
 					}
 					if len(vr.Validators(q.res.Header.NextValidatorsHash)) == 0 {
-						q.res.NextValidators, q.err = r.cl.getValidatorsByHash(q.res.Header.NextValidatorsHash)
+						q.res.NextValidators, q.err = r.getValidatorsByHash(ctx, q.res.Header.NextValidatorsHash)
 						if q.err != nil {
 							q.err = errors.Wrapf(q.err, "syncVerifier: getValidatorsByHash: %v", q.err)
 							return
@@ -238,6 +617,9 @@ func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
 				}(q)
 			}
 		}
+		if timer != nil {
+			timer.Stop()
+		}
 		// filter nil
 		_sres, sres := sres, sres[:0]
 		for _, v := range _sres {
@@ -267,12 +649,448 @@ func (r *receiver) syncVerifier(vr *Verifier, height int64) error {
 			}
 			r.log.WithFields(log.Fields{"height": vr.Next(), "target": height}).Debug("syncVerifier: syncing")
 		}
+		if vr.Next() > next {
+			attempts = 0
+			continue
+		}
+		attempts++
+		r.log.WithFields(log.Fields{
+			"height": next, "attempts": attempts, "error": nextErr,
+		}).Warn("syncVerifier: failed to fetch block")
+		if max := int(r.opts.SyncMaxAttempts); max > 0 && attempts >= max {
+			return &SyncStuckError{Height: next, Attempts: attempts, Err: nextErr}
+		}
 	}
 
 	r.log.WithFields(log.Fields{"height": vr.Next()}).Info("syncVerifier: complete")
 	return nil
 }
 
+// recoverPanic must be deferred by the goroutines fetching the block at
+// height; it turns a panic into *err, so that a malformed block is retried
+// and reported like any other error instead of crashing the process.
+func (r *receiver) recoverPanic(height int64, err *error) {
+	if rec := recover(); rec != nil {
+		r.log.WithFields(log.Fields{
+			"height": height, "panic": rec, "stack": string(debug.Stack()),
+		}).Error("recovered panic")
+		*err = fmt.Errorf("recovered panic: height=%d, %v", height, rec)
+	}
+}
+
+// clients returns the clients of all urls.
+func (r *receiver) clients() []*Client {
+	if len(r.cls) == 0 {
+		return []*Client{r.cl}
+	}
+	return r.cls
+}
+
+// clientIndex picks a client for a read at random, weighted by the inverse
+// of its average latency, so that faster endpoints are preferred while the
+// load is still spread over all of them.
+func (r *receiver) clientIndex() int {
+	cls := r.clients()
+	if len(cls) == 1 {
+		return 0
+	}
+	latencies := make([]time.Duration, len(cls))
+	var min time.Duration
+	for i, cl := range cls {
+		latencies[i] = cl.Stats().Latency
+		if latencies[i] > 0 && (min == 0 || latencies[i] < min) {
+			min = latencies[i]
+		}
+	}
+	if min == 0 {
+		min = time.Millisecond
+	}
+	weights := make([]float64, len(cls))
+	var total float64
+	for i, latency := range latencies {
+		if latency == 0 {
+			latency = min // unknown endpoints are tried as the fastest
+		}
+		weights[i] = 1 / float64(latency)
+		total += weights[i]
+	}
+	v := rand.Float64() * total
+	for i, w := range weights {
+		if v -= w; v < 0 {
+			return i
+		}
+	}
+	return len(cls) - 1
+}
+
+// client returns a client for a read, see clientIndex.
+func (r *receiver) client() *Client {
+	return r.clients()[r.clientIndex()]
+}
+
+// EndpointStats returns the request statistics of the endpoints.
+func (r *receiver) EndpointStats() []EndpointStats {
+	cls := r.clients()
+	stats := make([]EndpointStats, 0, len(cls))
+	for _, cl := range cls {
+		stats = append(stats, cl.Stats())
+	}
+	return stats
+}
+
+// getValidatorsByHash fetches the validators from the clients in turn,
+// starting with one chosen by client, while the data served doesn't match
+// the hash, up to RPCCallRetry times, so that a corrupted response is
+// fetched again from another endpoint if any.
+func (r *receiver) getValidatorsByHash(ctx context.Context, hash common.HexHash) ([]common.Address, error) {
+	cls := r.clients()
+	start := r.clientIndex()
+	for i := 0; ; i++ {
+		cl := cls[(start+i)%len(cls)]
+		validators, err := cl.getValidatorsByHash(ctx, hash)
+		var merr *DataHashMismatchError
+		if !errors.As(err, &merr) || i >= r.opts.rpcCallRetry() {
+			return validators, err
+		}
+		r.log.WithFields(log.Fields{
+			"endpoint": cl.Endpoint, "hash": hash, "len": merr.Len, "retry": i + 1,
+		}).Warn("getValidatorsByHash: data hash mismatch")
+	}
+}
+
+// getProofForEvents fetches the proofs from the clients in turn, starting with
+// one chosen by client, so that a retry goes to another endpoint instead of
+// the one that just failed.
+func (r *receiver) getProofForEvents(ctx context.Context, height int64, p *ProofEventsParam) (proofs [][][]byte, err error) {
+	cls := r.clients()
+	start := r.clientIndex()
+	for i := 0; i < len(cls); i++ {
+		cl := cls[(start+i)%len(cls)]
+		if proofs, err = cl.GetProofForEvents(ctx, p); err == nil {
+			return proofs, nil
+		}
+		r.log.WithFields(log.Fields{"endpoint": cl.Endpoint, "error": err}).Debug("getProofForEvents: try next endpoint")
+	}
+	r.resolverMtx.Lock()
+	resolve := r.proofResolver
+	r.resolverMtx.Unlock()
+	for i := 0; resolve != nil && i <= r.opts.rpcCallRetry(); i++ {
+		cl := resolve(height, err)
+		if cl == nil {
+			break
+		}
+		if proofs, err = cl.GetProofForEvents(ctx, p); err == nil {
+			return proofs, nil
+		}
+		r.log.WithFields(log.Fields{
+			"height": height, "endpoint": cl.Endpoint, "error": err,
+		}).Debug("getProofForEvents: resolved endpoint failed")
+	}
+	return nil, err
+}
+
+// ProofResolver returns a client to fetch the proofs of the events of the
+// block at height from, after it failed with err from every client of the
+// receiver, or nil to give up. It's called again with the error of the
+// client returned, up to 1+RPCCallRetry times, so it may route the heavy
+// proof requests to dedicated nodes by any policy.
+type ProofResolver func(height int64, err error) *Client
+
+// SetProofResolver sets resolve to be called when the proofs of events
+// can't be fetched, replacing any previous one; nil removes it. resolve is
+// called from the fetching goroutines, concurrently.
+func (r *receiver) SetProofResolver(resolve ProofResolver) {
+	r.resolverMtx.Lock()
+	defer r.resolverMtx.Unlock()
+	r.proofResolver = resolve
+}
+
+// logMismatch logs an event not matching the filter in field, skipped as
+// one for another destination, at most once per mismatchLogInterval for
+// each field.
+func (r *receiver) logMismatch(height int64, field string, got, expected []byte) {
+	ok, suppressed := r.mismatchLog.Allow(field)
+	if !ok {
+		return
+	}
+	r.log.WithFields(log.Fields{
+		"height":     height,
+		"got":        common.HexBytes(got),
+		"expected":   common.HexBytes(expected),
+		"suppressed": suppressed}).Debug("skip event: cannot match " + field)
+}
+
+// getReceipt fetches the proofs of the events of the receipt at index in the
+// block, proves them against the receipt hash of hr and returns the receipt
+// with the events matching logFilter.
+func (r *receiver) getReceipt(ctx context.Context, hr *BlockHeaderResult, height int64, blockHash HexBytes, index HexInt, events []HexInt, logFilter *eventLogRawFilter) (*chain.Receipt, error) {
+	p := &ProofEventsParam{
+		Index:     index,
+		BlockHash: blockHash,
+		Events:    events,
+	}
+	if r.proofSem != nil {
+		select {
+		case r.proofSem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	proofs, err := r.getProofForEvents(ctx, height, p)
+	if r.proofSem != nil {
+		<-r.proofSem
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetProofForEvents: %v", err)
+	}
+	if expected := 1 + len(p.Events); len(proofs) > expected && r.opts.TolerateExtraProofs {
+		r.log.WithFields(log.Fields{
+			"height": height, "index": index, "got": len(proofs), "expected": expected,
+		}).Warn("getReceipt: ignore extra proofs")
+		proofs = proofs[:expected]
+	}
+	if len(proofs) != 1+len(p.Events) { // num_receipt + num_events
+		return nil, fmt.Errorf(
+			"Proof does not include all events: len(proofs)=%d, expected=%d",
+			len(proofs), len(p.Events)+1,
+		)
+	}
+
+	// Processing receipt index
+	serializedReceipt, err := mptProve(index, proofs[0], hr.ReceiptHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "MPTProve Receipt: %v", err)
+	}
+	var result TxResult
+	_, err = codec.RLP.UnmarshalFromBytes(serializedReceipt, &result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal Receipt: %v", err)
+	}
+	if r.failedReceipt(height, index, result.Status) {
+		return r.newReceipt(height, index, nil, logFilter)
+	}
+
+	els := make([]*EventLog, 0, len(p.Events))
+	for j := 0; j < len(p.Events); j++ {
+		// nextEP is pointer to event where sequence has caught up
+		serializedEventLog, err := mptProve(
+			p.Events[j], proofs[j+1], common.HexBytes(result.EventLogsHash))
+		if err != nil {
+			return nil, errors.Wrapf(err, "event.MPTProve: %v", err)
+		}
+		var el EventLog
+		_, err = codec.RLP.UnmarshalFromBytes(serializedEventLog, &el)
+		if err != nil {
+			return nil, errors.Wrapf(err, "event.UnmarshalFromBytes: %v", err)
+		}
+		els = append(els, &el)
+	}
+	return r.newReceipt(height, index, els, logFilter)
+}
+
+// ProcessBlock returns the receipts of the messages in the block at height,
+// independently of any subscription, e.g. to check the handling of a block
+// that failed before. The events are found in the transaction results of
+// the block as with Polling, then proven against its header like those of a
+// subscription, regardless of TrustNode.
+func (r *receiver) ProcessBlock(ctx context.Context, height int64) ([]*chain.Receipt, error) {
+	cl := r.client()
+	bn, err := cl.pollBlockNotification(height, r.blockReq.EventFilters)
+	if err != nil {
+		return nil, err
+	}
+	if len(bn.Indexes) == 0 || len(bn.Indexes[0]) == 0 {
+		return nil, nil
+	}
+	header, err := cl.getBlockHeaderByHeight(ctx, height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getBlockHeader: %v", err)
+	}
+	var hr BlockHeaderResult
+	if _, err = codec.RLP.UnmarshalFromBytes(header.Result, &hr); err != nil {
+		return nil, errors.Wrapf(err, "BlockHeaderResult.UnmarshalFromBytes: %v", err)
+	}
+	logFilter := r.logFilter // copy
+	var receipts []*chain.Receipt
+	for i, index := range bn.Indexes[0] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		receipt, err := r.getReceipt(ctx, &hr, height, bn.Hash, index, bn.Events[0][i], &logFilter)
+		if err != nil {
+			return nil, err
+		}
+		if len(receipt.Events) > 0 {
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
+}
+
+// getTrustedReceipt is getReceipt for TrustNode: the events are taken from
+// the transaction result served by the node instead of being proven.
+func (r *receiver) getTrustedReceipt(ctx context.Context, height int64, blockHash HexBytes, index HexInt, events []HexInt, logFilter *eventLogRawFilter) (*chain.Receipt, error) {
+	cl := r.client()
+	blk, err := cl.GetBlockByHeightContext(ctx, &BlockHeightParam{Height: NewHexInt(height)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetBlockByHeight: %v", err)
+	}
+	// the block hash is served without 0x prefix
+	if !strings.EqualFold(strings.TrimPrefix(string(blk.BlockHash), "0x"),
+		strings.TrimPrefix(string(blockHash), "0x")) {
+		return nil, fmt.Errorf("block hash mismatch: height=%d, got=%s, expected=%s",
+			height, blk.BlockHash, blockHash)
+	}
+	idx, err := index.Int()
+	if err != nil || idx < 0 || idx >= len(blk.NormalTransactions) {
+		return nil, fmt.Errorf("invalid transaction index: height=%d, index=%s", height, index)
+	}
+	txr, err := cl.GetTransactionResultContext(ctx,
+		&TransactionHashParam{Hash: blk.NormalTransactions[idx].TxHash})
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetTransactionResult: %v", err)
+	}
+	if status, _ := txr.Status.Value(); r.failedReceipt(height, index, status) {
+		return r.newReceipt(height, index, nil, logFilter)
+	}
+	els := make([]*EventLog, 0, len(events))
+	for _, e := range events {
+		i, err := e.Int()
+		if err != nil || i < 0 || i >= len(txr.EventLogs) {
+			return nil, fmt.Errorf("invalid event index: height=%d, index=%s, event=%s", height, index, e)
+		}
+		l := txr.EventLogs[i]
+		el, err := newEventLog(l.Addr, l.Indexed, l.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid event: height=%d, index=%s, event=%s, %v", height, index, e, err)
+		}
+		els = append(els, el)
+	}
+	return r.newReceipt(height, index, els, logFilter)
+}
+
+// failedReceipt returns whether the events of the receipt with status at
+// index of the block at height are to be skipped, with CheckReceiptStatus,
+// because its transaction failed.
+func (r *receiver) failedReceipt(height int64, index HexInt, status int64) bool {
+	if !r.opts.CheckReceiptStatus || status == 1 {
+		return false
+	}
+	r.log.WithFields(log.Fields{
+		"height": height, "index": index, "status": status,
+	}).Warn("skip events of failed transaction")
+	return true
+}
+
+// newEventLog converts an event log of a transaction result to its form in
+// the receipts, assuming the layout of EventSignature.
+func newEventLog(addr Address, indexed, data []string) (*EventLog, error) {
+	if len(indexed) != 3 || len(data) != 1 {
+		return nil, fmt.Errorf("unexpected event: indexed=%d, data=%d", len(indexed), len(data))
+	}
+	a, err := addr.Value()
+	if err != nil {
+		return nil, err
+	}
+	seq, err := HexInt(indexed[EventIndexSequence]).BigInt()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := HexBytes(data[0]).Value()
+	if err != nil {
+		return nil, err
+	}
+	return &EventLog{
+		Addr: a,
+		Indexed: [][]byte{
+			[]byte(indexed[EventIndexSignature]),
+			[]byte(indexed[EventIndexNext]),
+			intconv.BigIntToBytes(seq),
+		},
+		Data: [][]byte{msg},
+	}, nil
+}
+
+// newReceipt returns the receipt at index of the block at height with the
+// events of els matching logFilter. The other events must be messages for
+// other destinations.
+func (r *receiver) newReceipt(height int64, index HexInt, els []*EventLog, logFilter *eventLogRawFilter) (*chain.Receipt, error) {
+	idx, _ := index.Value()
+	receipt := &chain.Receipt{
+		Index:  uint64(idx),
+		Height: uint64(height),
+	}
+	skipped := 0
+	for i, el := range els {
+		// the layout of EventSignature, checked so that a malformed event
+		// fails the block instead of panicking
+		if len(el.Indexed) <= EventIndexSequence || len(el.Data) < 1 {
+			return nil, fmt.Errorf(
+				"malformed event: height=%d, index=%s, event=%d, indexed=%d, data=%d",
+				height, index, i, len(el.Indexed), len(el.Data))
+		}
+		if bytes.Equal(el.Addr, logFilter.addr) &&
+			bytes.Equal(el.Indexed[EventIndexSignature], logFilter.signature) &&
+			bytes.Equal(el.Indexed[EventIndexNext], logFilter.next) {
+			var seqGot common.HexInt
+			seqGot.SetBytes(el.Indexed[EventIndexSequence])
+			evt := &chain.Event{
+				Next:     chain.BTPAddress(el.Indexed[EventIndexNext]),
+				Sequence: seqGot.Uint64(),
+				Message:  el.Data[0],
+			}
+			receipt.Events = append(receipt.Events, evt)
+		} else if bytes.Equal(el.Addr, logFilter.addr) &&
+			bytes.Equal(el.Indexed[EventIndexSignature], logFilter.signature) {
+			// message for another destination, which nodes not filtering
+			// on indexed values include in the notification
+			skipped++
+			if ok, suppressed := r.mismatchLog.Allow("skip"); ok {
+				r.log.WithFields(log.Fields{
+					"height":     height,
+					"next":       common.HexBytes(el.Indexed[EventIndexNext]),
+					"suppressed": suppressed}).Debug("skip event for other destination")
+			}
+		} else {
+			// the first mismatch is returned, all of them are logged
+			var merr *EventMatchError
+			for _, f := range []struct {
+				name          string
+				got, expected []byte
+			}{
+				{"addr", el.Addr, logFilter.addr},
+				{"sig", el.Indexed[EventIndexSignature], logFilter.signature},
+				{"next", el.Indexed[EventIndexNext], logFilter.next},
+			} {
+				if bytes.Equal(f.got, f.expected) {
+					continue
+				}
+				r.logMismatch(height, f.name, f.got, f.expected)
+				if merr == nil {
+					merr = &EventMatchError{
+						Height: height, Index: index, Event: i,
+						Field: f.name, Got: f.got, Expected: f.expected,
+					}
+				}
+			}
+			return nil, merr
+		}
+	}
+	if len(receipt.Events) > 0 && len(receipt.Events)+skipped != len(els) {
+		r.log.WithFields(log.Fields{
+			"height":              height,
+			"receipt_index":       index,
+			"got_num_events":      len(receipt.Events),
+			"skipped_num_events":  skipped,
+			"expected_num_events": len(els)}).Error("failed to verify all events for the receipt")
+		return nil, &ReceiptEventCountError{
+			Height: height, Index: index,
+			Got: len(receipt.Events), Skipped: skipped, Expected: len(els),
+		}
+	}
+	return receipt, nil
+}
+
 func (r *receiver) receiveLoop(ctx context.Context, startHeight, startSeq uint64, callback func(rs []*chain.Receipt) error) (err error) {
 
 	blockReq, logFilter := r.blockReq, r.logFilter // copy
@@ -281,10 +1099,26 @@ func (r *receiver) receiveLoop(ctx context.Context, startHeight, startSeq uint64
 
 	var vr *Verifier
 	if r.opts.Verifier != nil {
-		vr, err = r.newVerifer(r.opts.Verifier)
+		vr, err = r.newVerifer(ctx, r.opts.Verifier)
 		if err != nil {
 			return err
 		}
+		r.setVerifier(vr)
+		defer r.setVerifier(nil)
+	}
+
+	if r.opts.EventMonitor && !r.opts.Polling {
+		if vr != nil {
+			r.log.Warn("receiveLoop: event monitor disabled: not supported with verifier")
+		} else {
+			next, err := r.receiveEventLoop(ctx, startHeight, callback)
+			if err != errEventMonitorUnavailable {
+				return err
+			}
+			r.log.WithFields(log.Fields{"height": next}).Warn("receiveLoop: event monitor unavailable: fallback to block monitor")
+			blockReq.Height = NewHexInt(int64(next))
+			startHeight = next
+		}
 	}
 
 	type res struct {
@@ -298,8 +1132,59 @@ func (r *receiver) receiveLoop(ctx context.Context, startHeight, startSeq uint64
 
 	ech := make(chan error)                                       // error channel
 	rech := make(chan struct{}, 1)                                // reconnect channel
+	fbch := make(chan struct{}, 1)                                // polling fallback channel
 	bnch := make(chan *BlockNotification, r.opts.SyncConcurrency) // block notification channel
-	brch := make(chan *res, cap(bnch))                            // block result channel
+	brch := make(chan *res, r.opts.CatchUpBatchSize)              // block result channel
+
+	// block results kept over reconnects by height, if PreserveResults
+	var keptMtx sync.Mutex
+	kept := make(map[int64]*res)
+	keep := func(v *res) {
+		if r.opts.PreserveResults && v != nil {
+			keptMtx.Lock()
+			kept[v.Height] = v
+			keptMtx.Unlock()
+		}
+	}
+	// take returns the result kept for the block notified, if any
+	take := func(height int64, hash HexBytes) *res {
+		keptMtx.Lock()
+		defer keptMtx.Unlock()
+		v, ok := kept[height]
+		if !ok {
+			return nil
+		}
+		delete(kept, height)
+		if h, err := hash.Value(); err != nil || !bytes.Equal(h, v.Hash) {
+			return nil
+		}
+		return v
+	}
+
+	// block notifications to observe, dropped if the observer is slow
+	obch := make(chan *BlockNotification, blockObserverBuffer)
+	defer close(obch)
+	go func() {
+		for bn := range obch {
+			if cb := r.blockObserver(); cb != nil {
+				cb(bn)
+			}
+		}
+	}()
+	// observe raises the chain head to the block notified and passes it to the
+	// block observer, if any
+	observe := func(bn *BlockNotification) {
+		if h, err := bn.Height.Value(); err == nil && h > 0 {
+			r.updateHead(uint64(h))
+		}
+		if r.blockObserver() == nil {
+			return
+		}
+		select {
+		case obch <- bn:
+		default:
+		}
+	}
 
 	reconnect := func() {
 		select {
@@ -308,17 +1193,80 @@ func (r *receiver) receiveLoop(ctx context.Context, startHeight, startSeq uint64
 		}
 		for len(brch) > 0 || len(bnch) > 0 {
 			select {
-			case <-brch: // clear block result channel
+			case v := <-brch: // clear block result channel
+				keep(v)
 			case <-bnch: // clear block notification channel
 			}
 		}
 	}
 
 	next := int64(startHeight) // next block height to process
+	r.updateDebug(func(d *receiverDebug) {
+		d.running, d.next, d.reconnects = true, next, 0
+		d.depths = func() (int, int) { return len(brch), len(bnch) }
+	})
+	defer func() {
+		r.updateDebug(func(d *receiverDebug) {
+			d.running, d.depths = false, nil
+		})
+		if err != nil {
+			r.setDebugError(err)
+		}
+	}()
+	r.log.WithFields(log.Fields{
+		"height": next, "strictOrdering": r.opts.StrictOrdering,
+	}).Info("receiveLoop: start")
+	polling := r.opts.Polling
+	pollInterval := time.Duration(r.opts.PollInterval) * time.Second
+	syncWindow := r.opts.InitialSyncConcurrency // of the next verifier sync
+	// the last block notification queued, to skip it if notified again
+	var lastNotified struct {
+		height int64
+		hash   HexBytes
+	}
+	// blocks older than maxBlockAge are rejected once caughtUp, if set
+	maxBlockAge := time.Duration(r.opts.MaxBlockAge) * time.Second
+	caughtUp := false
+	staleReconnects := 0 // in a row, up to RPCCallRetry
+
+	// average size of block results, to bound the batch by MaxBufferedBytes
+	var avgResSize uint64
+	resSize := func(v *res) uint64 {
+		size := uint64(len(v.Votes) + len(v.NextValidators)*len(common.Address{}))
+		if v.Header != nil {
+			size += uint64(len(v.Header.serialized))
+		}
+		for _, rc := range v.Receipts {
+			for _, ev := range rc.Events {
+				size += uint64(len(ev.Message) + len(ev.Next))
+			}
+		}
+		return size
+	}
+	batchLimit := func() int {
+		limit := cap(brch)
+		if r.opts.MaxBufferedBytes > 0 && avgResSize > 0 {
+			if n := r.opts.MaxBufferedBytes / avgResSize; n < uint64(limit) {
+				limit = int(n)
+			}
+			if limit < 1 {
+				limit = 1
+			}
+		}
+		return limit
+	}
 
 	// subscribe to monitor block
 	ctxMonitorBlock, cancelMonitorBlock := context.WithCancel(ctx)
 	reconnect()
+	connState := ConnStateConnecting
+	defer func() {
+		reason := err
+		if reason == nil {
+			reason = ctx.Err()
+		}
+		r.setState(ConnStateDisconnected, reason)
+	}()
 
 loop:
 	for {
@@ -330,26 +1278,106 @@ loop:
 			return err
 
 		case <-rech:
+			select {
+			case <-fbch:
+				polling = true
+			default:
+			}
 			cancelMonitorBlock()
 			ctxMonitorBlock, cancelMonitorBlock = context.WithCancel(ctx)
+			r.setState(connState, nil)
+			if connState == ConnStateReconnecting {
+				r.updateDebug(func(d *receiverDebug) { d.reconnects++ })
+			}
+			connState = ConnStateReconnecting
+			keptMtx.Lock()
+			for h := range kept {
+				if h < next {
+					delete(kept, h) // already forwarded
+				}
+			}
+			keptMtx.Unlock()
+
+			// catch up by polling if the head is too far ahead
+			var catchUpTo int64
+			if r.opts.MaxBlockGap > 0 && !polling {
+				if blk, err := r.cl.GetLastBlockContext(ctx); err == nil {
+					r.updateHead(uint64(blk.Height))
+					if blk.Height-next > int64(r.opts.MaxBlockGap) {
+						r.log.WithFields(log.Fields{
+							"height": next, "head": blk.Height,
+						}).Warn("receiveLoop: block gap too large: catch up by polling")
+						catchUpTo = blk.Height
+					}
+				}
+			}
 
 			// start new monitor loop
-			go func(ctx context.Context, cancel context.CancelFunc) {
+			go func(ctx context.Context, cancel context.CancelFunc, catchUpTo int64, polling bool) {
 				defer cancel()
 				blockReq.Height = NewHexInt(next)
-				err := r.cl.MonitorBlock(ctx, &blockReq,
-					func(conn *websocket.Conn, v *BlockNotification) error {
-						if !errors.Is(ctx.Err(), context.Canceled) {
-							bnch <- v
-						}
-						return nil
-					},
-					func(conn *websocket.Conn) {},
-					func(c *websocket.Conn, err error) {})
+				var err error
+				if catchUpTo > 0 {
+					r.setState(ConnStateConnected, nil)
+					err = r.cl.PollBlock(ctx, &blockReq, pollInterval,
+						func(v *BlockNotification) error {
+							select {
+							case bnch <- v:
+							case <-ctx.Done():
+								return ctx.Err()
+							}
+							if h, _ := v.Height.Value(); h >= catchUpTo {
+								return errCaughtUp
+							}
+							return nil
+						})
+					if err == errCaughtUp {
+						blockReq.Height = NewHexInt(catchUpTo + 1)
+						err = nil
+					}
+				}
+				switch {
+				case err != nil:
+				case polling:
+					r.setState(ConnStateConnected, nil)
+					err = r.cl.PollBlock(ctx, &blockReq, pollInterval,
+						func(v *BlockNotification) error {
+							select {
+							case bnch <- v:
+								return nil
+							case <-ctx.Done():
+								return ctx.Err()
+							}
+						})
+				default:
+					err = r.cl.MonitorBlock(ctx, &blockReq,
+						func(conn *websocket.Conn, v *BlockNotification) error {
+							if !errors.Is(ctx.Err(), context.Canceled) {
+								bnch <- v
+							}
+							return nil
+						},
+						func(conn *websocket.Conn) {
+							r.setState(ConnStateConnected, nil)
+						},
+						func(c *websocket.Conn, err error) {})
+				}
 				if err != nil {
 					if errors.Is(err, context.Canceled) {
 						return
 					}
+					if err == ErrConnectFail && !polling && r.opts.PollingFallback {
+						select {
+						case fbch <- struct{}{}: // before reconnect, to poll from then on
+						default:
+						}
+						r.setState(ConnStateDisconnected, err)
+						reconnect()
+						r.log.Warn("reconnect: websocket unavailable: fallback to polling")
+						return
+					}
+					r.setState(ConnStateDisconnected, err)
+					r.setDebugError(err)
 					time.Sleep(time.Second * 5)
 					reconnect()
 					r.log.WithFields(log.Fields{"error": err}).Error("reconnect: monitor block error")
@@ -360,27 +1388,35 @@ loop:
 					// 	ech <- err
 					// }
 				}
-			}(ctxMonitorBlock, cancelMonitorBlock)
+			}(ctxMonitorBlock, cancelMonitorBlock, catchUpTo, polling)
 
 			// sync verifier
 			if vr != nil {
-				if err := r.syncVerifier(vr, next); err != nil {
+				if err := r.syncVerifier(ctx, vr, next, syncWindow); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
 					return errors.Wrapf(err, "sync verifier: %v", err)
 				}
+				syncWindow = r.opts.SteadySyncConcurrency
 			}
 
 		case br := <-brch:
 			for ; br != nil; next++ {
+				if ctx.Err() != nil {
+					return nil // don't hold the shutdown for the rest of the batch
+				}
 				r.log.WithFields(log.Fields{"height": br.Height}).Debug("block notification")
 
 				if vr != nil {
-					ok, err := vr.Verify(br.Header, br.Votes)
-					if !ok || err != nil {
-						if err != nil {
-							r.log.WithFields(log.Fields{"height": br.Height, "error": err}).Error("receiveLoop: verification error")
-						} else if !ok {
-							r.log.WithFields(log.Fields{"height": br.Height, "hash": br.Hash}).Error("receiveLoop: invalid header")
+					q, err := vr.VerifyQuorum(br.Header, br.Votes)
+					if err != nil {
+						fields := log.Fields{"height": br.Height, "error": err}
+						if q != nil {
+							fields["votes"], fields["required"] = q.Votes, q.Required
 						}
+						r.log.WithFields(fields).Error("receiveLoop: verification error")
+						r.setDebugError(err)
 						reconnect() // reconnect websocket
 						r.log.WithFields(log.Fields{"height": br.Height, "hash": br.Hash}).Error("reconnect: verification failed")
 						break
@@ -392,6 +1428,11 @@ loop:
 				if err := callback(br.Receipts); err != nil {
 					return errors.Wrapf(err, "receiveLoop: callback: %v", err)
 				}
+				if maxBlockAge > 0 && !caughtUp && br.Header != nil && blockAge(br.Header) <= maxBlockAge {
+					caughtUp = true
+					r.log.WithFields(log.Fields{"height": br.Height}).Info("receiveLoop: caught up: check block age")
+				}
+				r.updateDebug(func(d *receiverDebug) { d.next = next + 1 })
 				if br = nil; len(brch) > 0 {
 					br = <-brch
 				}
@@ -413,15 +1454,40 @@ loop:
 					res *res
 				}
 
-				qch := make(chan *req, cap(bnch))
-				for i := int64(0); bn != nil; i++ {
+				qch := make(chan *req, cap(brch))
+				limit := batchLimit()
+				checkAge := maxBlockAge > 0 && caughtUp
+				for bn != nil {
+					observe(bn)
+					i := int64(len(qch)) // notifications queued
 					height, err := bn.Height.Value()
 					if err != nil {
-						panic(err)
+						r.log.WithFields(log.Fields{
+							"height": bn.Height, "error": err,
+						}).Error("reconnect: invalid block notification height")
+						for len(qch) > 0 {
+							keep((<-qch).res) // taken from kept, not fetched
+						}
+						reconnect()
+						continue loop
+					} else if height == next+i-1 && height == lastNotified.height && bn.Hash == lastNotified.hash {
+						// sent again, as by some nodes on internal retries
+						if ok, suppressed := r.mismatchLog.Allow("duplicate"); ok {
+							r.log.WithFields(log.Fields{
+								"height": height, "hash": bn.Hash, "suppressed": suppressed,
+							}).Debug("receiveLoop: skip duplicate block notification")
+						}
+						if bn = nil; len(bnch) > 0 && len(qch) < limit {
+							bn = <-bnch
+						}
+						continue
 					} else if height != next+i {
 						r.log.WithFields(log.Fields{
 							"height": log.Fields{"got": height, "expected": next + i},
 						}).Error("reconnect: missing block notification")
+						for len(qch) > 0 {
+							keep((<-qch).res) // taken from kept, not fetched
+						}
 						reconnect()
 						continue loop
 					}
@@ -430,24 +1496,45 @@ loop:
 						hash:    bn.Hash,
 						indexes: bn.Indexes,
 						events:  bn.Events,
-						retry:   RPCCallRetry,
+						retry:   r.opts.rpcCallRetry(),
+						res:     take(height, bn.Hash), // fetched before if not nil
 					} // fill qch with requests
-					if bn = nil; len(bnch) > 0 && len(qch) < cap(qch) {
+					lastNotified.height, lastNotified.hash = height, bn.Hash
+					if bn = nil; len(bnch) > 0 && len(qch) < limit {
 						bn = <-bnch
 					}
 				}
+				if len(qch) == 0 {
+					continue loop // only duplicates
+				}
 
 				brs := make([]*res, 0, len(qch))
+				var stale *StaleBlockError // of the batch, not fetched again
 				for q := range qch {
 					switch {
 					case q.err != nil:
-						if q.retry > 0 {
-							q.retry--
+						if ctx.Err() != nil {
+							return nil // shutting down: don't retry
+						}
+						var serr *StaleBlockError
+						if errors.As(q.err, &serr) {
+							if stale == nil || serr.Height < stale.Height {
+								stale = serr
+							}
+						} else if q.retry > 0 || r.opts.StrictOrdering {
+							if q.retry > 0 {
+								q.retry--
+							} else if ok, suppressed := r.mismatchLog.Allow("strict"); ok {
+								r.log.WithFields(log.Fields{
+									"height": q.height, "error": q.err, "suppressed": suppressed,
+								}).Warn("receiveLoop: strict ordering: retry until fetched")
+							}
 							q.res, q.err = nil, nil
 							qch <- q
 							continue
 						}
 						r.log.WithFields(log.Fields{"height": q.height, "error": q.err}).Debug("receiveLoop: req error")
+						r.setDebugError(q.err)
 						brs = append(brs, nil)
 						if len(brs) == cap(brs) {
 							close(qch)
@@ -462,9 +1549,16 @@ loop:
 					default:
 						go func(q *req) {
 							defer func() {
-								time.Sleep(500 * time.Millisecond)
+								select {
+								case <-time.After(retryDelay(q.err, 500*time.Millisecond)):
+								case <-ctx.Done():
+								}
 								qch <- q
 							}()
+							defer r.recoverPanic(q.height, &q.err)
+							if q.err = ctx.Err(); q.err != nil {
+								return
+							}
 							if q.res == nil {
 								q.res = &res{}
 							}
@@ -476,21 +1570,57 @@ loop:
 								return
 							}
 
-							q.res.Header, q.err = r.cl.getBlockHeaderByHeight(q.height)
-							if q.err != nil {
-								q.err = errors.Wrapf(q.err, "getBlockHeader: %v", q.err)
+							cl := r.client()
+							if maxBlockAge > 0 {
+								q.res.Header, q.err = cl.getBlockHeaderByHeight(ctx, q.height)
+								if q.err != nil {
+									q.err = errors.Wrapf(q.err, "getBlockHeader: %v", q.err)
+									return
+								}
+								if age := blockAge(q.res.Header); checkAge && age > maxBlockAge {
+									q.err = &StaleBlockError{Height: q.height, Age: age, MaxAge: maxBlockAge}
+									return
+								}
+							}
+
+							// without verifier, the header is only needed to prove events
+							if vr == nil && (len(q.indexes) == 0 || len(q.events) == 0) {
+								return
+							}
+							if vr == nil && r.opts.TrustNode {
+								for i, index := range q.indexes[0] {
+									if q.err = ctx.Err(); q.err != nil {
+										return
+									}
+									receipt, err := r.getTrustedReceipt(ctx, q.height, q.hash, index, q.events[0][i], &logFilter)
+									if err != nil {
+										q.err = err
+										return
+									}
+									if len(receipt.Events) > 0 {
+										q.res.Receipts = append(q.res.Receipts, receipt)
+									}
+								}
 								return
 							}
+
+							if q.res.Header == nil {
+								q.res.Header, q.err = cl.getBlockHeaderByHeight(ctx, q.height)
+								if q.err != nil {
+									q.err = errors.Wrapf(q.err, "getBlockHeader: %v", q.err)
+									return
+								}
+							}
 							// fetch votes, next validators only if verifier exists
 							if vr != nil {
-								q.res.Votes, q.err = r.cl.GetVotesByHeight(
+								q.res.Votes, q.err = cl.GetVotesByHeight(ctx,
 									&BlockHeightParam{Height: NewHexInt(int64(q.height))})
 								if q.err != nil {
 									q.err = errors.Wrapf(q.err, "GetVotesByHeight: %v", q.err)
 									return
 								}
 								if len(vr.Validators(q.res.Header.NextValidatorsHash)) == 0 {
-									q.res.NextValidators, q.err = r.cl.getValidatorsByHash(q.res.Header.NextValidatorsHash)
+									q.res.NextValidators, q.err = r.getValidatorsByHash(ctx, q.res.Header.NextValidatorsHash)
 									if q.err != nil {
 										q.err = errors.Wrapf(q.err, "getValidatorsByHash: %v", q.err)
 										return
@@ -501,108 +1631,21 @@ loop:
 							if len(q.indexes) > 0 && len(q.events) > 0 {
 								var hr BlockHeaderResult
 								_, err := codec.RLP.UnmarshalFromBytes(q.res.Header.Result, &hr)
-								if q.err != nil {
-									q.err = errors.Wrapf(q.err, "BlockHeaderResult.UnmarshalFromBytes: %v", err)
+								if err != nil {
+									q.err = errors.Wrapf(err, "BlockHeaderResult.UnmarshalFromBytes: %v", err)
 									return
 								}
 								for i, index := range q.indexes[0] {
-									p := &ProofEventsParam{
-										Index:     index,
-										BlockHash: q.hash,
-										Events:    q.events[0][i],
-									}
-									proofs, err := r.cl.GetProofForEvents(p)
-									if err != nil {
-										q.err = errors.Wrapf(err, "GetProofForEvents: %v", err)
+									if q.err = ctx.Err(); q.err != nil {
 										return
 									}
-									if len(proofs) != 1+len(p.Events) { // num_receipt + num_events
-										q.err = errors.Wrapf(q.err,
-											"Proof does not include all events: len(proofs)=%d, expected=%d",
-											len(proofs), len(p.Events)+1,
-										)
-										return
-									}
-
-									// Processing receipt index
-									serializedReceipt, err := mptProve(index, proofs[0], hr.ReceiptHash)
-									if err != nil {
-										q.err = errors.Wrapf(err, "MPTProve Receipt: %v", err)
-										return
-									}
-									var result TxResult
-									_, err = codec.RLP.UnmarshalFromBytes(serializedReceipt, &result)
+									receipt, err := r.getReceipt(ctx, &hr, q.height, q.hash, index, q.events[0][i], &logFilter)
 									if err != nil {
-										q.err = errors.Wrapf(err, "Unmarshal Receipt: %v", err)
+										q.err = err
 										return
 									}
-
-									idx, _ := index.Value()
-									receipt := &chain.Receipt{
-										Index:  uint64(idx),
-										Height: uint64(q.height),
-									}
-									for j := 0; j < len(p.Events); j++ {
-										// nextEP is pointer to event where sequence has caught up
-										serializedEventLog, err := mptProve(
-											p.Events[j], proofs[j+1], common.HexBytes(result.EventLogsHash))
-										if err != nil {
-											q.err = errors.Wrapf(err, "event.MPTProve: %v", err)
-											return
-										}
-										var el EventLog
-										_, err = codec.RLP.UnmarshalFromBytes(serializedEventLog, &el)
-										if err != nil {
-											q.err = errors.Wrapf(err, "event.UnmarshalFromBytes: %v", err)
-											return
-										}
-
-										if bytes.Equal(el.Addr, logFilter.addr) &&
-											bytes.Equal(el.Indexed[EventIndexSignature], logFilter.signature) &&
-											bytes.Equal(el.Indexed[EventIndexNext], logFilter.next) {
-											var seqGot common.HexInt
-											seqGot.SetBytes(el.Indexed[EventIndexSequence])
-											evt := &chain.Event{
-												Next:     chain.BTPAddress(el.Indexed[EventIndexNext]),
-												Sequence: seqGot.Uint64(),
-												Message:  el.Data[0],
-											}
-											receipt.Events = append(receipt.Events, evt)
-										} else {
-											if !bytes.Equal(el.Addr, logFilter.addr) {
-												r.log.WithFields(log.Fields{
-													"height":   q.height,
-													"got":      common.HexBytes(el.Addr),
-													"expected": common.HexBytes(logFilter.addr)}).Error("invalid event: cannot match addr")
-											}
-											if !bytes.Equal(el.Indexed[EventIndexSignature], logFilter.signature) {
-												r.log.WithFields(log.Fields{
-													"height":   q.height,
-													"got":      common.HexBytes(el.Indexed[EventIndexSignature]),
-													"expected": common.HexBytes(logFilter.signature)}).Error("invalid event: cannot match sig")
-											}
-											if !bytes.Equal(el.Indexed[EventIndexNext], logFilter.next) {
-												r.log.WithFields(log.Fields{
-													"height":   q.height,
-													"got":      common.HexBytes(el.Indexed[EventIndexNext]),
-													"expected": common.HexBytes(logFilter.next)}).Error("invalid event: cannot match next")
-											}
-											q.err = errors.New("invalid event")
-											return
-										}
-									}
 									if len(receipt.Events) > 0 {
-										if len(receipt.Events) == len(p.Events) {
-											q.res.Receipts = append(q.res.Receipts, receipt)
-										} else {
-											r.log.WithFields(log.Fields{
-												"height":              q.height,
-												"receipt_index":       index,
-												"got_num_events":      len(receipt.Events),
-												"expected_num_events": len(p.Events)}).Error("failed to verify all events for the receipt")
-											q.err = errors.New("failed to verify all events for the receipt")
-											return
-										}
+										q.res.Receipts = append(q.res.Receipts, receipt)
 									}
 								}
 							}
@@ -614,6 +1657,11 @@ loop:
 				for _, v := range _brs {
 					if v != nil {
 						brs = append(brs, v)
+						if size := resSize(v) + 1; avgResSize == 0 {
+							avgResSize = size
+						} else {
+							avgResSize = (avgResSize*7 + size) / 8
+						}
 					}
 				}
 				// sort and forward notifications
@@ -621,6 +1669,35 @@ loop:
 					sort.SliceStable(brs, func(i, j int) bool {
 						return brs[i].Height < brs[j].Height
 					})
+				}
+				if len(brs) < len(_brs) {
+					// a block failed after all retries: blocks after it
+					// can't be forwarded without breaking the order
+					gap := next + int64(len(brs))
+					for i, d := range brs {
+						if d.Height != next+int64(i) {
+							gap = next + int64(i)
+							break
+						}
+					}
+					if r.opts.FailOnGap {
+						return fmt.Errorf("receiveLoop: missing block: height=%d", gap)
+					}
+					if stale != nil && stale.Height == gap {
+						if staleReconnects >= r.opts.rpcCallRetry() {
+							return stale
+						}
+						staleReconnects++
+					}
+					for _, d := range brs {
+						keep(d)
+					}
+					r.log.WithFields(log.Fields{"height": gap}).Error("reconnect: missing block")
+					reconnect()
+					continue loop
+				}
+				staleReconnects = 0
+				if len(brs) > 0 {
 					for i, d := range brs {
 						if d.Height == int64(next)+int64(i) {
 							brch <- d
@@ -633,20 +1710,186 @@ loop:
 
 }
 
+// blockAge returns the time elapsed since the timestamp of the block.
+func blockAge(h *BlockHeader) time.Duration {
+	return time.Since(time.Unix(0, h.Timestamp*int64(time.Microsecond)))
+}
+
+var errEventMonitorUnavailable = errors.New("event monitor unavailable")
+
+// errCaughtUp stops polling once the blocks of a large gap are fetched.
+var errCaughtUp = errors.New("caught up")
+
+// receiveEventLoop monitors events matching the event filter of the receiver
+// from startHeight and forwards the receipts of the matching events to
+// callback. It returns errEventMonitorUnavailable with the height to resume
+// from if the node rejects the event subscription before any notification.
+func (r *receiver) receiveEventLoop(ctx context.Context, startHeight uint64, callback func(rs []*chain.Receipt) error) (uint64, error) {
+	evtReq := EventRequest{EventFilter: *r.blockReq.EventFilters[0]}
+	logFilter := r.logFilter // copy
+	next := int64(startHeight)
+	for connState := ConnStateConnecting; ; connState = ConnStateReconnecting {
+		received := false
+		evtReq.Height = NewHexInt(next)
+		r.setState(connState, nil)
+		err := r.cl.MonitorEvent(ctx, &evtReq,
+			func(conn *websocket.Conn, v *EventNotification) error {
+				if !received {
+					// the node notifies nothing on subscription
+					r.setState(ConnStateConnected, nil)
+				}
+				received = true
+				height, err := v.Height.Value()
+				if err != nil {
+					return errors.Wrapf(err, "invalid height: %v", v.Height)
+				}
+				var receipt *chain.Receipt
+				for retry := 0; ; retry++ {
+					if receipt, err = r.getEventReceipt(ctx, height, v, &logFilter); err == nil || retry >= r.opts.rpcCallRetry() {
+						break
+					}
+					r.log.WithFields(log.Fields{"height": height, "error": err}).Debug("receiveEventLoop: req error")
+					time.Sleep(500 * time.Millisecond)
+				}
+				if err != nil {
+					return err
+				}
+				// events of the same block may be notified separately,
+				// so resume from this block; duplicates are dropped by seq.
+				next = height
+				if len(receipt.Events) > 0 {
+					if err := callback([]*chain.Receipt{receipt}); err != nil {
+						return errors.Wrapf(err, "receiveEventLoop: callback: %v", err)
+					}
+				}
+				return nil
+			},
+			func(conn *websocket.Conn, err error) {})
+		if ctx.Err() != nil {
+			r.setState(ConnStateDisconnected, ctx.Err())
+			return uint64(next), nil
+		}
+		r.setState(ConnStateDisconnected, err)
+		if _, ok := err.(wsRequestError); ok && !received {
+			return uint64(next), errEventMonitorUnavailable
+		}
+		r.log.WithFields(log.Fields{"height": next, "error": err}).Error("reconnect: monitor event error")
+		time.Sleep(time.Second * 5)
+	}
+}
+
+func (r *receiver) getEventReceipt(ctx context.Context, height int64, en *EventNotification, logFilter *eventLogRawFilter) (*chain.Receipt, error) {
+	if r.opts.TrustNode {
+		return r.getTrustedReceipt(ctx, height, en.Hash, en.Index, en.Events, logFilter)
+	}
+	header, err := r.cl.getBlockHeaderByHeight(ctx, height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getBlockHeader: %v", err)
+	}
+	var hr BlockHeaderResult
+	if _, err = codec.RLP.UnmarshalFromBytes(header.Result, &hr); err != nil {
+		return nil, errors.Wrapf(err, "BlockHeaderResult.UnmarshalFromBytes: %v", err)
+	}
+	return r.getReceipt(ctx, &hr, height, en.Hash, en.Index, en.Events, logFilter)
+}
+
+// updateHead raises the known chain head to height.
+func (r *receiver) updateHead(height uint64) {
+	for {
+		head := atomic.LoadUint64(&r.head)
+		if height <= head || atomic.CompareAndSwapUint64(&r.head, head, height) {
+			return
+		}
+	}
+}
+
+// Subscribe forwards the BTP messages from opts.Seq+1 found in the blocks
+// from opts.Height, or from the latest block if opts.Height is 0.
 func (r *receiver) Subscribe(
 	ctx context.Context, msgCh chan<- *chain.Message,
 	opts chain.SubscribeOptions) (errCh <-chan error, err error) {
 
 	opts.Seq++
 
+	if r.seqIdx != nil && opts.Height >= 1 {
+		if height, ok := r.seqIdx.Height(opts.Seq); ok && height > opts.Height {
+			r.log.WithFields(log.Fields{
+				"seq": opts.Seq, "height": height, "requested": opts.Height,
+			}).Info("Subscribe: start from seq index")
+			opts.Height = height
+		}
+	}
+
 	if opts.Height < 1 {
-		opts.Height = 1
+		blk, err := r.cl.GetLastBlockContext(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetLastBlock: %v", err)
+		}
+		opts.Height = uint64(blk.Height)
+		r.updateHead(opts.Height)
+		r.log.WithFields(log.Fields{"height": opts.Height}).Info("Subscribe: start from latest block")
+		if opts.Height < 1 {
+			opts.Height = 1
+		}
+	}
+
+	// send sends the messages to msgCh, through a buffer if any
+	send := func(msg *chain.Message) error {
+		select {
+		case msgCh <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var buf chan *chain.Message
+	if r.opts.MessageBufferSize > 0 {
+		buf = make(chan *chain.Message, r.opts.MessageBufferSize)
+		send = func(msg *chain.Message) error {
+			if r.opts.FailOnFullBuffer {
+				select {
+				case buf <- msg:
+					return nil
+				default:
+					return ErrMessageBufferFull
+				}
+			}
+			select {
+			case buf <- msg:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		r.updateDebug(func(d *receiverDebug) {
+			d.buffered = func() int { return len(buf) }
+		})
 	}
 
 	_errCh := make(chan error)
 	go func() {
 		defer close(_errCh)
+		// forward the buffered messages, all of them before an error
+		forwarded := make(chan struct{})
+		if buf != nil {
+			go func() {
+				defer close(forwarded)
+				for msg := range buf {
+					select {
+					case msgCh <- msg:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		} else {
+			close(forwarded)
+		}
+		started := false // whether an event up to the expected seq was received
 		err := r.receiveLoop(ctx, opts.Height, opts.Seq, func(receipts []*chain.Receipt) error {
+			if err := r.waitIfPaused(ctx); err != nil {
+				return err
+			}
 			for _, receipt := range receipts {
 				events := receipt.Events[:0]
 				for _, event := range receipt.Events {
@@ -654,24 +1897,125 @@ func (r *receiver) Subscribe(
 					case event.Sequence == opts.Seq:
 						events = append(events, event)
 						opts.Seq++
+						started = true
+						if r.seqIdx != nil {
+							if err := r.seqIdx.Add(event.Sequence, receipt.Height); err != nil {
+								r.log.WithFields(log.Fields{"seq": event.Sequence, "error": err}).Warn("seq index: add failed")
+							}
+						}
 					case event.Sequence > opts.Seq:
+						err := &SequenceGapError{
+							Expected: opts.Seq,
+							Got:      event.Sequence,
+							Height:   receipt.Height,
+							Started:  started,
+						}
 						r.log.WithFields(log.Fields{
-							"seq": log.Fields{"got": event.Sequence, "expected": opts.Seq},
+							"seq":     log.Fields{"got": event.Sequence, "expected": opts.Seq},
+							"height":  receipt.Height,
+							"started": started,
 						}).Error("invalid event seq")
-						return fmt.Errorf("invalid event seq")
+						return err
+					default:
+						started = true
 					}
 				}
 				receipt.Events = events
 			}
 			if len(receipts) > 0 {
-				msgCh <- &chain.Message{Receipts: receipts}
+				r.updateHead(receipts[len(receipts)-1].Height)
+				return send(&chain.Message{Receipts: receipts, Head: atomic.LoadUint64(&r.head)})
 			}
 			return nil
 		})
-		if err != nil {
+		if buf != nil {
+			close(buf)
+			<-forwarded
+			r.updateDebug(func(d *receiverDebug) { d.buffered = nil })
+		}
+		if err != nil && ctx.Err() == nil {
 			r.log.Errorf("receiveLoop terminated: %v", err)
 			_errCh <- err
 		}
 	}()
 	return _errCh, nil
 }
+
+// SubscribeEvents subscribes like Subscribe but sends the events to evtCh
+// one by one, in sequence order, instead of the messages of receipts.
+func (r *receiver) SubscribeEvents(
+	ctx context.Context, evtCh chan<- *chain.Event,
+	opts chain.SubscribeOptions) (errCh <-chan error, err error) {
+
+	msgCh := make(chan *chain.Message)
+	subErrCh, err := r.Subscribe(ctx, msgCh, opts)
+	if err != nil {
+		return nil, err
+	}
+	_errCh := make(chan error)
+	go func() {
+		defer close(_errCh)
+		// don't block the subscription reporting an error on cancellation
+		defer func() {
+			go func() {
+				for range subErrCh {
+				}
+			}()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-subErrCh:
+				if ok {
+					select {
+					case _errCh <- err:
+					case <-ctx.Done():
+					}
+				}
+				return
+			case msg := <-msgCh:
+				for _, receipt := range msg.Receipts {
+					for _, event := range receipt.Events {
+						select {
+						case evtCh <- event:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+			}
+		}
+	}()
+	return _errCh, nil
+}
+
+// SubscribeN subscribes like Subscribe until n events have been received and
+// returns them, in order. It's meant for tests expecting a known number of
+// events; the subscription is cancelled before it returns.
+func (r *receiver) SubscribeN(ctx context.Context, opts chain.SubscribeOptions, n int) ([]*chain.Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	evtCh := make(chan *chain.Event)
+	errCh, err := r.SubscribeEvents(ctx, evtCh, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*chain.Event, 0, n)
+	for len(events) < n {
+		select {
+		case <-ctx.Done():
+			return events, ctx.Err()
+		case err, ok := <-errCh:
+			if !ok {
+				return events, errors.New("subscription terminated")
+			}
+			return events, err
+		case event := <-evtCh:
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
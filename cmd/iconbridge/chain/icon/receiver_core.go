@@ -31,8 +31,8 @@ type ReceiverCore struct {
 	BlockReq BlockRequest
 }
 
-func (r *ReceiverCore) newVerifer(opts *VerifierOptions) (*Verifier, error) {
-	validators, err := r.Cl.getValidatorsByHash(opts.ValidatorsHash)
+func (r *ReceiverCore) newVerifer(ctx context.Context, opts *VerifierOptions) (*Verifier, error) {
+	validators, err := r.Cl.getValidatorsByHash(ctx, opts.ValidatorsHash)
 	if err != nil {
 		return nil, err
 	}
@@ -43,11 +43,11 @@ func (r *ReceiverCore) newVerifer(opts *VerifierOptions) (*Verifier, error) {
 			opts.ValidatorsHash.String(): validators,
 		},
 	}
-	header, err := r.Cl.getBlockHeaderByHeight(int64(vr.next))
+	header, err := r.Cl.getBlockHeaderByHeight(ctx, int64(vr.next))
 	if err != nil {
 		return nil, err
 	}
-	votes, err := r.Cl.GetVotesByHeight(
+	votes, err := r.Cl.GetVotesByHeight(ctx,
 		&BlockHeightParam{Height: NewHexInt(vr.next)})
 	if err != nil {
 		return nil, err
@@ -62,7 +62,7 @@ func (r *ReceiverCore) newVerifer(opts *VerifierOptions) (*Verifier, error) {
 	return &vr, nil
 }
 
-func (r *ReceiverCore) syncVerifier(vr *Verifier, height int64) error {
+func (r *ReceiverCore) syncVerifier(ctx context.Context, vr *Verifier, height int64) error {
 	if height == vr.Next() {
 		return nil
 	}
@@ -124,19 +124,19 @@ func (r *ReceiverCore) syncVerifier(vr *Verifier, height int64) error {
 						q.res = &res{}
 					}
 					q.res.Height = q.height
-					q.res.Header, q.err = r.Cl.getBlockHeaderByHeight(q.height)
+					q.res.Header, q.err = r.Cl.getBlockHeaderByHeight(ctx, q.height)
 					if q.err != nil {
 						q.err = errors.Wrapf(q.err, "syncVerifier: getBlockHeader: %v", q.err)
 						return
 					}
-					q.res.Votes, q.err = r.Cl.GetVotesByHeight(
+					q.res.Votes, q.err = r.Cl.GetVotesByHeight(ctx,
 						&BlockHeightParam{Height: NewHexInt(int64(q.height))})
 					if q.err != nil {
 						q.err = errors.Wrapf(q.err, "syncVerifier: GetVotesByHeight: %v", q.err)
 						return
 					}
 					if len(vr.Validators(q.res.Header.NextValidatorsHash)) == 0 {
-						q.res.NextValidators, q.err = r.Cl.getValidatorsByHash(q.res.Header.NextValidatorsHash)
+						q.res.NextValidators, q.err = r.Cl.getValidatorsByHash(ctx, q.res.Header.NextValidatorsHash)
 						if q.err != nil {
 							q.err = errors.Wrapf(q.err, "syncVerifier: getValidatorsByHash: %v", q.err)
 							return
@@ -181,7 +181,7 @@ func (r *ReceiverCore) ReceiveLoop(ctx context.Context, startHeight, startSeq ui
 
 	var vr *Verifier
 	if r.Opts.Verifier != nil {
-		vr, err = r.newVerifer(r.Opts.Verifier)
+		vr, err = r.newVerifer(ctx, r.Opts.Verifier)
 		if err != nil {
 			return err
 		}
@@ -258,7 +258,7 @@ loop:
 
 			// sync verifier
 			if vr != nil {
-				if err := r.syncVerifier(vr, next); err != nil {
+				if err := r.syncVerifier(ctx, vr, next); err != nil {
 					return errors.Wrapf(err, "sync verifier: %v", err)
 				}
 			}
@@ -371,21 +371,21 @@ loop:
 								return
 							}
 
-							q.res.Header, q.err = r.Cl.getBlockHeaderByHeight(q.height)
+							q.res.Header, q.err = r.Cl.getBlockHeaderByHeight(ctx, q.height)
 							if q.err != nil {
 								q.err = errors.Wrapf(q.err, "getBlockHeader: %v", q.err)
 								return
 							}
 							// fetch votes, next validators only if verifier exists
 							if vr != nil {
-								q.res.Votes, q.err = r.Cl.GetVotesByHeight(
+								q.res.Votes, q.err = r.Cl.GetVotesByHeight(ctx,
 									&BlockHeightParam{Height: NewHexInt(int64(q.height))})
 								if q.err != nil {
 									q.err = errors.Wrapf(q.err, "GetVotesByHeight: %v", q.err)
 									return
 								}
 								if len(vr.Validators(q.res.Header.NextValidatorsHash)) == 0 {
-									q.res.NextValidators, q.err = r.Cl.getValidatorsByHash(q.res.Header.NextValidatorsHash)
+									q.res.NextValidators, q.err = r.Cl.getValidatorsByHash(ctx, q.res.Header.NextValidatorsHash)
 									if q.err != nil {
 										q.err = errors.Wrapf(q.err, "getValidatorsByHash: %v", q.err)
 										return
@@ -410,7 +410,7 @@ loop:
 											BlockHash: q.hash,
 											Events:    q.events[id][i],
 										}
-										proofs, err := r.Cl.GetProofForEvents(p)
+										proofs, err := r.Cl.GetProofForEvents(ctx, p)
 										if err != nil {
 											q.err = errors.Wrapf(err, "GetProofForEvents: %v", err)
 											return
@@ -467,7 +467,10 @@ loop:
 													"receipt_index":       index,
 													"got_num_events":      len(result.EventLogs),
 													"expected_num_events": len(p.Events)}).Info("failed to verify all events for the receipt")
-												q.err = errors.New("failed to verify all events for the receipt")
+												q.err = &ReceiptEventCountError{
+													Height: q.height, Index: index,
+													Got: len(result.EventLogs), Expected: len(p.Events),
+												}
 												return
 											}
 										}
@@ -3,12 +3,22 @@ package icon
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	vlcodec "github.com/icon-project/goloop/common/codec"
 	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
 	"github.com/icon-project/icon-bridge/common"
+	"github.com/icon-project/icon-bridge/common/crypto"
 	"github.com/icon-project/icon-bridge/common/jsonrpc"
 	"github.com/icon-project/icon-bridge/common/log"
 	"github.com/pkg/errors"
@@ -97,3 +107,1124 @@ func TestNextValidatorHashFetch(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestGetProofForEventsRotatesEndpoints(t *testing.T) {
+	calls := make([]int, 2)
+	newServer := func(i int, fail bool) *Client {
+		srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+			"icx_getProofForEvents": func(json.RawMessage) interface{} {
+				calls[i]++
+				if fail {
+					return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E1000:failed"}
+				}
+				return [][][]byte{{[]byte("proof")}}
+			},
+		})
+		return NewClient(srv.URL, log.New())
+	}
+	r := &receiver{log: log.New()}
+	r.cls = []*Client{newServer(0, true), newServer(1, false)}
+	r.cl = r.cls[0]
+
+	for i := 0; i < 4; i++ {
+		proofs, err := r.getProofForEvents(context.Background(), 1, &ProofEventsParam{})
+		require.NoError(t, err)
+		require.Equal(t, [][][]byte{{[]byte("proof")}}, proofs)
+	}
+	require.LessOrEqual(t, calls[0], 4)
+	require.Equal(t, 4, calls[1])
+}
+
+func TestGetProofForEventsResolver(t *testing.T) {
+	var mtx sync.Mutex
+	calls := make(map[string]int)
+	newServer := func(name string, fail bool) *Client {
+		srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+			"icx_getProofForEvents": func(json.RawMessage) interface{} {
+				mtx.Lock()
+				calls[name]++
+				mtx.Unlock()
+				if fail {
+					return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "E1000:failed"}
+				}
+				return [][][]byte{{[]byte("proof")}}
+			},
+		})
+		return NewClient(srv.URL, log.New())
+	}
+	r := &receiver{log: log.New(), cl: newServer("main", true), opts: ReceiverOptions{RPCCallRetry: uint64Ptr(2)}}
+	_, err := r.getProofForEvents(context.Background(), 10, &ProofEventsParam{})
+	require.Error(t, err)
+
+	// the resolver is called with the height and the last error
+	archive, broken := newServer("archive", false), newServer("broken", true)
+	var resolved []error
+	r.SetProofResolver(func(height int64, err error) *Client {
+		require.Equal(t, int64(10), height)
+		if resolved = append(resolved, err); len(resolved) == 1 {
+			return broken
+		}
+		return archive
+	})
+	proofs, err := r.getProofForEvents(context.Background(), 10, &ProofEventsParam{})
+	require.NoError(t, err)
+	require.Equal(t, [][][]byte{{[]byte("proof")}}, proofs)
+	require.Len(t, resolved, 2)
+	require.Equal(t, map[string]int{"main": 2, "broken": 1, "archive": 1}, calls)
+
+	// up to 1+RPCCallRetry times, or until it gives up
+	resolved = nil
+	r.SetProofResolver(func(int64, error) *Client {
+		resolved = append(resolved, nil)
+		return broken
+	})
+	_, err = r.getProofForEvents(context.Background(), 10, &ProofEventsParam{})
+	require.Error(t, err)
+	require.Len(t, resolved, 3)
+	r.SetProofResolver(func(int64, error) *Client { return nil })
+	_, err = r.getProofForEvents(context.Background(), 10, &ProofEventsParam{})
+	require.Error(t, err)
+}
+
+func TestClientIndexPrefersFasterEndpoint(t *testing.T) {
+	fast, slow := NewClient("http://fast/api/v3", log.New()), NewClient("http://slow/api/v3", log.New())
+	fast.stats.Latency, slow.stats.Latency = time.Millisecond, 100*time.Millisecond
+	r := &receiver{log: log.New(), cl: slow, cls: []*Client{slow, fast}}
+
+	picks := make([]int, 2)
+	for i := 0; i < 1000; i++ {
+		picks[r.clientIndex()]++
+	}
+	require.Greater(t, picks[1], 900)
+
+	stats := r.EndpointStats()
+	require.Equal(t, "http://slow/api/v3", stats[0].Endpoint)
+	require.Equal(t, 100*time.Millisecond, stats[0].Latency)
+}
+
+func TestRecoverPanic(t *testing.T) {
+	r := &receiver{log: log.New()}
+	var err error
+	func() {
+		defer r.recoverPanic(10, &err)
+		var bn *BlockNotification
+		_ = bn.Height // nil dereference
+	}()
+	require.EqualError(t, err, "recovered panic: height=10, runtime error: invalid memory address or nil pointer dereference")
+
+	err = nil
+	func() {
+		defer r.recoverPanic(11, &err)
+	}()
+	require.NoError(t, err)
+}
+
+func TestGetReceiptExtraProofs(t *testing.T) {
+	txr, err := vlcodec.RLP.MarshalToBytes(&TxResult{Status: 1})
+	require.NoError(t, err)
+	root, proofs := newTestMPT(t, string(txr))
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getProofForEvents": func(json.RawMessage) interface{} {
+			return [][][]byte{proofs[0], proofs[0]} // extra trailing proof
+		},
+	})
+	r := &receiver{log: log.New(), cl: NewClient(srv.URL, log.New())}
+	hr := &BlockHeaderResult{ReceiptHash: root}
+
+	_, err = r.getReceipt(context.Background(), hr, 10, HexBytes("0x01"), NewHexInt(0), nil, &eventLogRawFilter{})
+	require.Error(t, err)
+
+	r.opts.TolerateExtraProofs = true
+	receipt, err := r.getReceipt(context.Background(), hr, 10, HexBytes("0x01"), NewHexInt(0), nil, &eventLogRawFilter{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), receipt.Height)
+	require.Empty(t, receipt.Events)
+}
+
+func TestGetReceiptProofConcurrencyContext(t *testing.T) {
+	r := &receiver{log: log.New(), proofSem: make(chan struct{}, 1)}
+	r.proofSem <- struct{}{} // taken by another fetch
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := r.getReceipt(ctx, &BlockHeaderResult{}, 10, HexBytes("0x01"), NewHexInt(0), nil, &eventLogRawFilter{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGetReceiptSkipsOtherDestinations(t *testing.T) {
+	filter := &eventLogRawFilter{
+		addr:      []byte("bmc"),
+		signature: []byte(EventSignature),
+		next:      []byte("btp://0x1.hmny/0x01"),
+	}
+	event := func(addr, next string) string {
+		bs, err := vlcodec.RLP.MarshalToBytes(&EventLog{
+			Addr:    []byte(addr),
+			Indexed: [][]byte{[]byte(EventSignature), []byte(next), {0x01}},
+			Data:    [][]byte{[]byte("msg")},
+		})
+		require.NoError(t, err)
+		return string(bs)
+	}
+	getReceipt := func(events ...string) (*chain.Receipt, error) {
+		elRoot, elProofs := newTestMPT(t, events...)
+		txr, err := vlcodec.RLP.MarshalToBytes(&TxResult{Status: 1, EventLogsHash: elRoot})
+		require.NoError(t, err)
+		root, proofs := newTestMPT(t, string(txr))
+		srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+			"icx_getProofForEvents": func(json.RawMessage) interface{} {
+				return append([][][]byte{proofs[0]}, elProofs...)
+			},
+		})
+		r := &receiver{log: log.New(), cl: NewClient(srv.URL, log.New())}
+		var indexes []HexInt
+		for i := range events {
+			indexes = append(indexes, NewHexInt(int64(i)))
+		}
+		return r.getReceipt(context.Background(), &BlockHeaderResult{ReceiptHash: root},
+			10, HexBytes("0x01"), NewHexInt(0), indexes, filter)
+	}
+
+	receipt, err := getReceipt(
+		event("bmc", "btp://0x2.bsc/0x02"),
+		event("bmc", string(filter.next)),
+	)
+	require.NoError(t, err)
+	require.Len(t, receipt.Events, 1)
+	require.Equal(t, chain.BTPAddress(filter.next), receipt.Events[0].Next)
+
+	receipt, err = getReceipt(event("bmc", "btp://0x2.bsc/0x02"))
+	require.NoError(t, err)
+	require.Empty(t, receipt.Events)
+
+	_, err = getReceipt(event("other", string(filter.next)))
+	require.Error(t, err)
+}
+
+func TestNewReceiptMalformedEvent(t *testing.T) {
+	filter := &eventLogRawFilter{
+		addr:      []byte("bmc"),
+		signature: []byte(EventSignature),
+		next:      []byte("btp://0x1.hmny/0x01"),
+	}
+	r := &receiver{log: log.New()}
+	for _, el := range []*EventLog{
+		{Addr: filter.addr, Indexed: [][]byte{filter.signature, filter.next}, Data: [][]byte{[]byte("msg")}},
+		{Addr: filter.addr, Indexed: [][]byte{filter.signature, filter.next, {0x01}}},
+		{Addr: filter.addr},
+	} {
+		require.NotPanics(t, func() {
+			_, err := r.newReceipt(10, NewHexInt(0), []*EventLog{el}, filter)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "malformed event: height=10")
+		})
+	}
+}
+
+func TestNewReceiptEventMatchError(t *testing.T) {
+	filter := &eventLogRawFilter{
+		addr:      []byte("bmc"),
+		signature: []byte(EventSignature),
+		next:      []byte("btp://0x1.hmny/0x01"),
+	}
+	r := &receiver{log: log.New()}
+	els := []*EventLog{
+		{Addr: filter.addr, Indexed: [][]byte{filter.signature, filter.next, {0x01}}, Data: [][]byte{[]byte("msg")}},
+		{Addr: []byte("xyz"), Indexed: [][]byte{filter.signature, filter.next, {0x02}}, Data: [][]byte{[]byte("msg")}},
+	}
+	_, err := r.newReceipt(10, NewHexInt(3), els, filter)
+	require.True(t, errors.Is(err, ErrEventMatchFailed), "error: %v", err)
+	require.False(t, errors.Is(err, ErrReceiptEventCountMismatch))
+	var merr *EventMatchError
+	require.True(t, errors.As(err, &merr))
+	require.Equal(t, &EventMatchError{
+		Height: 10, Index: NewHexInt(3), Event: 1,
+		Field: "addr", Got: []byte("xyz"), Expected: filter.addr,
+	}, merr)
+
+	err = &ReceiptEventCountError{Height: 10, Index: NewHexInt(3), Got: 1, Expected: 2}
+	require.True(t, errors.Is(errors.Wrap(err, "getReceipt"), ErrReceiptEventCountMismatch))
+	require.Contains(t, err.Error(), "height=10, index=0x3, got=1, skipped=0, expected=2")
+}
+
+func TestReceiverStateCallback(t *testing.T) {
+	// a node without blocks
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	require.NoError(t, os.WriteFile(record, nil, 0644))
+	rs, err := NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(rs.URL(), log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 1, CatchUpBatchSize: 1},
+	}
+	var mtx sync.Mutex
+	var states []ConnState
+	var last ConnStateEvent
+	r.SetStateCallback(func(ev ConnStateEvent) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		states, last = append(states, ev.State), ev
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error { return nil }))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, []ConnState{ConnStateConnecting, ConnStateConnected, ConnStateDisconnected}, states)
+	require.Equal(t, rs.URL(), last.Endpoint)
+	require.ErrorIs(t, last.Reason, context.DeadlineExceeded)
+	require.Equal(t, "disconnected", last.State.String())
+}
+
+func TestNewReceiverRPCCallRetry(t *testing.T) {
+	src := chain.BTPAddress("btp://0x1.icon/cx997849d3920d338ed81800833fbb270c785e743d")
+	dst := chain.BTPAddress("btp://0x63564c40.hmny/0xa69712a3813d0505bbD55AeD3fd8471Bc2f722DD")
+	for _, tc := range []struct {
+		opts  string
+		retry int
+	}{
+		{`{}`, RPCCallRetry},
+		{`{"rpcCallRetry":0}`, 0},
+		{`{"rpcCallRetry":2}`, 2},
+		{`{"rpcCallRetry":20}`, 20},
+	} {
+		recv, err := NewReceiver(src, dst, []string{"http://localhost/api/v3"}, json.RawMessage(tc.opts), log.New())
+		require.NoError(t, err)
+		require.Equal(t, tc.retry, recv.(*receiver).opts.rpcCallRetry(), tc.opts)
+	}
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func TestReceiveLoopCancelMidBatch(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := openRecorder(record)
+	require.NoError(t, err)
+	for h := int64(1); h <= 5; h++ {
+		rec.recordNotification("/block", &BlockNotification{Hash: "0x01", Height: NewHexInt(h)})
+	}
+	rs, err := NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(rs.URL(), log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 5, CatchUpBatchSize: 5},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	calls := 0
+	require.NoError(t, r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		if calls++; calls == 2 {
+			cancel() // as if shut down while the callback was slow
+		}
+		return nil
+	}))
+	require.Equal(t, 2, calls)
+}
+
+// runFlakyReceiveLoop runs receiveLoop over blocks 1 to 5, the header of
+// block 3 failing to be fetched the first failures times, and returns the
+// number of fetches of the header of each block.
+func runFlakyReceiveLoop(t *testing.T, failures int, opts ReceiverOptions) map[int64]int {
+	var mtx sync.Mutex
+	fetches := make(map[int64]int)
+	rpc := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if fetches[height]++; height == 3 && fetches[height] <= failures {
+				return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "unavailable"}
+			}
+			return vlcodec.RLP.MustMarshalToBytes(&BlockHeader{
+				Height: height, Result: vlcodec.RLP.MustMarshalToBytes(&BlockHeaderResult{}),
+			})
+		},
+	})
+	// notifies blocks to 5, with events for the headers to be fetched
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !websocket.IsWebSocketUpgrade(req) {
+			rpc.Config.Handler.ServeHTTP(w, req)
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		require.NoError(t, conn.WriteJSON(&WSResponse{}))
+		height, err := br.Height.Value()
+		require.NoError(t, err)
+		for h := height; h <= 5; h++ {
+			if conn.WriteJSON(&BlockNotification{
+				Hash: "0x01", Height: NewHexInt(h),
+				Indexes: [][]HexInt{{}}, Events: [][][]HexInt{{}},
+			}) != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: opts,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	calls := 0
+	require.NoError(t, r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		if calls++; calls == 5 {
+			cancel()
+		}
+		return nil
+	}))
+	require.Equal(t, 5, calls)
+	mtx.Lock()
+	defer mtx.Unlock()
+	return fetches
+}
+
+func TestReceiveLoopMaxBlockAge(t *testing.T) {
+	// blocks 1, 2 and 4 are an hour old, block 3 is fresh
+	now := time.Now()
+	rpc := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			ts := now.Add(-time.Hour)
+			if height == 3 {
+				ts = now
+			}
+			return vlcodec.RLP.MustMarshalToBytes(&BlockHeader{
+				Height: height, Timestamp: ts.UnixNano() / int64(time.Microsecond),
+			})
+		},
+	})
+	var connects int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !websocket.IsWebSocketUpgrade(req) {
+			rpc.Config.Handler.ServeHTTP(w, req)
+			return
+		}
+		atomic.AddInt32(&connects, 1)
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		require.NoError(t, conn.WriteJSON(&WSResponse{}))
+		height, err := br.Height.Value()
+		require.NoError(t, err)
+		for h := height; h <= 4; h++ {
+			if conn.WriteJSON(&BlockNotification{Hash: "0x01", Height: NewHexInt(h)}) != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	r := &receiver{
+		log: log.New(),
+		cl:  NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{
+			SyncConcurrency: 1, CatchUpBatchSize: 1, RPCCallRetry: uint64Ptr(1),
+			FailOnGap: true, MaxBlockAge: 60,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	calls := 0
+	err := r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		calls++
+		return nil
+	})
+	// old blocks are forwarded until caught up at block 3, then rejected
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing block: height=4")
+	require.Equal(t, 3, calls)
+
+	// the stale block is not fetched again, even with StrictOrdering, and is
+	// returned once RPCCallRetry reconnects in a row notified it again
+	r.opts.FailOnGap, r.opts.StrictOrdering = false, true
+	atomic.StoreInt32(&connects, 0)
+	calls = 0
+	err = r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		calls++
+		return nil
+	})
+	var serr *StaleBlockError
+	require.True(t, errors.As(err, &serr), "error: %v", err)
+	require.Equal(t, int64(4), serr.Height)
+	require.Equal(t, 3, calls)
+	require.Equal(t, int32(2), atomic.LoadInt32(&connects))
+}
+
+func TestReceiveLoopDuplicateNotifications(t *testing.T) {
+	var mtx sync.Mutex
+	monitors := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		require.NoError(t, conn.WriteJSON(&WSResponse{}))
+		mtx.Lock()
+		monitors++
+		mtx.Unlock()
+		// each block notified twice
+		for h := int64(1); h <= 3; h++ {
+			for i := 0; i < 2; i++ {
+				bn := &BlockNotification{Hash: HexBytes(fmt.Sprintf("0x%064x", h)), Height: NewHexInt(h)}
+				if conn.WriteJSON(bn) != nil {
+					return
+				}
+			}
+		}
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 5, CatchUpBatchSize: 5, RPCCallRetry: uint64Ptr(1)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	calls := 0
+	require.NoError(t, r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		if calls++; calls == 3 {
+			cancel()
+		}
+		return nil
+	}))
+	require.Equal(t, 3, calls)
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, 1, monitors, "no reconnect")
+}
+
+func TestReceiveLoopPreserveResults(t *testing.T) {
+	fetches := runFlakyReceiveLoop(t, 2, ReceiverOptions{
+		SyncConcurrency: 5, CatchUpBatchSize: 5,
+		RPCCallRetry: uint64Ptr(1), PreserveResults: true,
+	})
+	require.Equal(t, map[int64]int{1: 1, 2: 1, 3: 3, 4: 1, 5: 1}, fetches)
+}
+
+func TestReceiveLoopStrictOrdering(t *testing.T) {
+	// retried beyond RPCCallRetry instead of reconnecting
+	fetches := runFlakyReceiveLoop(t, 3, ReceiverOptions{
+		SyncConcurrency: 5, CatchUpBatchSize: 5,
+		RPCCallRetry: uint64Ptr(1), StrictOrdering: true,
+	})
+	require.Equal(t, map[int64]int{1: 1, 2: 1, 3: 4, 4: 1, 5: 1}, fetches)
+}
+
+func TestGetTrustedReceipt(t *testing.T) {
+	bmc := Address("cx01" + strings.Repeat("00", 19))
+	addr, err := bmc.Value()
+	require.NoError(t, err)
+	filter := &eventLogRawFilter{
+		addr:      addr,
+		signature: []byte(EventSignature),
+		next:      []byte("btp://0x1.hmny/0x01"),
+	}
+	status := ResultStatusSuccess
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockByHeight": func(json.RawMessage) interface{} {
+			return map[string]interface{}{
+				"block_hash": "0a",
+				"height":     10,
+				"confirmed_transaction_list": []map[string]interface{}{
+					{"txHash": "0x00"}, {"txHash": "0x01"},
+				},
+			}
+		},
+		"icx_getTransactionResult": func(params json.RawMessage) interface{} {
+			var p TransactionHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, HexBytes("0x01"), p.Hash)
+			event := func(next, seq string) map[string]interface{} {
+				return map[string]interface{}{
+					"scoreAddress": bmc,
+					"indexed":      []string{EventSignature, next, seq},
+					"data":         []string{"0x6d7367"},
+				}
+			}
+			return map[string]interface{}{"status": status, "eventLogs": []interface{}{
+				event("btp://0x2.bsc/0x02", "0x1"),
+				event(string(filter.next), "0x80"),
+			}}
+		},
+	})
+	r := &receiver{log: log.New(), cl: NewClient(srv.URL, log.New())}
+
+	receipt, err := r.getTrustedReceipt(context.Background(), 10, "0x0a", NewHexInt(1),
+		[]HexInt{NewHexInt(0), NewHexInt(1)}, filter)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), receipt.Index)
+	require.Equal(t, []*chain.Event{{
+		Next: chain.BTPAddress(filter.next), Sequence: 0x80, Message: []byte("msg"),
+	}}, receipt.Events)
+
+	// the events of a failed transaction are skipped with CheckReceiptStatus
+	status = "0x0"
+	receipt, err = r.getTrustedReceipt(context.Background(), 10, "0x0a", NewHexInt(1), []HexInt{NewHexInt(1)}, filter)
+	require.NoError(t, err)
+	require.Len(t, receipt.Events, 1)
+	r.opts.CheckReceiptStatus = true
+	receipt, err = r.getTrustedReceipt(context.Background(), 10, "0x0a", NewHexInt(1), []HexInt{NewHexInt(1)}, filter)
+	require.NoError(t, err)
+	require.Empty(t, receipt.Events)
+	status = ResultStatusSuccess
+
+	_, err = r.getTrustedReceipt(context.Background(), 10, "0x0b", NewHexInt(1), []HexInt{NewHexInt(1)}, filter)
+	require.Error(t, err)
+	_, err = r.getTrustedReceipt(context.Background(), 10, "0x0a", NewHexInt(1), []HexInt{NewHexInt(2)}, filter)
+	require.Error(t, err)
+}
+
+func TestReceiveLoopPollingFallback(t *testing.T) {
+	rpc := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return &Block{Height: 3}
+		},
+		"icx_getBlockByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			return &Block{BlockHash: "01", Height: height}
+		},
+	})
+	// a node without websocket
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if websocket.IsWebSocketUpgrade(req) {
+			http.NotFound(w, req)
+			return
+		}
+		rpc.Config.Handler.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	r := &receiver{
+		log: log.New(),
+		cl:  NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{
+			SyncConcurrency: 5, CatchUpBatchSize: 5, RPCCallRetry: uint64Ptr(1),
+			PollingFallback: true, PollInterval: 1,
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	calls := 0
+	require.NoError(t, r.receiveLoop(ctx, 1, 0, func([]*chain.Receipt) error {
+		if calls++; calls == 3 {
+			cancel()
+		}
+		return nil
+	}))
+	require.Equal(t, 3, calls)
+}
+
+// testNode serves blocks 1 to len(seqs) over websocket and RPC, the block at
+// height h having a transaction with the messages of seqs[h-1] from bmc to
+// next, for a receiver with TrustNode to take them without proofs.
+type testNode struct {
+	*httptest.Server
+	mtx      sync.Mutex
+	monitors []int64 // heights requested by block monitors
+}
+
+func (n *testNode) monitorHeights() []int64 {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]int64(nil), n.monitors...)
+}
+
+func newTestNode(t *testing.T, bmc Address, next string, seqs [][]uint64) *testNode {
+	node := &testNode{}
+	blockHash := func(height int64) string { return fmt.Sprintf("%064x", height) }
+	rpc := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return &Block{Height: int64(len(seqs))}
+		},
+		"icx_getBlockByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			return map[string]interface{}{
+				"block_hash": blockHash(height),
+				"height":     height,
+				"confirmed_transaction_list": []map[string]interface{}{
+					{"txHash": NewHexInt(height)},
+				},
+			}
+		},
+		"icx_getTransactionResult": func(params json.RawMessage) interface{} {
+			var p TransactionHashParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := HexInt(p.Hash).Value()
+			require.NoError(t, err)
+			var logs []interface{}
+			for _, seq := range seqs[height-1] {
+				logs = append(logs, map[string]interface{}{
+					"scoreAddress": bmc,
+					"indexed":      []string{EventSignature, next, string(NewHexInt(int64(seq)))},
+					"data":         []string{"0x00"},
+				})
+			}
+			return map[string]interface{}{"status": ResultStatusSuccess, "eventLogs": logs}
+		},
+	})
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !websocket.IsWebSocketUpgrade(req) {
+			rpc.Config.Handler.ServeHTTP(w, req)
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var br BlockRequest
+		require.NoError(t, conn.ReadJSON(&br))
+		require.NoError(t, conn.WriteJSON(&WSResponse{}))
+		height, err := br.Height.Value()
+		require.NoError(t, err)
+		node.mtx.Lock()
+		node.monitors = append(node.monitors, height)
+		node.mtx.Unlock()
+		for ; height <= int64(len(seqs)); height++ {
+			bn := &BlockNotification{Hash: HexBytes("0x" + blockHash(height)), Height: NewHexInt(height)}
+			if n := len(seqs[height-1]); n > 0 {
+				var events []HexInt
+				for i := 0; i < n; i++ {
+					events = append(events, NewHexInt(int64(i)))
+				}
+				bn.Indexes = [][]HexInt{{"0x0"}}
+				bn.Events = [][][]HexInt{{events}}
+			}
+			if conn.WriteJSON(bn) != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(node.Close)
+	return node
+}
+
+// newTestNodeReceiver returns a receiver of the messages of a testNode.
+func newTestNodeReceiver(t *testing.T, seqs [][]uint64, opts ReceiverOptions) (*receiver, *testNode) {
+	bmc := Address("cx" + strings.Repeat("01", 20))
+	next := "btp://0x1.hmny/0x01"
+	node := newTestNode(t, bmc, next, seqs)
+	addr, err := bmc.Value()
+	require.NoError(t, err)
+	if opts.SyncConcurrency == 0 {
+		opts.SyncConcurrency, opts.CatchUpBatchSize = 5, 5
+	}
+	if opts.RPCCallRetry == nil {
+		opts.RPCCallRetry = uint64Ptr(1)
+	}
+	opts.TrustNode = true
+	return &receiver{
+		log:  log.New(),
+		cl:   NewClient(node.URL, log.New()),
+		opts: opts,
+		blockReq: BlockRequest{EventFilters: []*EventFilter{{
+			Addr: bmc, Signature: EventSignature, Indexed: []*string{&next},
+		}}},
+		logFilter: eventLogRawFilter{
+			addr:      addr,
+			signature: []byte(EventSignature),
+			next:      []byte(next),
+		},
+	}, node
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	r, _ := newTestNodeReceiver(t, [][]uint64{{1}, nil, {2, 3}, {4}}, ReceiverOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	evtCh := make(chan *chain.Event)
+	errCh, err := r.SubscribeEvents(ctx, evtCh, chain.SubscribeOptions{Seq: 1, Height: 1})
+	require.NoError(t, err)
+	var seqs []uint64
+	for len(seqs) < 3 {
+		select {
+		case err := <-errCh:
+			t.Fatalf("subscription failed: %v", err)
+		case event := <-evtCh:
+			seqs = append(seqs, event.Sequence)
+		}
+	}
+	require.Equal(t, []uint64{2, 3, 4}, seqs)
+	cancel()
+	_, ok := <-errCh
+	require.False(t, ok)
+
+	events, err := r.SubscribeN(context.Background(), chain.SubscribeOptions{Seq: 0, Height: 1}, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, uint64(1), events[0].Sequence)
+	require.Equal(t, uint64(2), events[1].Sequence)
+}
+
+func TestSubscribeMessageBuffer(t *testing.T) {
+	seqsOf := func(msgs []*chain.Message) (seqs []uint64) {
+		for _, msg := range msgs {
+			for _, receipt := range msg.Receipts {
+				for _, event := range receipt.Events {
+					seqs = append(seqs, event.Sequence)
+				}
+			}
+		}
+		return seqs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// blocks are fetched while the consumer doesn't read msgCh
+	r, _ := newTestNodeReceiver(t, [][]uint64{{1}, {2}, {3}, {4}}, ReceiverOptions{
+		SyncConcurrency: 1, CatchUpBatchSize: 1, MessageBufferSize: 3,
+	})
+	msgCh := make(chan *chain.Message)
+	errCh, err := r.Subscribe(ctx, msgCh, chain.SubscribeOptions{Height: 1})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return r.DebugState().BufferedMessages == 3 // and one being sent
+	}, 5*time.Second, 10*time.Millisecond)
+	var msgs []*chain.Message
+	for len(msgs) < 4 {
+		msgs = append(msgs, <-msgCh)
+	}
+	require.Equal(t, []uint64{1, 2, 3, 4}, seqsOf(msgs))
+	require.Zero(t, r.DebugState().BufferedMessages)
+
+	// the subscription fails once the buffer is full, after the messages
+	// buffered before
+	r, _ = newTestNodeReceiver(t, [][]uint64{{1}, {2}, {3}, {4}}, ReceiverOptions{
+		SyncConcurrency: 1, CatchUpBatchSize: 1, MessageBufferSize: 1, FailOnFullBuffer: true,
+	})
+	errCh, err = r.Subscribe(ctx, msgCh, chain.SubscribeOptions{Height: 1})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(r.DebugState().LastError, ErrMessageBufferFull.Error())
+	}, 5*time.Second, 10*time.Millisecond)
+	msgs = nil
+	for err == nil {
+		select {
+		case msg := <-msgCh:
+			msgs = append(msgs, msg)
+		case err = <-errCh:
+		}
+	}
+	require.ErrorIs(t, err, ErrMessageBufferFull)
+	require.Equal(t, []uint64{1, 2}, seqsOf(msgs))
+}
+
+func TestSyncVerifierStuck(t *testing.T) {
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(json.RawMessage) interface{} {
+			return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "unavailable"}
+		},
+	})
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 2, SyncMaxAttempts: 2},
+	}
+	vr := NewSampleTestVerifier()
+	err := r.syncVerifier(context.Background(), vr, vr.Next()+2, 0)
+	var serr *SyncStuckError
+	require.True(t, errors.As(err, &serr), "error: %v", err)
+	require.Equal(t, vr.Next(), serr.Height)
+	require.Equal(t, 2, serr.Attempts)
+	require.Contains(t, serr.Error(), "unavailable")
+}
+
+func TestSyncVerifierWindow(t *testing.T) {
+	var mtx sync.Mutex
+	var heights map[int64]bool
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			mtx.Lock()
+			heights[height] = true
+			mtx.Unlock()
+			return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "unavailable"}
+		},
+	})
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 2, SyncMaxAttempts: 1},
+	}
+	for _, tc := range []struct {
+		window  uint64
+		fetched int
+	}{
+		{0, 2},
+		{7, 7},
+	} {
+		heights = make(map[int64]bool)
+		vr := NewSampleTestVerifier()
+		require.Error(t, r.syncVerifier(context.Background(), vr, vr.Next()+100, tc.window))
+		mtx.Lock()
+		require.Len(t, heights, tc.fetched, "window=%d", tc.window)
+		mtx.Unlock()
+	}
+
+	src := chain.BTPAddress("btp://0x1.icon/cx997849d3920d338ed81800833fbb270c785e743d")
+	dst := chain.BTPAddress("btp://0x63564c40.hmny/0xa69712a3813d0505bbD55AeD3fd8471Bc2f722DD")
+	recv, err := NewReceiver(src, dst, []string{"http://localhost/api/v3"},
+		json.RawMessage(`{"syncConcurrency":10,"initialSyncConcurrency":100000}`), log.New())
+	require.NoError(t, err)
+	opts := recv.(*receiver).opts
+	require.Equal(t, uint64(MonitorBlockMaxConcurrency), opts.InitialSyncConcurrency)
+	require.Equal(t, uint64(10), opts.SteadySyncConcurrency)
+}
+
+func TestSyncVerifierContextCancel(t *testing.T) {
+	var mtx sync.Mutex
+	fetches := 0
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(json.RawMessage) interface{} {
+			mtx.Lock()
+			fetches++
+			mtx.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			return &jsonrpc.Error{Code: JsonrpcErrorCodeSystem, Message: "unavailable"}
+		},
+	})
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{SyncConcurrency: 2, RPCCallRetry: uint64Ptr(100)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	vr := NewSampleTestVerifier()
+	start := time.Now()
+	require.ErrorIs(t, r.syncVerifier(ctx, vr, vr.Next()+10, 0), context.DeadlineExceeded)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// the pending requests are not retried
+	time.Sleep(100 * time.Millisecond)
+	mtx.Lock()
+	n := fetches
+	mtx.Unlock()
+	time.Sleep(time.Second)
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Equal(t, n, fetches)
+}
+
+func TestReceiveLoopMaxBlockGap(t *testing.T) {
+	seqs := make([][]uint64, 10)
+	for i := range seqs {
+		seqs[i] = []uint64{uint64(i + 1)}
+	}
+	r, node := newTestNodeReceiver(t, seqs, ReceiverOptions{MaxBlockGap: 3, PollInterval: 1})
+	events, err := r.SubscribeN(context.Background(), chain.SubscribeOptions{Seq: 0, Height: 1}, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 10)
+	for i, event := range events {
+		require.Equal(t, uint64(i+1), event.Sequence)
+	}
+	// blocks up to the head are polled, monitoring resumes after it
+	require.Equal(t, []int64{11}, node.monitorHeights())
+}
+
+func TestReceiveLoopBlockObserver(t *testing.T) {
+	r, _ := newTestNodeReceiver(t, [][]uint64{{1}, nil, {2}}, ReceiverOptions{})
+	observed := make(chan *BlockNotification, 3)
+	r.SetBlockObserver(func(bn *BlockNotification) {
+		observed <- bn
+	})
+	_, err := r.SubscribeN(context.Background(), chain.SubscribeOptions{Seq: 0, Height: 1}, 2)
+	require.NoError(t, err)
+	for h := int64(1); h <= 3; h++ {
+		select {
+		case bn := <-observed:
+			require.Equal(t, NewHexInt(h), bn.Height)
+			require.Equal(t, len(bn.Indexes) > 0, h != 2)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d not observed", h)
+		}
+	}
+}
+
+func TestSubscribeHead(t *testing.T) {
+	r, _ := newTestNodeReceiver(t, [][]uint64{{1}, nil, {2}}, ReceiverOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgCh := make(chan *chain.Message)
+	_, err := r.Subscribe(ctx, msgCh, chain.SubscribeOptions{Seq: 0, Height: 1})
+	require.NoError(t, err)
+	for seq := uint64(1); seq <= 2; {
+		select {
+		case msg := <-msgCh:
+			for _, receipt := range msg.Receipts {
+				seq += uint64(len(receipt.Events))
+				// raised by the block notifications, up to the last one
+				require.GreaterOrEqual(t, msg.Head, receipt.Height)
+				require.LessOrEqual(t, msg.Head, uint64(3))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("seq %d not received", seq)
+		}
+	}
+}
+
+func TestGetValidatorsByHashRetry(t *testing.T) {
+	data, err := vlcodec.BC.MarshalToBytes(getSampleValidators())
+	require.NoError(t, err)
+	var mtx sync.Mutex
+	corrupt := 0 // number of corrupt responses left
+	fetches := 0
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getDataByHash": func(json.RawMessage) interface{} {
+			mtx.Lock()
+			defer mtx.Unlock()
+			fetches++
+			if corrupt > 0 {
+				corrupt--
+				return data[:len(data)/2]
+			}
+			return data
+		},
+	})
+	r := &receiver{
+		log:  log.New(),
+		cl:   NewClient(srv.URL, log.New()),
+		opts: ReceiverOptions{RPCCallRetry: uint64Ptr(2)},
+	}
+	hash := crypto.SHA3Sum256(data)
+
+	corrupt = 2
+	validators, err := r.getValidatorsByHash(context.Background(), hash)
+	require.NoError(t, err)
+	require.Equal(t, getSampleValidators(), validators)
+	require.Equal(t, 3, fetches)
+
+	corrupt, fetches = 3, 0
+	_, err = r.getValidatorsByHash(context.Background(), hash)
+	var merr *DataHashMismatchError
+	require.True(t, errors.As(err, &merr), "error: %v", err)
+	require.Equal(t, len(data)/2, merr.Len)
+	require.Equal(t, 3, fetches)
+}
+
+func TestProcessBlock(t *testing.T) {
+	bmc := Address("cx" + strings.Repeat("01", 20))
+	next := "btp://0x1.hmny/0x01"
+	addr, err := bmc.Value()
+	require.NoError(t, err)
+	el, err := vlcodec.RLP.MarshalToBytes(&EventLog{
+		Addr:    addr,
+		Indexed: [][]byte{[]byte(EventSignature), []byte(next), {0x05}},
+		Data:    [][]byte{[]byte("msg")},
+	})
+	require.NoError(t, err)
+	elRoot, elProofs := newTestMPT(t, string(el))
+	txr, err := vlcodec.RLP.MarshalToBytes(&TxResult{Status: 1, EventLogsHash: elRoot})
+	require.NoError(t, err)
+	root, proofs := newTestMPT(t, string(txr))
+	result, err := vlcodec.RLP.MarshalToBytes(&BlockHeaderResult{ReceiptHash: root})
+	require.NoError(t, err)
+
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockByHeight": func(json.RawMessage) interface{} {
+			return map[string]interface{}{
+				"block_hash":                 "0a",
+				"height":                     10,
+				"confirmed_transaction_list": []map[string]interface{}{{"txHash": "0x01"}},
+			}
+		},
+		"icx_getTransactionResult": func(json.RawMessage) interface{} {
+			return map[string]interface{}{"status": ResultStatusSuccess, "eventLogs": []interface{}{
+				map[string]interface{}{
+					"scoreAddress": bmc,
+					"indexed":      []string{EventSignature, next, "0x5"},
+					"data":         []string{"0x6d7367"},
+				},
+			}}
+		},
+		"icx_getBlockHeaderByHeight": func(json.RawMessage) interface{} {
+			return vlcodec.RLP.MustMarshalToBytes(&BlockHeader{Height: 10, Result: result})
+		},
+		"icx_getProofForEvents": func(params json.RawMessage) interface{} {
+			var p ProofEventsParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			require.Equal(t, HexBytes("0x0a"), p.BlockHash)
+			return [][][]byte{proofs[0], elProofs[0]}
+		},
+	})
+	r := &receiver{
+		log: log.New(),
+		cl:  NewClient(srv.URL, log.New()),
+		blockReq: BlockRequest{EventFilters: []*EventFilter{{
+			Addr: bmc, Signature: EventSignature, Indexed: []*string{&next},
+		}}},
+		logFilter: eventLogRawFilter{
+			addr:      addr,
+			signature: []byte(EventSignature),
+			next:      []byte(next),
+		},
+	}
+
+	receipts, err := r.ProcessBlock(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, []*chain.Receipt{{
+		Index:  0,
+		Height: 10,
+		Events: []*chain.Event{{Next: chain.BTPAddress(next), Sequence: 5, Message: []byte("msg")}},
+	}}, receipts)
+
+	// the events are proven against the header
+	result, err = vlcodec.RLP.MarshalToBytes(&BlockHeaderResult{ReceiptHash: elRoot})
+	require.NoError(t, err)
+	_, err = r.ProcessBlock(context.Background(), 10)
+	require.Error(t, err)
+}
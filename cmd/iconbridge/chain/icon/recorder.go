@@ -0,0 +1,241 @@
+package icon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
+	"github.com/pkg/errors"
+)
+
+// recordEntry is a line of a record file: either a JSON-RPC call with its
+// result or error, or a notification received on a websocket path.
+type recordEntry struct {
+	Method       string          `json:"method,omitempty"`
+	Params       json.RawMessage `json:"params,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        *jsonrpc.Error  `json:"error,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	Notification json.RawMessage `json:"notification,omitempty"`
+}
+
+// recorder appends the traffic of clients to a record file.
+type recorder struct {
+	mtx sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+var (
+	recordersMtx sync.Mutex
+	recorders    = make(map[string]*recorder)
+)
+
+// openRecorder returns the recorder for the file at path, shared by all the
+// clients recording to it.
+func openRecorder(path string) (*recorder, error) {
+	recordersMtx.Lock()
+	defer recordersMtx.Unlock()
+	if rec, ok := recorders[path]; ok {
+		return rec, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	rec := &recorder{f: f, enc: json.NewEncoder(f)}
+	recorders[path] = rec
+	return rec, nil
+}
+
+func (rec *recorder) write(e *recordEntry) {
+	rec.mtx.Lock()
+	defer rec.mtx.Unlock()
+	rec.enc.Encode(e)
+}
+
+func (rec *recorder) recordCall(method string, reqPtr, respPtr interface{}, err error) {
+	e := &recordEntry{Method: method}
+	if reqPtr != nil {
+		e.Params, _ = json.Marshal(reqPtr)
+	}
+	if err != nil {
+		je, ok := err.(*jsonrpc.Error)
+		if !ok {
+			return // the endpoint failed, there is no response to replay
+		}
+		e.Error = je
+	} else if e.Result, err = json.Marshal(respPtr); err != nil {
+		return
+	}
+	rec.write(e)
+}
+
+func (rec *recorder) recordNotification(path string, v interface{}) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	rec.write(&recordEntry{Path: path, Notification: bs})
+}
+
+// ReplayServer serves the traffic of a record file, written by a client
+// with ClientOptions.Record, so that a run can be reproduced without the
+// original node.
+//
+// Calls are answered with the recorded responses to the same method and
+// params, in recorded order; the last one is repeated once exhausted.
+// Monitors are sent the recorded notifications of their path from the
+// requested height on.
+type ReplayServer struct {
+	srv      *http.Server
+	ln       net.Listener
+	mtx      sync.Mutex
+	calls    map[string][]*recordEntry
+	notifies map[string][]json.RawMessage
+}
+
+// NewReplayServer loads the record file at path and starts serving it on a
+// loopback address.
+func NewReplayServer(path string) (*ReplayServer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &ReplayServer{
+		calls:    make(map[string][]*recordEntry),
+		notifies: make(map[string][]json.RawMessage),
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		e := &recordEntry{}
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			return nil, errors.Wrapf(err, "invalid record: line=%d, %v", line, err)
+		}
+		if e.Path != "" {
+			s.notifies[e.Path] = append(s.notifies[e.Path], e.Notification)
+		} else {
+			key := replayKey(e.Method, e.Params)
+			s.calls[key] = append(s.calls[key], e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.ln, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	s.srv = &http.Server{Handler: http.HandlerFunc(s.serveHTTP)}
+	go s.srv.Serve(s.ln)
+	return s, nil
+}
+
+// URL returns the endpoint to create a replaying Client with.
+func (s *ReplayServer) URL() string {
+	return "http://" + s.ln.Addr().String()
+}
+
+func (s *ReplayServer) Close() error {
+	return s.srv.Close()
+}
+
+func replayKey(method string, params json.RawMessage) string {
+	var buf bytes.Buffer
+	if len(params) > 0 && json.Compact(&buf, params) == nil {
+		return method + buf.String()
+	}
+	return method + string(params)
+}
+
+func (s *ReplayServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveMonitor(w, r)
+		return
+	}
+	req := &jsonrpc.Request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := map[string]interface{}{"jsonrpc": jsonrpc.Version, "id": req.ID}
+	s.mtx.Lock()
+	key := replayKey(req.Method, req.Params)
+	es := s.calls[key]
+	var e *recordEntry
+	if len(es) > 0 {
+		e = es[0]
+		if len(es) > 1 {
+			s.calls[key] = es[1:]
+		}
+	}
+	s.mtx.Unlock()
+	switch {
+	case e == nil:
+		resp["error"] = &jsonrpc.Error{
+			Code:    jsonrpc.ErrorCodeMethodNotFound,
+			Message: "not recorded: " + key,
+		}
+	case e.Error != nil:
+		resp["error"] = e.Error
+	default:
+		resp["result"] = e.Result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *ReplayServer) serveMonitor(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var req struct {
+		Height HexInt `json:"height"`
+	}
+	if err := conn.ReadJSON(&req); err != nil {
+		return
+	}
+	height, err := req.Height.Value()
+	if err != nil {
+		conn.WriteJSON(&WSResponse{Code: -1, Message: err.Error()})
+		return
+	}
+	if err := conn.WriteJSON(&WSResponse{}); err != nil {
+		return
+	}
+	path := r.URL.Path[strings.LastIndex(r.URL.Path, "/"):]
+	s.mtx.Lock()
+	notifies := s.notifies[path]
+	s.mtx.Unlock()
+	for _, n := range notifies {
+		var v struct {
+			Height HexInt `json:"height"`
+		}
+		if json.Unmarshal(n, &v) == nil {
+			if h, err := v.Height.Value(); err == nil && h < height {
+				continue
+			}
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, n); err != nil {
+			return
+		}
+	}
+	// keep the connection open until the client closes it
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package icon
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/icon-project/icon-bridge/common/jsonrpc"
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/stretchr/testify/require"
+)
+
+func monitorHeights(t *testing.T, cl *Client, height int64, n int) []int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []int64
+	err := cl.MonitorBlock(ctx, &BlockRequest{Height: NewHexInt(height)},
+		func(conn *websocket.Conn, v *BlockNotification) error {
+			h, err := v.Height.Value()
+			require.NoError(t, err)
+			if got = append(got, h); len(got) == n {
+				cancel()
+			}
+			return nil
+		},
+		func(conn *websocket.Conn) {},
+		func(conn *websocket.Conn, err error) {})
+	require.ErrorIs(t, err, context.Canceled)
+	return got
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	source, record := filepath.Join(dir, "source.jsonl"), filepath.Join(dir, "record.jsonl")
+
+	rec, err := openRecorder(source)
+	require.NoError(t, err)
+	for h := int64(1); h <= 3; h++ {
+		rec.recordNotification("/block", &BlockNotification{Height: NewHexInt(h)})
+	}
+
+	// record calls to a node and notifications from a replayed source
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getLastBlock": func(json.RawMessage) interface{} {
+			return &Block{Height: 5}
+		},
+		"icx_getDataByHash": func(json.RawMessage) interface{} {
+			return &jsonrpc.Error{Code: jsonrpc.ErrorCodeInvalidParams, Message: "not found"}
+		},
+	})
+	cl := newTestClient(t, srv.URL, &ClientOptions{Record: record})
+	blk, err := cl.GetLastBlock()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), blk.Height)
+	_, err = cl.GetDataByHash(context.Background(), &DataHashParam{Hash: "0x01"})
+	require.Error(t, err)
+
+	rs, err := NewReplayServer(source)
+	require.NoError(t, err)
+	defer rs.Close()
+	cl = newTestClient(t, rs.URL(), &ClientOptions{Record: record})
+	require.Equal(t, []int64{2, 3}, monitorHeights(t, cl, 2, 2))
+
+	// replay the record without the node
+	srv.Close()
+	rs, err = NewReplayServer(record)
+	require.NoError(t, err)
+	defer rs.Close()
+	cl = NewClient(rs.URL(), log.New())
+	blk, err = cl.GetLastBlock()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), blk.Height)
+	_, err = cl.GetDataByHash(context.Background(), &DataHashParam{Hash: "0x01"})
+	require.Error(t, err)
+	require.Equal(t, jsonrpc.ErrorCodeInvalidParams, err.(*jsonrpc.Error).Code)
+	_, err = cl.GetDataByHash(context.Background(), &DataHashParam{Hash: "0x02"})
+	require.Error(t, err)
+	require.Equal(t, jsonrpc.ErrorCodeMethodNotFound, err.(*jsonrpc.Error).Code)
+	require.Equal(t, []int64{3}, monitorHeights(t, cl, 3, 1))
+}
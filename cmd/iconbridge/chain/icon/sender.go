@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"math/big"
 	"net/url"
-	"strconv"
 	"time"
 
 	"github.com/icon-project/icon-bridge/cmd/iconbridge/chain"
@@ -62,7 +61,11 @@ func NewSender(
 	if err := json.Unmarshal(rawOpts, &s.opts); err != nil {
 		return nil, err
 	}
-	s.cl = NewClient(urls[0], l)
+	cl, err := NewClientWithOptions(urls[0], l, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.cl = cl
 	return s, nil
 }
 
@@ -112,7 +115,7 @@ func (s *sender) Status(ctx context.Context) (*chain.BMCLinkStatus, error) {
 		},
 	}
 	bs := &BMCStatus{}
-	err := mapError(s.cl.Call(p, bs))
+	err := mapError(s.cl.CallContext(ctx, p, bs))
 	if err != nil {
 		return nil, err
 	}
@@ -193,7 +196,7 @@ func (s *sender) Segment(
 }
 
 func (s *sender) Balance(ctx context.Context) (balance, threshold *big.Int, err error) {
-	bal, err := s.cl.GetBalance(&AddressParam{Address: Address(s.w.Address())})
+	bal, err := s.cl.GetBalanceContext(ctx, &AddressParam{Address: Address(s.w.Address())})
 	return bal, &s.opts.BalanceThreshold.Int, err
 }
 
@@ -258,7 +261,7 @@ SignLoop:
 				return ctx.Err()
 			default:
 			}
-			txh, err := tx.cl.SendTransaction(tx.txParam)
+			txh, err := tx.cl.SendTransactionContext(ctx, tx.txParam)
 			if txh != nil {
 				tx.txHashParam = &TransactionHashParam{*txh}
 				// tx.cl.log.WithFields(log.Fields{
@@ -279,11 +282,12 @@ SignLoop:
 						<-time.After(defaultRelayReSendInterval)
 						continue SendLoop
 					case JsonrpcErrorCodeSystem:
-						if subEc, err := strconv.ParseInt(je.Message[1:5], 0, 32); err == nil {
+						if subEc, ok := SystemErrorCodeOf(je); ok {
 							switch subEc {
 							case DuplicateTransactionError:
 								return nil
 							case ExpiredTransactionError:
+								tx.txParam.Timestamp = "" // re-sign with a new timestamp
 								continue SignLoop
 							}
 						}
@@ -306,7 +310,7 @@ func (tx *relayTx) Receipt(ctx context.Context) (blockHeight uint64, err error)
 			return 0, ctx.Err()
 		default:
 		}
-		txr, err := tx.cl.GetTransactionResult(tx.txHashParam)
+		txr, err := tx.cl.GetTransactionResultContext(ctx, tx.txHashParam)
 		if err != nil {
 			if je, ok := err.(*jsonrpc.Error); ok {
 				switch je.Code {
@@ -333,7 +337,7 @@ func mapError(err error) error {
 			case JsonrpcErrorCodeTxPoolOverflow:
 				return ErrSendFailByOverflow
 			case JsonrpcErrorCodeSystem:
-				if subEc, err := strconv.ParseInt(re.Message[1:5], 0, 32); err == nil {
+				if subEc, ok := SystemErrorCodeOf(re); ok {
 					//TODO return JsonRPC Error
 					switch subEc {
 					case ExpiredTransactionError:
@@ -0,0 +1,80 @@
+package icon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// seqIndex maps the sequences of the events received to the heights of the
+// blocks they were emitted at, so that a subscription resuming from a known
+// sequence can start from its height instead of an earlier checkpoint.
+// Sequences are added in increasing order and appended to a file, if any.
+type seqIndex struct {
+	mtx     sync.Mutex
+	seqs    []uint64
+	heights []uint64
+	f       *os.File // nil if not persisted
+}
+
+// loadSeqIndex loads the index from the file at path, created if missing,
+// to which the sequences added later are appended.
+func loadSeqIndex(path string) (*seqIndex, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	si := &seqIndex{}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		var seq, height uint64
+		if _, err := fmt.Sscanf(sc.Text(), "%d %d", &seq, &height); err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "invalid seq index: line=%d, %v", line, err)
+		}
+		si.add(seq, height)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	si.f = f
+	return si, nil
+}
+
+func (si *seqIndex) add(seq, height uint64) bool {
+	if n := len(si.seqs); n > 0 && seq <= si.seqs[n-1] {
+		return false
+	}
+	si.seqs = append(si.seqs, seq)
+	si.heights = append(si.heights, height)
+	return true
+}
+
+// Add records that the event with seq was emitted at height. Sequences not
+// greater than the last one added are ignored.
+func (si *seqIndex) Add(seq, height uint64) error {
+	si.mtx.Lock()
+	defer si.mtx.Unlock()
+	if !si.add(seq, height) || si.f == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(si.f, "%d %d\n", seq, height)
+	return err
+}
+
+// Height returns the height to search the event with seq from: the height of
+// the greatest sequence added not greater than seq.
+func (si *seqIndex) Height(seq uint64) (uint64, bool) {
+	si.mtx.Lock()
+	defer si.mtx.Unlock()
+	i := sort.Search(len(si.seqs), func(i int) bool { return si.seqs[i] > seq })
+	if i == 0 {
+		return 0, false
+	}
+	return si.heights[i-1], true
+}
@@ -0,0 +1,44 @@
+package icon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeqIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seq.idx")
+	si, err := loadSeqIndex(path)
+	require.NoError(t, err)
+	_, ok := si.Height(1)
+	require.False(t, ok)
+
+	require.NoError(t, si.Add(3, 100))
+	require.NoError(t, si.Add(4, 100))
+	require.NoError(t, si.Add(7, 250))
+	require.NoError(t, si.Add(5, 300)) // out of order, ignored
+
+	si, err = loadSeqIndex(path)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		seq    uint64
+		height uint64
+		ok     bool
+	}{
+		{2, 0, false},
+		{3, 100, true},
+		{5, 100, true},
+		{7, 250, true},
+		{10, 250, true},
+	} {
+		height, ok := si.Height(tc.seq)
+		require.Equal(t, tc.ok, ok, "seq=%d", tc.seq)
+		require.Equal(t, tc.height, height, "seq=%d", tc.seq)
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("3 100\nx\n"), 0644))
+	_, err = loadSeqIndex(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package icon
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var txSerializeExcludes = map[string]bool{"signature": true}
+
+// txSigner signs transactions as configured by ClientOptions, needing no
+// node, for Client.SignTransaction and SignTransactionOffline.
+type txSigner struct {
+	hash            func([]byte) []byte
+	excludes        map[string]bool // fields not hashed
+	verifySignature bool
+	maxStepLimit    *big.Int // nil if not bounded
+	maxValue        *big.Int // nil if not bounded
+}
+
+func newTxSigner(opts *ClientOptions) (*txSigner, error) {
+	s := &txSigner{
+		hash:            hashFuncs[opts.Hash],
+		excludes:        txSerializeExcludes,
+		verifySignature: opts.VerifySignature,
+	}
+	if s.hash == nil {
+		return nil, fmt.Errorf("unknown hash %q", opts.Hash)
+	}
+	if opts.MaxStepLimit > 0 {
+		s.maxStepLimit = new(big.Int).SetUint64(opts.MaxStepLimit)
+	}
+	if opts.MaxValue != "" {
+		v, ok := new(big.Int).SetString(opts.MaxValue, 0)
+		if !ok || v.Sign() < 0 {
+			return nil, fmt.Errorf("invalid max value %q", opts.MaxValue)
+		}
+		s.maxValue = v
+	}
+	if len(opts.SerializeExcludes) > 0 {
+		s.excludes = map[string]bool{"signature": true}
+		for _, field := range opts.SerializeExcludes {
+			s.excludes[field] = true
+		}
+	}
+	return s, nil
+}
+
+// sign signs p with w, see Client.SignTransactionWithExcludes.
+func (s *txSigner) sign(w Wallet, p *TransactionParam, excludes map[string]bool) error {
+	if err := s.checkBounds(p); err != nil {
+		return err
+	}
+	if err := ValidateAddress(p.FromAddress); err != nil {
+		return errors.Wrapf(err, "from: %v", err)
+	} else if !strings.HasPrefix(string(p.FromAddress), "hx") {
+		return fmt.Errorf("from: not an account address %q", p.FromAddress)
+	}
+	if p.Timestamp == "" {
+		p.Timestamp = NewHexInt(time.Now().UnixNano() / int64(time.Microsecond))
+	}
+	txHash, err := s.txHash(p, excludes)
+	if err != nil {
+		return err
+	}
+	p.TxHash = NewHexBytes(txHash)
+	sig, err := w.Sign(txHash)
+	if err != nil {
+		return err
+	}
+	if s.verifySignature {
+		signer, err := recoverAddress(txHash, sig)
+		if err != nil {
+			return errors.Wrapf(err, "invalid signature: %v", err)
+		}
+		if signer != Address(w.Address()) {
+			return fmt.Errorf("signature mismatch: signer=%s, wallet=%s", signer, w.Address())
+		}
+	}
+	p.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// txHash returns the hash of p without the fields in excludes, those of
+// txSerializeExcludes if nil.
+func (s *txSigner) txHash(p *TransactionParam, excludes map[string]bool) ([]byte, error) {
+	js, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if excludes == nil {
+		excludes = txSerializeExcludes
+	}
+	bs, err := SerializeJSON(js, nil, excludes)
+	if err != nil {
+		return nil, err
+	}
+	return s.hash(append([]byte("icx_sendTransaction."), bs...)), nil
+}
+
+// checkBounds checks that the StepLimit and Value of p are non-negative
+// integers within the bounds of the signer.
+func (s *txSigner) checkBounds(p *TransactionParam) error {
+	for _, f := range []struct {
+		name  string
+		value HexInt
+		max   *big.Int
+	}{
+		{"stepLimit", p.StepLimit, s.maxStepLimit},
+		{"value", p.Value, s.maxValue},
+	} {
+		if f.value == "" {
+			continue // optional value, or missing stepLimit left to the node
+		}
+		v, err := f.value.BigInt()
+		if err != nil || v.Sign() < 0 || (f.max != nil && v.Cmp(f.max) > 0) {
+			return &TxBoundsError{Field: f.name, Value: f.value, Max: f.max}
+		}
+	}
+	return nil
+}
+
+// SignedTransaction is a signed transaction with its hash, which isn't part
+// of the JSON of TransactionParam, to be sent by another machine than the
+// one which signed it, e.g. an air-gapped one.
+type SignedTransaction struct {
+	TxHash HexBytes          `json:"txHash"`
+	Param  *TransactionParam `json:"param"`
+}
+
+// SignTransactionOffline signs p with w like the SignTransaction of a Client
+// created with opts, which may be nil, but without a Client. p must be
+// complete, with its NetworkID and StepLimit, as no node is asked for them.
+// The result is meant to be serialized in JSON and sent as is with
+// Client.SendSignedTransaction.
+func SignTransactionOffline(w Wallet, p *TransactionParam, opts *ClientOptions) (*SignedTransaction, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	s, err := newTxSigner(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sign(w, p, s.excludes); err != nil {
+		return nil, err
+	}
+	return &SignedTransaction{TxHash: p.TxHash, Param: p}, nil
+}
+
+// SendSignedTransaction sends the transaction signed by
+// SignTransactionOffline without signing it again. Its hash is checked
+// first against the one computed by the client, so that signing with a
+// different Hash or SerializeExcludes than the client is reported without
+// a request.
+func (c *Client) SendSignedTransaction(st *SignedTransaction) (*HexBytes, error) {
+	if st.Param == nil || st.Param.Signature == "" {
+		return nil, fmt.Errorf("transaction not signed")
+	}
+	txHash, err := c.signer.txHash(st.Param, c.signer.excludes)
+	if err != nil {
+		return nil, err
+	}
+	if NewHexBytes(txHash) != st.TxHash {
+		return nil, fmt.Errorf("transaction hash mismatch: signed=%s, computed=%s", st.TxHash, NewHexBytes(txHash))
+	}
+	st.Param.TxHash = st.TxHash
+	return c.SendTransaction(st.Param)
+}
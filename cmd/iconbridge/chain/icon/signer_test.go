@@ -0,0 +1,65 @@
+package icon
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/icon-project/icon-bridge/common/log"
+	"github.com/icon-project/icon-bridge/common/wallet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignTransactionOffline(t *testing.T) {
+	w := wallet.New()
+	newParam := func() *TransactionParam {
+		return &TransactionParam{
+			Version:     NewHexInt(JsonrpcApiVersion),
+			FromAddress: Address(w.Address()),
+			ToAddress:   Address("hx0000000000000000000000000000000000000001"),
+			StepLimit:   NewHexInt(100000),
+			NetworkID:   NewHexInt(1),
+		}
+	}
+	var sent []TransactionParam
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_sendTransaction": func(params json.RawMessage) interface{} {
+			var p TransactionParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			sent = append(sent, p)
+			return "0x01"
+		},
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	// signed as by the client, carried in JSON to the client
+	st, err := SignTransactionOffline(w, newParam(), nil)
+	require.NoError(t, err)
+	p := newParam()
+	p.Timestamp = st.Param.Timestamp
+	require.NoError(t, cl.SignTransaction(w, p))
+	require.Equal(t, p.TxHash, st.TxHash)
+	b, err := json.Marshal(st)
+	require.NoError(t, err)
+	var received SignedTransaction
+	require.NoError(t, json.Unmarshal(b, &received))
+	require.Equal(t, st.TxHash, received.TxHash)
+
+	_, err = cl.SendSignedTransaction(&received)
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	require.Equal(t, st.Param.Timestamp, sent[0].Timestamp)
+	require.Equal(t, st.Param.Signature, sent[0].Signature)
+
+	// signed with another hash than the client's, or not signed
+	st, err = SignTransactionOffline(w, newParam(), &ClientOptions{Hash: "keccak-256"})
+	require.NoError(t, err)
+	_, err = cl.SendSignedTransaction(st)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "hash mismatch")
+	_, err = cl.SendSignedTransaction(&SignedTransaction{Param: newParam()})
+	require.Error(t, err)
+	require.Len(t, sent, 1)
+
+	_, err = SignTransactionOffline(w, newParam(), &ClientOptions{Hash: "md5"})
+	require.EqualError(t, err, `unknown hash "md5"`)
+}
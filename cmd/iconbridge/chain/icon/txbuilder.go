@@ -0,0 +1,101 @@
+package icon
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/icon-project/icon-bridge/common/intconv"
+	"github.com/pkg/errors"
+)
+
+// TransactionBuilder builds a TransactionParam, checking on Build that the
+// fields required to sign and send it are set, e.g.
+//
+//	p, err := NewTransactionBuilder().
+//		From(from).To(bts).NetworkID(nid).StepLimit(limit).
+//		CallMethod("transfer").Params(params).
+//		Build()
+type TransactionBuilder struct {
+	p      TransactionParam
+	method string
+	params interface{}
+}
+
+// NewTransactionBuilder returns a builder of a transaction of version
+// JsonrpcApiVersion.
+func NewTransactionBuilder() *TransactionBuilder {
+	return &TransactionBuilder{p: TransactionParam{Version: NewHexInt(JsonrpcApiVersion)}}
+}
+
+func (b *TransactionBuilder) From(a Address) *TransactionBuilder {
+	b.p.FromAddress = a
+	return b
+}
+
+func (b *TransactionBuilder) To(a Address) *TransactionBuilder {
+	b.p.ToAddress = a
+	return b
+}
+
+// Value sets the amount of ICX in loop transferred with the transaction.
+func (b *TransactionBuilder) Value(v *big.Int) *TransactionBuilder {
+	b.p.Value = HexInt(intconv.FormatBigInt(v))
+	return b
+}
+
+func (b *TransactionBuilder) StepLimit(limit int64) *TransactionBuilder {
+	b.p.StepLimit = NewHexInt(limit)
+	return b
+}
+
+func (b *TransactionBuilder) NetworkID(nid HexInt) *TransactionBuilder {
+	b.p.NetworkID = nid
+	return b
+}
+
+func (b *TransactionBuilder) Nonce(nonce int64) *TransactionBuilder {
+	b.p.Nonce = NewHexInt(nonce)
+	return b
+}
+
+// CallMethod makes the transaction call method of the contract it is sent
+// to, with the arguments set by Params.
+func (b *TransactionBuilder) CallMethod(method string) *TransactionBuilder {
+	b.method = method
+	return b
+}
+
+// Params sets the arguments of the method set by CallMethod, e.g. a
+// map[string]interface{} or a struct with JSON tags.
+func (b *TransactionBuilder) Params(params interface{}) *TransactionBuilder {
+	b.params = params
+	return b
+}
+
+// Build returns the transaction, to be signed by SignTransaction.
+func (b *TransactionBuilder) Build() (*TransactionParam, error) {
+	p := b.p
+	if err := ValidateAddress(p.FromAddress); err != nil {
+		return nil, fmt.Errorf("from: %v", err)
+	} else if !strings.HasPrefix(string(p.FromAddress), "hx") {
+		return nil, fmt.Errorf("from: not an account address %q", p.FromAddress)
+	}
+	if err := ValidateAddress(p.ToAddress); err != nil {
+		return nil, fmt.Errorf("to: %v", err)
+	}
+	if p.StepLimit == "" {
+		return nil, errors.New("missing step limit")
+	}
+	if p.NetworkID == "" {
+		return nil, errors.New("missing network id")
+	}
+	switch {
+	case b.method != "":
+		p.DataType = "call"
+		p.Data = CallData{Method: b.method, Params: b.params}
+	case b.params != nil:
+		return nil, errors.New("params without method")
+	}
+	return &p, nil
+}
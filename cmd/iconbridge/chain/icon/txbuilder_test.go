@@ -0,0 +1,47 @@
+package icon
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionBuilder(t *testing.T) {
+	from := Address("hx" + strings.Repeat("02", 20))
+	bts := Address("cx" + strings.Repeat("01", 20))
+	build := func() *TransactionBuilder {
+		return NewTransactionBuilder().From(from).To(bts).NetworkID("0x3").StepLimit(1000)
+	}
+
+	p, err := build().Value(big.NewInt(10)).
+		CallMethod("transfer").Params(map[string]string{"_to": "btp://0x1.hmny/0x01"}).
+		Build()
+	require.NoError(t, err)
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"version": "0x3", "from": "`+string(from)+`", "to": "`+string(bts)+`",
+		"value": "0xa", "stepLimit": "0x3e8", "timestamp": "", "nid": "0x3",
+		"signature": "", "dataType": "call",
+		"data": {"method": "transfer", "params": {"_to": "btp://0x1.hmny/0x01"}}
+	}`, string(b))
+
+	p, err = build().Build()
+	require.NoError(t, err)
+	require.Empty(t, p.DataType)
+	require.Nil(t, p.Data)
+
+	for name, b := range map[string]*TransactionBuilder{
+		"from":       build().From("cx" + from[2:]),
+		"to":         build().To("cx01"),
+		"step limit": NewTransactionBuilder().From(from).To(bts).NetworkID("0x3"),
+		"network id": NewTransactionBuilder().From(from).To(bts).StepLimit(1000),
+		"params":     build().Params(map[string]string{}),
+	} {
+		_, err := b.Build()
+		require.Error(t, err, name)
+	}
+}
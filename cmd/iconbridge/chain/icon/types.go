@@ -41,8 +41,12 @@ const (
 	JsonrpcErrorCodeScore          jsonrpc.ErrorCode = -30000
 )
 
+// SystemErrorCode is the sub-code of a JsonrpcErrorCodeSystem error,
+// carried at the head of its message as "E<code>:<message>".
+type SystemErrorCode int
+
 const (
-	DuplicateTransactionError = iota + 2000
+	DuplicateTransactionError SystemErrorCode = iota + 2000
 	TransactionPoolOverflowError
 	ExpiredTransactionError
 	FutureTransactionError
@@ -126,6 +130,16 @@ type TransactionParam struct {
 	TxHash      HexBytes    `json:"-"`
 }
 
+// Transaction is a transaction as returned by icx_getTransactionByHash:
+// the params it was sent with and where it was included.
+type Transaction struct {
+	TransactionParam
+	TxHash      HexBytes `json:"txHash"`
+	TxIndex     HexInt   `json:"txIndex"`
+	BlockHeight HexInt   `json:"blockHeight"`
+	BlockHash   HexBytes `json:"blockHash"`
+}
+
 type CallData struct {
 	Method string      `json:"method"`
 	Params interface{} `json:"params,omitempty"`
@@ -173,6 +187,9 @@ type TransactionHashParam struct {
 type BlockHeightParam struct {
 	Height HexInt `json:"height" validate:"required,t_int"`
 }
+type BlockHashParam struct {
+	Hash HexBytes `json:"hash" validate:"required,t_hash"`
+}
 type DataHashParam struct {
 	Hash HexBytes `json:"hash" validate:"required,t_hash"`
 }
@@ -228,6 +245,30 @@ type WSResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// Codes of a WSResponse to a monitor request. The node answers a request it
+// can't serve with the code of the corresponding JSON-RPC error.
+const (
+	WSResponseCodeOK             = 0
+	WSResponseCodeInvalidRequest = int(jsonrpc.ErrorCodeInvalidRequest)
+	WSResponseCodeInvalidParams  = int(jsonrpc.ErrorCodeInvalidParams)
+	WSResponseCodeServer         = int(jsonrpc.ErrorCodeServer)
+	WSResponseCodeSystem         = int(JsonrpcErrorCodeSystem)
+	WSResponseCodeLackOfResource = int(JsonrpcErrorLackOfResource)
+	WSResponseCodeTimeout        = int(JsonrpcErrorCodeTimeout)
+	WSResponseCodeSystemTimeout  = int(JsonrpcErrorCodeSystemTimeout)
+)
+
+// DefaultWSRetryableCodes are the codes of a WSResponse reporting a
+// transient failure of the node, for which a monitor request is retried;
+// any other code means the request itself is rejected.
+var DefaultWSRetryableCodes = []int{
+	WSResponseCodeServer,
+	WSResponseCodeSystem,
+	WSResponseCodeLackOfResource,
+	WSResponseCodeTimeout,
+	WSResponseCodeSystemTimeout,
+}
+
 //T_BIN_DATA, T_HASH
 type HexBytes string
 
@@ -279,6 +320,9 @@ type Address string
 
 func (a Address) Value() ([]byte, error) {
 	var b [21]byte
+	if len(a) < 2 {
+		return nil, fmt.Errorf("invalid address %q", string(a))
+	}
 	switch a[:2] {
 	case "cx":
 		b[0] = 1
@@ -328,7 +372,7 @@ type ReceiptProof struct {
 }
 
 type Block struct {
-	//BlockHash              HexBytes  `json:"block_hash" validate:"required,t_hash"`
+	BlockHash HexBytes `json:"block_hash" validate:"required,t_hash"`
 	//Version                HexInt    `json:"version" validate:"required,t_int"`
 	Height int64 `json:"height" validate:"required,t_int"`
 	//Timestamp              int64             `json:"time_stamp" validate:"required,t_int"`
@@ -351,3 +395,13 @@ type Block struct {
 	} `json:"confirmed_transaction_list"`
 	//Signature              HexBytes  `json:"signature" validate:"optional,t_hash"`
 }
+
+// NetworkInfo is the result of icx_getNetworkInfo.
+type NetworkInfo struct {
+	Platform  string `json:"platform"`
+	NID       HexInt `json:"nid"`
+	Channel   string `json:"channel"`
+	Earliest  HexInt `json:"earliest"` // height of the first block kept
+	Latest    HexInt `json:"latest"`
+	StepPrice HexInt `json:"stepPrice"`
+}
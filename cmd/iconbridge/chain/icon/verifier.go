@@ -12,17 +12,88 @@ import (
 type VerifierOptions struct {
 	BlockHeight    uint64         `json:"blockHeight"`
 	ValidatorsHash common.HexHash `json:"validatorsHash"`
+	// ValidatorsCacheSize is the number of validator sets kept by hash,
+	// the least recently updated being evicted first; an evicted set is
+	// fetched again if needed. Defaults to DefaultValidatorsCacheSize.
+	ValidatorsCacheSize int `json:"validatorsCacheSize"`
 }
 
-type commitVoteItem struct {
+const DefaultValidatorsCacheSize = 16
+
+type CommitVoteItem struct {
 	Timestamp int64
 	Signature common.Signature
 }
 
-type commitVoteList struct {
+type CommitVoteList struct {
 	Round          int32
 	BlockPartSetID *PartSetID
-	Items          []commitVoteItem
+	Items          []CommitVoteItem
+}
+
+// DecodeCommitVoteList decodes the votes of a block as returned by
+// icx_getVotesByHeight.
+func DecodeCommitVoteList(votes []byte) (*CommitVoteList, error) {
+	cvl := &CommitVoteList{}
+	if _, err := codec.BC.UnmarshalFromBytes(votes, cvl); err != nil {
+		return nil, fmt.Errorf("invalid votes: %v; err=%v", common.HexBytes(votes), err)
+	}
+	return cvl, nil
+}
+
+// Tally returns the validators in the set whose precommit votes for
+// blockHeader are in the list, in the order of the votes, and their quorum
+// against the set. Every validator has a voting power of 1; votes with
+// invalid signatures or of unknown validators are ignored.
+func (cvl *CommitVoteList) Tally(blockHeader *BlockHeader, validators []common.Address) (signers []common.Address, q *Quorum) {
+	hash := crypto.SHA3Sum256(codec.BC.MustMarshalToBytes(blockHeader))
+	vote := &vote{
+		voteBase: voteBase{
+			_HR: _HR{
+				Height: blockHeader.Height,
+				Round:  cvl.Round,
+			},
+			Type:           VoteTypePrecommit,
+			BlockID:        hash,
+			BlockPartSetID: cvl.BlockPartSetID,
+		},
+	}
+
+	remaining := make(map[common.Address]struct{})
+	for _, val := range validators {
+		remaining[val] = struct{}{}
+	}
+
+	for _, item := range cvl.Items {
+		vote.Timestamp = item.Timestamp
+		pub, err := item.Signature.RecoverPublicKey(crypto.SHA3Sum256(codec.BC.MustMarshalToBytes(vote)))
+		if err != nil {
+			continue // skip error
+		}
+		address := common.NewAccountAddressFromPublicKey(pub)
+		if address == nil {
+			continue
+		}
+		if _, ok := remaining[*address]; !ok {
+			continue // already voted or invalid validator
+		}
+		delete(remaining, *address)
+		signers = append(signers, *address)
+	}
+	return signers, &Quorum{
+		Votes:      len(signers),
+		Required:   requiredVotes(len(validators)),
+		Validators: len(validators),
+	}
+}
+
+// requiredVotes returns the number of votes required out of numValidators.
+func requiredVotes(numValidators int) int {
+	required := (2 * numValidators) / 3
+	if required < 1 {
+		required = 1
+	}
+	return required
 }
 
 type PartSetID struct {
@@ -80,71 +151,84 @@ type Verifier struct {
 	mu                 sync.RWMutex
 	next               int64
 	nextValidatorsHash common.HexHash
-	validators         map[string][]common.Address // convert this to lru cache
+	validators         map[string][]common.Address
+	hashes             []string // keys of validators, least recently updated first
+	maxValidators      int      // max number of validators kept, unlimited if zero
+
+	quorumMtx sync.Mutex
+	quorum    *Quorum // of the last block verified
+}
+
+// Quorum is the tally of the votes of a block against its validators.
+type Quorum struct {
+	Votes      int `json:"votes"`      // valid votes of the validators
+	Required   int `json:"required"`   // votes required to reach the quorum
+	Validators int `json:"validators"` // size of the validator set
+}
+
+// Margin returns the number of votes above the quorum, negative if short.
+// A shrinking margin warns of validators failing to vote.
+func (q *Quorum) Margin() int {
+	return q.Votes - q.Required
+}
+
+// Reached returns whether the votes reach the quorum.
+func (q *Quorum) Reached() bool {
+	return q.Margin() >= 0
 }
 
 func (vr *Verifier) Next() int64 { return vr.next }
 
 func (vr *Verifier) Verify(blockHeader *BlockHeader, votes []byte) (ok bool, err error) {
+	_, err = vr.VerifyQuorum(blockHeader, votes)
+	return err == nil, err
+}
+
+// VerifyQuorum is Verify returning the tally of the votes, also if they
+// are insufficient. The quorum is nil if the votes could not be tallied.
+func (vr *Verifier) VerifyQuorum(blockHeader *BlockHeader, votes []byte) (*Quorum, error) {
 	vr.mu.RLock()
 	defer vr.mu.RUnlock()
 
 	nextValidatorsHash := vr.nextValidatorsHash
 	listValidators, ok := vr.validators[nextValidatorsHash.String()]
 	if !ok {
-		return false, fmt.Errorf("no validators for hash=%v", nextValidatorsHash)
+		return nil, fmt.Errorf("no validators for hash=%v", nextValidatorsHash)
 	}
 
-	requiredVotes := (2 * len(listValidators)) / 3
-	if requiredVotes < 1 {
-		requiredVotes = 1
+	q, err := tallyQuorum(blockHeader, votes, listValidators)
+	if q != nil {
+		vr.quorumMtx.Lock()
+		vr.quorum = q
+		vr.quorumMtx.Unlock()
 	}
+	return q, err
+}
 
-	cvl := &commitVoteList{}
-	_, err = codec.BC.UnmarshalFromBytes(votes, cvl)
-	if err != nil {
-		return false, fmt.Errorf("invalid votes: %v; err=%v", common.HexBytes(votes), err)
-	}
-
-	hash := crypto.SHA3Sum256(codec.BC.MustMarshalToBytes(blockHeader))
-	vote := &vote{
-		voteBase: voteBase{
-			_HR: _HR{
-				Height: blockHeader.Height,
-				Round:  cvl.Round,
-			},
-			Type:           VoteTypePrecommit,
-			BlockID:        hash,
-			BlockPartSetID: cvl.BlockPartSetID,
-		},
+// VerifyHeader checks that the votes of the block header reach the quorum of
+// validators, the validators of the block, without a Verifier. It's meant
+// to audit specific headers, the receiver verifying the chain of blocks
+// with a Verifier instead.
+func VerifyHeader(blockHeader *BlockHeader, votes []byte, validators []common.Address) (bool, error) {
+	if len(validators) == 0 {
+		return false, fmt.Errorf("no validators")
 	}
+	_, err := tallyQuorum(blockHeader, votes, validators)
+	return err == nil, err
+}
 
-	numVotes := 0
-	validators := make(map[common.Address]struct{})
-	for _, val := range listValidators {
-		validators[val] = struct{}{}
+// tallyQuorum tallies the votes of the block header against validators. The
+// quorum is nil if the votes could not be tallied.
+func tallyQuorum(blockHeader *BlockHeader, votes []byte, validators []common.Address) (*Quorum, error) {
+	cvl, err := DecodeCommitVoteList(votes)
+	if err != nil {
+		return nil, err
 	}
-
-	for _, item := range cvl.Items {
-		vote.Timestamp = item.Timestamp
-		pub, err := item.Signature.RecoverPublicKey(crypto.SHA3Sum256(codec.BC.MustMarshalToBytes(vote)))
-		if err != nil {
-			continue // skip error
-		}
-		address := common.NewAccountAddressFromPublicKey(pub)
-		if address == nil {
-			continue
-		}
-		if _, ok := validators[*address]; !ok {
-			continue // already voted or invalid validator
-		}
-		delete(validators, *address)
-		if numVotes++; numVotes >= requiredVotes {
-			return true, nil
-		}
+	_, q := cvl.Tally(blockHeader, validators)
+	if !q.Reached() {
+		return q, fmt.Errorf("insufficient votes")
 	}
-
-	return false, fmt.Errorf("insufficient votes")
+	return q, nil
 }
 
 func (vr *Verifier) Update(blockHeader *BlockHeader, nextValidators []common.Address) (err error) {
@@ -152,15 +236,67 @@ func (vr *Verifier) Update(blockHeader *BlockHeader, nextValidators []common.Add
 	defer vr.mu.Unlock()
 	nextValidatorsHash := common.HexBytes(blockHeader.NextValidatorsHash)
 
-	if _, ok := vr.validators[nextValidatorsHash.String()]; !ok {
-		vr.validators[nextValidatorsHash.String()] = nextValidators
+	key := nextValidatorsHash.String()
+	if _, ok := vr.validators[key]; !ok {
+		vr.validators[key] = nextValidators
+	} else {
+		for i, h := range vr.hashes {
+			if h == key {
+				vr.hashes = append(vr.hashes[:i], vr.hashes[i+1:]...)
+				break
+			}
+		}
 	}
+	vr.hashes = append(vr.hashes, key)
 
 	vr.next = blockHeader.Height + 1
 	vr.nextValidatorsHash = blockHeader.NextValidatorsHash
+	vr.evict()
 	return nil
 }
 
+// evict removes the least recently updated validators beyond maxValidators,
+// except the validators of the next block.
+func (vr *Verifier) evict() {
+	for vr.maxValidators > 0 && len(vr.hashes) > vr.maxValidators {
+		i := 0
+		if vr.hashes[0] == vr.nextValidatorsHash.String() {
+			i = 1
+		}
+		delete(vr.validators, vr.hashes[i])
+		vr.hashes = append(vr.hashes[:i], vr.hashes[i+1:]...)
+	}
+}
+
+// VerifierStatus is a snapshot of the state of a Verifier.
+type VerifierStatus struct {
+	Next               int64            `json:"next"`
+	NextValidatorsHash common.HexHash   `json:"nextValidatorsHash"`
+	Validators         []common.Address `json:"validators"`
+	// Quorum is the tally of the last block verified, if any.
+	Quorum *Quorum `json:"quorum,omitempty"`
+}
+
+// Status returns the next height to verify and the validators expected to
+// sign it. It's safe to call concurrently with Verify and Update.
+func (vr *Verifier) Status() *VerifierStatus {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+	validators := vr.validators[vr.nextValidatorsHash.String()]
+	status := &VerifierStatus{
+		Next:               vr.next,
+		NextValidatorsHash: vr.nextValidatorsHash,
+		Validators:         append([]common.Address(nil), validators...),
+	}
+	vr.quorumMtx.Lock()
+	if vr.quorum != nil {
+		q := *vr.quorum
+		status.Quorum = &q
+	}
+	vr.quorumMtx.Unlock()
+	return status
+}
+
 func (vr *Verifier) Validators(nextValidatorsHash common.HexBytes) []common.Address {
 	vr.mu.RLock()
 	defer vr.mu.RUnlock()
@@ -1,6 +1,7 @@
 package icon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -9,7 +10,9 @@ import (
 	ethc "github.com/ethereum/go-ethereum/common"
 	"github.com/icon-project/goloop/common"
 	"github.com/icon-project/goloop/common/codec"
+	gocrypto "github.com/icon-project/goloop/common/crypto"
 	"github.com/icon-project/icon-bridge/common/crypto"
+	"github.com/icon-project/icon-bridge/common/log"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,8 +27,8 @@ func NewSampleTestVerifier() *Verifier {
 	}
 }
 
-func getCommitVoteItem(ts int64, sig string) commitVoteItem {
-	cv := commitVoteItem{Timestamp: ts}
+func getCommitVoteItem(ts int64, sig string) CommitVoteItem {
+	cv := CommitVoteItem{Timestamp: ts}
 	_sig, _ := json.Marshal(sig)
 	cv.Signature.UnmarshalJSON([]byte(_sig))
 	return cv
@@ -45,14 +48,14 @@ func getSampleValidators() []common.Address {
 	}
 }
 
-func getSampleCommitVoteList() *commitVoteList {
-	cvl := commitVoteList{
+func getSampleCommitVoteList() *CommitVoteList {
+	cvl := CommitVoteList{
 		Round: 0,
 		BlockPartSetID: &PartSetID{
 			Count: 1,
 			Hash:  ethc.Hex2Bytes("3b27a2dea9d1e8ecd1c94ff723f9efe8ed79e54f0708fa459a57148ff2aab3f1"),
 		},
-		Items: []commitVoteItem{
+		Items: []CommitVoteItem{
 			getCommitVoteItem(1652523324922454, "5QIv0HrkyBU0wqqy/f6HFhPiCbqf9GK11z46LyrL9WAQD25TZdthyZfJXd4B3+4eIMxzW4i5oXicbD6+UtbtWQE="),
 			getCommitVoteItem(1652523324864943, "weofhyea6ixet/a1sKH986dRgYRoQZ6PxA9is90eIuJ/036poH3Hj28PtCKJ2ayWikbjkIYhpkBxFegnIkLnMgA="),
 			getCommitVoteItem(1652523324882445, "ocjI0SOiMpd3ZCDWAmPqAyqaRZK4zi5A3cg9y4OFC8Ft/4H5Gkpfc2fCSkvzJMva0rPvUNLjgnyWUyKiUWILhgE="),
@@ -141,7 +144,7 @@ func TestVerifierWhenInvalidAddress(t *testing.T) {
 	h := getSampleHeader()
 	vr := NewSampleTestVerifier()
 	cvl := getSampleCommitVoteList()
-	cvl.Items = []commitVoteItem{
+	cvl.Items = []CommitVoteItem{
 			getCommitVoteItem(1652523324922454, ""),
 			getCommitVoteItem(1652523324922454, ""),
 			getCommitVoteItem(1652523324922454, ""),
@@ -236,6 +239,33 @@ func TestVerifier_Update(t *testing.T) {
 	require.EqualValues(t, blockHeaderNew.Height + 1, vr.next)
 }
 
+func TestVerifierValidatorsCache(t *testing.T) {
+	vr := NewSampleTestVerifier()
+	initial := vr.nextValidatorsHash
+	vr.hashes = []string{initial.String()}
+	vr.maxValidators = 2
+	validators := getSampleValidators()
+	update := func(height int64, hash string) {
+		require.NoError(t, vr.Update(&BlockHeader{Height: height, NextValidatorsHash: []byte(hash)}, validators))
+	}
+
+	update(1, "a")
+	require.Len(t, vr.validators, 2)
+	update(2, "b") // evicts the initial set
+	require.Nil(t, vr.Validators(initial.Bytes()))
+	require.NotNil(t, vr.Validators([]byte("a")))
+	update(3, "a") // a becomes the most recent
+	update(4, "c") // evicts b
+	require.Nil(t, vr.Validators([]byte("b")))
+	require.NotNil(t, vr.Validators([]byte("a")))
+	require.NotNil(t, vr.Validators([]byte("c")))
+
+	vr.maxValidators = 1
+	update(5, "c") // keeps the validators of the next block
+	require.Len(t, vr.validators, 1)
+	require.NotNil(t, vr.Validators([]byte("c")))
+}
+
 func TestVerifier_GetValidators_Success(t *testing.T) {
 	vr := NewSampleTestVerifier()
 
@@ -251,4 +281,208 @@ func TestVerifier_GetValidators_NotFound(t *testing.T) {
 	address := vr.Validators([]byte("Unknown validator address"))
 
 	require.Nil(t, address)
-}
\ No newline at end of file
+}
+func TestVerifierStatus(t *testing.T) {
+	vr := NewSampleTestVerifier()
+
+	status := vr.Status()
+	require.EqualValues(t, 50000001, status.Next)
+	require.Equal(t, vr.nextValidatorsHash, status.NextValidatorsHash)
+	require.Equal(t, getSampleValidators(), status.Validators)
+
+	status.Validators[0] = common.Address{}
+	require.Equal(t, getSampleValidators(), vr.Validators(vr.nextValidatorsHash.Bytes()))
+}
+
+func TestVerifierQuorum(t *testing.T) {
+	h := getSampleHeader()
+	vr := NewSampleTestVerifier()
+	require.Nil(t, vr.Status().Quorum)
+	cvl := getSampleCommitVoteList()
+
+	rawVotes, err := codec.BC.MarshalToBytes(cvl)
+	require.NoError(t, err)
+	q, err := vr.VerifyQuorum(h, rawVotes)
+	require.NoError(t, err)
+	require.Equal(t, &Quorum{Votes: 3, Required: 2, Validators: 3}, q)
+	require.Equal(t, 1, q.Margin())
+	require.Equal(t, q, vr.Status().Quorum)
+
+	cvl.Items = cvl.Items[:1]
+	rawVotes, err = codec.BC.MarshalToBytes(cvl)
+	require.NoError(t, err)
+	q, err = vr.VerifyQuorum(h, rawVotes)
+	require.EqualError(t, err, "insufficient votes")
+	require.Equal(t, -1, q.Margin())
+	require.Equal(t, q, vr.Status().Quorum)
+
+	q, err = vr.VerifyQuorum(h, []byte("invalid"))
+	require.Error(t, err)
+	require.Nil(t, q)
+}
+
+func TestVerifyHeader(t *testing.T) {
+	h := getSampleHeader()
+	vr := NewSampleTestVerifier()
+	status := vr.Status()
+	rawVotes, err := codec.BC.MarshalToBytes(getSampleCommitVoteList())
+	require.NoError(t, err)
+
+	ok, err := VerifyHeader(h, rawVotes, getSampleValidators())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, status, vr.Status(), "no verifier state involved")
+
+	others := []common.Address{
+		*common.MustNewAddressFromString("hx" + strings.Repeat("01", 20)),
+		*common.MustNewAddressFromString("hx" + strings.Repeat("02", 20)),
+	}
+	ok, err = VerifyHeader(h, rawVotes, append(getSampleValidators()[:1], others...))
+	require.EqualError(t, err, "insufficient votes")
+	require.False(t, ok)
+
+	ok, err = VerifyHeader(h, rawVotes, nil)
+	require.EqualError(t, err, "no validators")
+	require.False(t, ok)
+
+	ok, err = VerifyHeader(h, []byte("invalid"), getSampleValidators())
+	require.Error(t, err)
+	require.False(t, ok)
+}
+
+func TestCommitVoteListTally(t *testing.T) {
+	h := getSampleHeader()
+	rawVotes, err := codec.BC.MarshalToBytes(getSampleCommitVoteList())
+	require.NoError(t, err)
+
+	cvl, err := DecodeCommitVoteList(rawVotes)
+	require.NoError(t, err)
+	require.Len(t, cvl.Items, 3)
+
+	signers, q := cvl.Tally(h, getSampleValidators())
+	require.True(t, q.Reached())
+	require.ElementsMatch(t, getSampleValidators(), signers)
+	require.Equal(t, &Quorum{Votes: 3, Required: 2, Validators: 3}, q)
+
+	signers, q = cvl.Tally(h, getSampleValidators()[:1])
+	require.True(t, q.Reached())
+	require.Equal(t, getSampleValidators()[:1], signers)
+
+	others := []common.Address{
+		*common.MustNewAddressFromString("hx" + strings.Repeat("01", 20)),
+		*common.MustNewAddressFromString("hx" + strings.Repeat("02", 20)),
+	}
+	_, q = cvl.Tally(h, append(getSampleValidators()[:1], others...))
+	require.False(t, q.Reached())
+	require.Equal(t, &Quorum{Votes: 1, Required: 2, Validators: 3}, q)
+
+	cvl.Items = cvl.Items[:1]
+	signers, q = cvl.Tally(h, getSampleValidators())
+	require.False(t, q.Reached())
+	require.Len(t, signers, 1)
+
+	_, err = DecodeCommitVoteList([]byte("invalid"))
+	require.Error(t, err)
+}
+
+func TestGetCommitVoteListByHeight(t *testing.T) {
+	rawVotes, err := codec.BC.MarshalToBytes(getSampleCommitVoteList())
+	require.NoError(t, err)
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getVotesByHeight": func(json.RawMessage) interface{} { return rawVotes },
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	cvl, err := cl.getCommitVoteListByHeight(context.Background(), 1)
+	require.NoError(t, err)
+	expected, err := DecodeCommitVoteList(rawVotes)
+	require.NoError(t, err)
+	require.Equal(t, expected, cvl)
+}
+
+func TestDecodeValidators(t *testing.T) {
+	validators := getSampleValidators()
+	data, err := codec.BC.MarshalToBytes(validators)
+	require.NoError(t, err)
+	decoded, err := decodeValidators(data)
+	require.NoError(t, err)
+	require.Equal(t, validators, decoded)
+
+	// list of public keys and addresses
+	_, pub := gocrypto.GenerateKeyPair()
+	data, err = codec.BC.MarshalToBytes([][]byte{pub.SerializeCompressed(), validators[0].Bytes()})
+	require.NoError(t, err)
+	decoded, err = decodeValidators(data)
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{*common.NewAccountAddressFromPublicKey(pub), validators[0]}, decoded)
+
+	_, err = decodeValidators([]byte{0x01, 0x02})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "len(data)=2")
+
+	data, err = codec.BC.MarshalToBytes([][]byte{{0x01, 0x02}})
+	require.NoError(t, err)
+	_, err = decodeValidators(data)
+	require.Error(t, err)
+}
+
+func TestGetValidatorsByHashChecksSet(t *testing.T) {
+	validators := getSampleValidators()
+	for _, tc := range []struct {
+		validators []common.Address
+		err        string
+	}{
+		{validators, ""},
+		{[]common.Address{}, "validator set empty at hash"},
+		{append([]common.Address{validators[1]}, validators[:2]...), "duplicate validator: index=2"},
+	} {
+		data, err := codec.BC.MarshalToBytes(tc.validators)
+		require.NoError(t, err)
+		srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+			"icx_getDataByHash": func(json.RawMessage) interface{} { return data },
+		})
+		cl := NewClient(srv.URL, log.New())
+		got, err := cl.getValidatorsByHash(context.Background(), crypto.SHA3Sum256(data))
+		if tc.err == "" {
+			require.NoError(t, err)
+			require.Equal(t, tc.validators, got)
+		} else {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	}
+}
+
+func TestValidateVerifierOptions(t *testing.T) {
+	data, err := codec.BC.MarshalToBytes(getSampleValidators())
+	require.NoError(t, err)
+	validatorsHash := common.HexHash(crypto.SHA3Sum256(data))
+	var heights []int64
+	srv := newTestRPCServer(t, map[string]func(json.RawMessage) interface{}{
+		"icx_getBlockHeaderByHeight": func(params json.RawMessage) interface{} {
+			var p BlockHeightParam
+			require.NoError(t, json.Unmarshal(params, &p))
+			height, err := p.Height.Value()
+			require.NoError(t, err)
+			heights = append(heights, height)
+			return codec.RLP.MustMarshalToBytes(&BlockHeader{Height: height, NextValidatorsHash: validatorsHash})
+		},
+		"icx_getDataByHash": func(json.RawMessage) interface{} { return data },
+	})
+	cl := NewClient(srv.URL, log.New())
+
+	require.NoError(t, cl.ValidateVerifierOptions(context.Background(),
+		&VerifierOptions{BlockHeight: 100, ValidatorsHash: validatorsHash}))
+	require.Equal(t, []int64{99}, heights)
+
+	err = cl.ValidateVerifierOptions(context.Background(),
+		&VerifierOptions{BlockHeight: 100, ValidatorsHash: common.HexHash(crypto.SHA3Sum256([]byte("other")))})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "validatorsHash mismatch at height 100")
+
+	// no previous block to check at height 1
+	heights = nil
+	require.NoError(t, cl.ValidateVerifierOptions(context.Background(),
+		&VerifierOptions{BlockHeight: 1, ValidatorsHash: validatorsHash}))
+	require.Empty(t, heights)
+}
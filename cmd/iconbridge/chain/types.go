@@ -31,6 +31,9 @@ type Receipt struct {
 type Message struct {
 	From     BTPAddress
 	Receipts []*Receipt
+	// Head is the latest block height of the source chain known to the
+	// receiver when the message was produced, or 0 if unknown.
+	Head uint64
 	// Headers  []interface{}
 }
 
@@ -57,7 +60,9 @@ type RelayTx interface {
 }
 
 type SubscribeOptions struct {
-	Seq    uint64
+	Seq uint64
+	// Height to start from. The icon receiver starts from the latest
+	// block if it is 0.
 	Height uint64
 }
 
@@ -46,6 +46,11 @@ func (r *relay) rxHeight(linkRxHeight uint64) uint64 {
 	if r.cfg.Src.Offset > height {
 		height = r.cfg.Src.Offset
 	}
+	if height < 1 {
+		// the relay must not skip blocks: height 0 asks some
+		// receivers to start from the latest block instead
+		height = 1
+	}
 	return height
 }
 
@@ -14,6 +14,13 @@ func SHA3Sum256(m []byte) []byte {
 	return d[:]
 }
 
+// Keccak256 returns the legacy Keccak-256 digest of the data
+func Keccak256(m []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(m)
+	return h.Sum(nil)
+}
+
 // SHASum256 returns the SHA256 digest of the data
 func SHASum256(m []byte) []byte {
 	d := sha256.Sum256(m)
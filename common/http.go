@@ -400,6 +400,7 @@ type HttpError struct {
 	status   int
 	response []byte
 	message  string
+	header   http.Header
 }
 
 func (e *HttpError) Error() string {
@@ -414,10 +415,16 @@ func (e *HttpError) Response() []byte {
 	return e.response
 }
 
+// Header returns the header of the response, e.g. for its Retry-After.
+func (e *HttpError) Header() http.Header {
+	return e.header
+}
+
 func NewHttpError(r *http.Response) *HttpError {
 	hErr := &HttpError{
 		status:   r.StatusCode,
 		message:  "HTTP " + r.Status,
+		header:   r.Header,
 	}
 	if rb, err := ioutil.ReadAll(r.Body); err == nil {
 		hErr.response = rb
@@ -43,8 +43,20 @@ func (c *Client) _do(req *http.Request) (resp *http.Response, err error) {
 //Supported Parameter Structures only 'by-name through an Object'
 //refer https://www.jsonrpc.org/specification#parameter_structures
 func (c *Client) Do(method string, reqPtr, respPtr interface{}) (jrResp *Response, err error) {
+	return c.DoWithHeader(method, reqPtr, respPtr, nil)
+}
+
+// DoWithHeader is Do with header set on the request in addition to
+// CustomHeader, for values specific to the request.
+func (c *Client) DoWithHeader(method string, reqPtr, respPtr interface{}, header http.Header) (jrResp *Response, err error) {
+	return c.DoWithID(method, time.Now().UnixNano()/int64(time.Millisecond), reqPtr, respPtr, header)
+}
+
+// DoWithID is DoWithHeader with id as the id of the request, instead of the
+// current time in milliseconds.
+func (c *Client) DoWithID(method string, id interface{}, reqPtr, respPtr interface{}, header http.Header) (jrResp *Response, err error) {
 	jrReq := &Request{
-		ID:      time.Now().UnixNano() / int64(time.Millisecond),
+		ID:      id,
 		Version: Version,
 		Method:  method,
 	}
@@ -69,11 +81,16 @@ func (c *Client) Do(method string, reqPtr, respPtr interface{}) (jrResp *Respons
 	for k, v := range c.CustomHeader {
 		req.Header.Set(k, v)
 	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
 
 	var resp *http.Response
 	resp, err = c._do(req)
 	if err != nil {
-		if hErr, ok := err.(*common.HttpError); ok && len(hErr.Response()) > 0 {
+		// a throttled request is returned as HttpError, with its Retry-After
+		if hErr, ok := err.(*common.HttpError); ok && len(hErr.Response()) > 0 &&
+			hErr.StatusCode() != http.StatusTooManyRequests {
 			if resp != nil && common.HasContentType(resp.Header, echo.MIMEApplicationJSON) {
 				if dErr := json.Unmarshal(hErr.Response(), &jrResp); dErr != nil {
 					err = fmt.Errorf("fail to decode response body err:%+v, httpErr:%+v, httpResp:%+v, responseBody:%s",
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"io/ioutil"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sink is a minimal leveled logger, to which a Logger created by
+// NewWithSink writes. Implement it to bridge to another logging library.
+type Sink interface {
+	Debug(msg string, fields Fields)
+	Info(msg string, fields Fields)
+	Warn(msg string, fields Fields)
+	Error(msg string, fields Fields)
+}
+
+// NewWithSink returns a Logger writing its entries to sink only.
+// Trace entries are written as debug, panic and fatal entries as errors;
+// Panic and Fatal still panic and exit after writing.
+func NewWithSink(sink Sink) Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Level = logrus.DebugLevel
+	logger.SetFormatter(newLogFilter(customFormatter{}))
+	logger.AddHook(&sinkHook{sink: sink})
+	return &loggerWrapper{
+		Logger: logger,
+	}
+}
+
+type sinkHook struct {
+	sink Sink
+}
+
+func (h *sinkHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *sinkHook) Fire(e *logrus.Entry) error {
+	fields := Fields(e.Data)
+	switch e.Level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		h.sink.Debug(e.Message, fields)
+	case logrus.InfoLevel:
+		h.sink.Info(e.Message, fields)
+	case logrus.WarnLevel:
+		h.sink.Warn(e.Message, fields)
+	default:
+		h.sink.Error(e.Message, fields)
+	}
+	return nil
+}
+
+// SinkOf returns a Sink writing to l, to pass a Logger where a Sink is
+// expected.
+func SinkOf(l Logger) Sink {
+	return loggerSink{l}
+}
+
+type loggerSink struct {
+	l Logger
+}
+
+func (s loggerSink) Debug(msg string, fields Fields) {
+	s.l.WithFields(fields).Debug(msg)
+}
+
+func (s loggerSink) Info(msg string, fields Fields) {
+	s.l.WithFields(fields).Info(msg)
+}
+
+func (s loggerSink) Warn(msg string, fields Fields) {
+	s.l.WithFields(fields).Warn(msg)
+}
+
+func (s loggerSink) Error(msg string, fields Fields) {
+	s.l.WithFields(fields).Error(msg)
+}
@@ -0,0 +1,42 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSink []string
+
+func (s *testSink) log(level, msg string, fields Fields) {
+	*s = append(*s, fmt.Sprintf("%s %s %v", level, msg, map[string]interface{}(fields)))
+}
+
+func (s *testSink) Debug(msg string, fields Fields) { s.log("D", msg, fields) }
+func (s *testSink) Info(msg string, fields Fields)  { s.log("I", msg, fields) }
+func (s *testSink) Warn(msg string, fields Fields)  { s.log("W", msg, fields) }
+func (s *testSink) Error(msg string, fields Fields) { s.log("E", msg, fields) }
+
+func TestNewWithSink(t *testing.T) {
+	sink := &testSink{}
+	l := NewWithSink(sink)
+	l.Trace("trace")
+	l.WithFields(Fields{"height": 1}).Debugf("block %d", 1)
+	l.Info("info")
+	l.WithFields(Fields{"a": 1}).WithFields(Fields{"b": 2}).Warn("warn")
+	l.Error("error")
+	l.SetLevel(InfoLevel)
+	l.Debug("filtered")
+	require.Equal(t, []string{
+		"D block 1 map[height:1]",
+		"I info map[]",
+		"W warn map[a:1 b:2]",
+		"E error map[]",
+	}, []string(*sink))
+
+	*sink = nil
+	s := SinkOf(NewWithSink(sink))
+	s.Info("info", Fields{"a": 1})
+	require.Equal(t, []string{"I info map[a:1]"}, []string(*sink))
+}